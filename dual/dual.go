@@ -0,0 +1,188 @@
+package dual
+
+import "math"
+
+// Dual is a dual number with a vector of infinitesimal parts.
+// It carries the value of a function along with its gradient with respect to all seeded variables,
+// so a single function evaluation in dual numbers yields a full row of the function Jacobian.
+type Dual struct {
+	// Re is real part i.e. the function value
+	Re float64
+	// Eps is infinitesimal part i.e. the gradient; nil Eps is a zero gradient
+	Eps []float64
+}
+
+// Const returns a dual number with value v and zero gradient.
+func Const(v float64) Dual {
+	return Dual{Re: v}
+}
+
+// Var returns a dual number with value v which is the i-th of n independent variables.
+func Var(v float64, i, n int) Dual {
+	eps := make([]float64, n)
+	eps[i] = 1.0
+
+	return Dual{Re: v, Eps: eps}
+}
+
+// Vars returns dual numbers with values v seeded as independent variables.
+func Vars(v []float64) []Dual {
+	x := make([]Dual, len(v))
+	for i := range v {
+		x[i] = Var(v[i], i, len(v))
+	}
+
+	return x
+}
+
+// Consts returns dual numbers with values v and zero gradients.
+func Consts(v []float64) []Dual {
+	x := make([]Dual, len(v))
+	for i := range v {
+		x[i] = Const(v[i])
+	}
+
+	return x
+}
+
+// Deriv returns the derivative of d with respect to the i-th variable.
+func (d Dual) Deriv(i int) float64 {
+	if i >= len(d.Eps) {
+		return 0.0
+	}
+
+	return d.Eps[i]
+}
+
+// Add returns d+b.
+func (d Dual) Add(b Dual) Dual {
+	return lin(d.Re+b.Re, 1.0, d, 1.0, b)
+}
+
+// Sub returns d-b.
+func (d Dual) Sub(b Dual) Dual {
+	return lin(d.Re-b.Re, 1.0, d, -1.0, b)
+}
+
+// Mul returns d*b.
+func (d Dual) Mul(b Dual) Dual {
+	return lin(d.Re*b.Re, b.Re, d, d.Re, b)
+}
+
+// Div returns d/b.
+func (d Dual) Div(b Dual) Dual {
+	return lin(d.Re/b.Re, 1/b.Re, d, -d.Re/(b.Re*b.Re), b)
+}
+
+// Neg returns -d.
+func (d Dual) Neg() Dual {
+	return chain(d, -d.Re, -1.0)
+}
+
+// Scale returns f*d.
+func (d Dual) Scale(f float64) Dual {
+	return chain(d, f*d.Re, f)
+}
+
+// AddConst returns d+c.
+func (d Dual) AddConst(c float64) Dual {
+	return chain(d, d.Re+c, 1.0)
+}
+
+// Sin returns sin(d).
+func Sin(d Dual) Dual {
+	return chain(d, math.Sin(d.Re), math.Cos(d.Re))
+}
+
+// Cos returns cos(d).
+func Cos(d Dual) Dual {
+	return chain(d, math.Cos(d.Re), -math.Sin(d.Re))
+}
+
+// Tan returns tan(d).
+func Tan(d Dual) Dual {
+	t := math.Tan(d.Re)
+	return chain(d, t, 1+t*t)
+}
+
+// Tanh returns tanh(d).
+func Tanh(d Dual) Dual {
+	t := math.Tanh(d.Re)
+	return chain(d, t, 1-t*t)
+}
+
+// Exp returns e**d.
+func Exp(d Dual) Dual {
+	e := math.Exp(d.Re)
+	return chain(d, e, e)
+}
+
+// Log returns natural logarithm of d.
+func Log(d Dual) Dual {
+	return chain(d, math.Log(d.Re), 1/d.Re)
+}
+
+// Sqrt returns square root of d.
+func Sqrt(d Dual) Dual {
+	s := math.Sqrt(d.Re)
+	return chain(d, s, 0.5/s)
+}
+
+// Pow returns d**p.
+func Pow(d Dual, p float64) Dual {
+	return chain(d, math.Pow(d.Re, p), p*math.Pow(d.Re, p-1))
+}
+
+// Abs returns absolute value of d. Its derivative at zero is taken to be zero.
+func Abs(d Dual) Dual {
+	switch {
+	case d.Re > 0:
+		return chain(d, d.Re, 1.0)
+	case d.Re < 0:
+		return chain(d, -d.Re, -1.0)
+	}
+
+	return chain(d, 0.0, 0.0)
+}
+
+// Atan2 returns the arc tangent of y/x using the signs of both to determine the quadrant.
+func Atan2(y, x Dual) Dual {
+	r := x.Re*x.Re + y.Re*y.Re
+	return lin(math.Atan2(y.Re, x.Re), x.Re/r, y, -y.Re/r, x)
+}
+
+// chain returns dual number with value v and gradient df*d.Eps
+func chain(d Dual, v, df float64) Dual {
+	if d.Eps == nil {
+		return Dual{Re: v}
+	}
+
+	eps := make([]float64, len(d.Eps))
+	for i := range d.Eps {
+		eps[i] = df * d.Eps[i]
+	}
+
+	return Dual{Re: v, Eps: eps}
+}
+
+// lin returns dual number with value v and gradient ca*a.Eps + cb*b.Eps
+func lin(v, ca float64, a Dual, cb float64, b Dual) Dual {
+	n := len(a.Eps)
+	if len(b.Eps) > n {
+		n = len(b.Eps)
+	}
+
+	if n == 0 {
+		return Dual{Re: v}
+	}
+
+	eps := make([]float64, n)
+	for i := range a.Eps {
+		eps[i] += ca * a.Eps[i]
+	}
+	for i := range b.Eps {
+		eps[i] += cb * b.Eps[i]
+	}
+
+	return Dual{Re: v, Eps: eps}
+}
@@ -0,0 +1,74 @@
+package dual
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDualArithmetic(t *testing.T) {
+	assert := assert.New(t)
+
+	x := Var(3.0, 0, 2)
+	y := Var(2.0, 1, 2)
+	c := Const(4.0)
+
+	testCases := []struct {
+		d  Dual
+		re float64
+		dx float64
+		dy float64
+	}{
+		{x.Add(y), 5.0, 1.0, 1.0},
+		{x.Sub(y), 1.0, 1.0, -1.0},
+		{x.Mul(y), 6.0, 2.0, 3.0},
+		{x.Div(y), 1.5, 0.5, -0.75},
+		{x.Neg(), -3.0, -1.0, 0.0},
+		{x.Scale(2.0), 6.0, 2.0, 0.0},
+		{x.AddConst(1.0), 4.0, 1.0, 0.0},
+		{c.Mul(x).Add(y), 14.0, 4.0, 1.0},
+		{c.Sub(y), 2.0, 0.0, -1.0},
+	}
+
+	for _, tc := range testCases {
+		assert.InDelta(tc.re, tc.d.Re, 1e-12)
+		assert.InDelta(tc.dx, tc.d.Deriv(0), 1e-12)
+		assert.InDelta(tc.dy, tc.d.Deriv(1), 1e-12)
+	}
+
+	// constants have no gradient
+	d := c.Mul(Const(2.0))
+	assert.Nil(d.Eps)
+	assert.Equal(0.0, d.Deriv(0))
+}
+
+func TestDualFunctions(t *testing.T) {
+	assert := assert.New(t)
+
+	v := 0.7
+	x := Var(v, 0, 1)
+
+	testCases := []struct {
+		d  Dual
+		re float64
+		dx float64
+	}{
+		{Sin(x), math.Sin(v), math.Cos(v)},
+		{Cos(x), math.Cos(v), -math.Sin(v)},
+		{Tan(x), math.Tan(v), 1 / (math.Cos(v) * math.Cos(v))},
+		{Tanh(x), math.Tanh(v), 1 - math.Tanh(v)*math.Tanh(v)},
+		{Exp(x), math.Exp(v), math.Exp(v)},
+		{Log(x), math.Log(v), 1 / v},
+		{Sqrt(x), math.Sqrt(v), 0.5 / math.Sqrt(v)},
+		{Pow(x, 3.0), v * v * v, 3 * v * v},
+		{Abs(x.Neg()), v, 1.0},
+		{Atan2(x, Const(2.0)), math.Atan2(v, 2.0), 2.0 / (4.0 + v*v)},
+		{Sin(x.Mul(x)), math.Sin(v * v), 2 * v * math.Cos(v*v)},
+	}
+
+	for _, tc := range testCases {
+		assert.InDelta(tc.re, tc.d.Re, 1e-12)
+		assert.InDelta(tc.dx, tc.d.Deriv(0), 1e-12)
+	}
+}
@@ -0,0 +1,184 @@
+package dual
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Func is a vector function of state x given input u written in dual numbers
+type Func func(x []Dual, u mat.Vector) ([]Dual, error)
+
+// Eval evaluates f at state x given input u and returns the result.
+// It returns error if f fails to be evaluated.
+func Eval(f Func, x, u mat.Vector) (*mat.VecDense, error) {
+	y, err := f(Consts(mat.Col(nil, 0, x)), u)
+	if err != nil {
+		return nil, err
+	}
+
+	out := mat.NewVecDense(len(y), nil)
+	for i := range y {
+		out.SetVec(i, y[i].Re)
+	}
+
+	return out, nil
+}
+
+// Jacobian calculates Jacobian of f with respect to state x given input u and returns it.
+// The Jacobian is exact and it is calculated using a single evaluation of f.
+// It returns error if f fails to be evaluated or if it returns no values.
+func Jacobian(f Func, x, u mat.Vector) (*mat.Dense, error) {
+	y, err := f(Vars(mat.Col(nil, 0, x)), u)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(y) == 0 {
+		return nil, fmt.Errorf("invalid function output length: %d", len(y))
+	}
+
+	n := x.Len()
+	jac := mat.NewDense(len(y), n, nil)
+	for i := range y {
+		for j := 0; j < n; j++ {
+			jac.Set(i, j, y[i].Deriv(j))
+		}
+	}
+
+	return jac, nil
+}
+
+// Model is a nonlinear discrete model of a dynamical system whose propagation and observation
+// functions are written in dual numbers. It implements filter.Model and filter.Linearizer
+// so the nonlinear filters and smoothers use its exact Jacobians instead of finite differences.
+type Model struct {
+	// F propagates state x to the next step given input u
+	F Func
+	// H observes system output given state x and input u
+	H Func
+	// nx is state dimension
+	nx int
+	// nu is input dimension
+	nu int
+	// ny is output dimension
+	ny int
+}
+
+// NewModel creates new model with propagation function f, observation function h
+// and model dimensions nx, nu and ny and returns it.
+// It returns error if either f or h is nil or if the dimensions are invalid.
+func NewModel(f, h Func, nx, nu, ny int) (*Model, error) {
+	if f == nil || h == nil {
+		return nil, fmt.Errorf("invalid model functions")
+	}
+
+	if nx <= 0 || nu < 0 || ny <= 0 {
+		return nil, fmt.Errorf("invalid model dimensions: [%d x %d x %d]", nx, nu, ny)
+	}
+
+	return &Model{
+		F:  f,
+		H:  h,
+		nx: nx,
+		nu: nu,
+		ny: ny,
+	}, nil
+}
+
+// Propagate propagates internal state x to the next step given an input vector u and a disturbance input wd.
+// wd is added to the propagated state if it has the same length as the state.
+// It returns error if either x or u have invalid dimensions or if the state fails to be propagated.
+func (m *Model) Propagate(x, u, wd mat.Vector) (mat.Vector, error) {
+	if err := m.checkDims(x, u); err != nil {
+		return nil, err
+	}
+
+	xNext, err := Eval(m.F, x, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propagate state: %v", err)
+	}
+
+	if xNext.Len() != m.nx {
+		return nil, fmt.Errorf("invalid propagated state length: %d", xNext.Len())
+	}
+
+	if wd != nil && wd.Len() == m.nx {
+		xNext.AddVec(xNext, wd)
+	}
+
+	return xNext, nil
+}
+
+// Observe observes external state of the system given internal state x and input u.
+// wn is added to the output as a noise vector.
+// It returns error if either x or u have invalid dimensions or if the output fails to be calculated.
+func (m *Model) Observe(x, u, wn mat.Vector) (mat.Vector, error) {
+	if err := m.checkDims(x, u); err != nil {
+		return nil, err
+	}
+
+	y, err := Eval(m.H, x, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to observe system output: %v", err)
+	}
+
+	if y.Len() != m.ny {
+		return nil, fmt.Errorf("invalid output vector length: %d", y.Len())
+	}
+
+	if wn != nil && wn.Len() == m.ny {
+		y.AddVec(y, wn)
+	}
+
+	return y, nil
+}
+
+// StateJacobian returns Jacobian of the system propagation with respect to state x given input u.
+// It returns error if either x or u have invalid dimensions or if the Jacobian fails to be calculated.
+func (m *Model) StateJacobian(x, u mat.Vector) (mat.Matrix, error) {
+	if err := m.checkDims(x, u); err != nil {
+		return nil, err
+	}
+
+	jac, err := Jacobian(m.F, x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	return jac, nil
+}
+
+// OutputJacobian returns Jacobian of the system observation with respect to state x given input u.
+// It returns error if either x or u have invalid dimensions or if the Jacobian fails to be calculated.
+func (m *Model) OutputJacobian(x, u mat.Vector) (mat.Matrix, error) {
+	if err := m.checkDims(x, u); err != nil {
+		return nil, err
+	}
+
+	jac, err := Jacobian(m.H, x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	return jac, nil
+}
+
+// SystemDims returns internal state length (nx), input vector length (nu),
+// external/observable/output state length (ny) and disturbance vector length (nz).
+func (m *Model) SystemDims() (nx, nu, ny, nz int) {
+	return m.nx, m.nu, m.ny, 0
+}
+
+// checkDims checks if state x and input u have valid dimensions
+func (m *Model) checkDims(x, u mat.Vector) error {
+	if u != nil && u.Len() != m.nu {
+		return fmt.Errorf("invalid input vector")
+	}
+
+	if x.Len() != m.nx {
+		return fmt.Errorf("invalid state vector")
+	}
+
+	return nil
+}
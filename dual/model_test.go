@@ -0,0 +1,153 @@
+package dual
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/diff/fd"
+	"gonum.org/v1/gonum/mat"
+)
+
+// pendulum propagates a discretised pendulum state
+func pendulum(x []Dual, u mat.Vector) ([]Dual, error) {
+	dt := 0.1
+	theta, omega := x[0], x[1]
+
+	return []Dual{
+		theta.Add(omega.Scale(dt)),
+		omega.Sub(Sin(theta).Scale(9.81 * dt)),
+	}, nil
+}
+
+// position observes horizontal position of the pendulum
+func position(x []Dual, u mat.Vector) ([]Dual, error) {
+	return []Dual{Sin(x[0])}, nil
+}
+
+func TestJacobian(t *testing.T) {
+	assert := assert.New(t)
+
+	x := mat.NewVecDense(2, []float64{0.3, -0.2})
+
+	jac, err := Jacobian(pendulum, x, nil)
+	assert.NoError(err)
+	exp := mat.NewDense(2, 2, []float64{
+		1.0, 0.1,
+		-9.81 * 0.1 * math.Cos(0.3), 1.0,
+	})
+	assert.True(mat.EqualApprox(exp, jac, 1e-12))
+
+	y, err := Eval(pendulum, x, nil)
+	assert.NoError(err)
+	assert.InDelta(0.3-0.02, y.AtVec(0), 1e-12)
+
+	// function errors
+	fail := func(x []Dual, u mat.Vector) ([]Dual, error) {
+		return nil, fmt.Errorf("failed")
+	}
+	jac, err = Jacobian(fail, x, nil)
+	assert.Nil(jac)
+	assert.Error(err)
+
+	// empty function output
+	empty := func(x []Dual, u mat.Vector) ([]Dual, error) {
+		return nil, nil
+	}
+	jac, err = Jacobian(empty, x, nil)
+	assert.Nil(jac)
+	assert.Error(err)
+}
+
+func TestModel(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := NewModel(pendulum, position, 2, 0, 1)
+	assert.NotNil(m)
+	assert.NoError(err)
+
+	nx, nu, ny, nz := m.SystemDims()
+	assert.Equal(2, nx)
+	assert.Equal(0, nu)
+	assert.Equal(1, ny)
+	assert.Equal(0, nz)
+
+	x := mat.NewVecDense(2, []float64{0.3, -0.2})
+	wd := mat.NewVecDense(2, []float64{1.0, 1.0})
+
+	xNext, err := m.Propagate(x, nil, wd)
+	assert.NoError(err)
+	assert.InDelta(1.28, xNext.AtVec(0), 1e-12)
+
+	y, err := m.Observe(x, nil, nil)
+	assert.NoError(err)
+	assert.InDelta(math.Sin(0.3), y.AtVec(0), 1e-12)
+
+	f, err := m.StateJacobian(x, nil)
+	assert.NoError(err)
+	assert.InDelta(-9.81*0.1*math.Cos(0.3), f.At(1, 0), 1e-12)
+
+	h, err := m.OutputJacobian(x, nil)
+	assert.NoError(err)
+	assert.InDelta(math.Cos(0.3), h.At(0, 0), 1e-12)
+
+	// invalid state vector
+	_x := mat.NewVecDense(3, nil)
+	xNext, err = m.Propagate(_x, nil, nil)
+	assert.Nil(xNext)
+	assert.Error(err)
+
+	f, err = m.StateJacobian(_x, nil)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(1, nil)
+	y, err = m.Observe(x, _u, nil)
+	assert.Nil(y)
+	assert.Error(err)
+
+	// invalid model
+	m, err = NewModel(nil, position, 2, 0, 1)
+	assert.Nil(m)
+	assert.Error(err)
+
+	m, err = NewModel(pendulum, position, 0, 0, 1)
+	assert.Nil(m)
+	assert.Error(err)
+
+	// invalid output length
+	m, err = NewModel(pendulum, position, 2, 0, 2)
+	assert.NoError(err)
+	y, err = m.Observe(x, nil, nil)
+	assert.Nil(y)
+	assert.Error(err)
+}
+
+func BenchmarkJacobianDual(b *testing.B) {
+	x := mat.NewVecDense(2, []float64{0.3, -0.2})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Jacobian(pendulum, x, nil); err != nil {
+			b.Fatalf("failed to calculate Jacobian: %v", err)
+		}
+	}
+}
+
+func BenchmarkJacobianFD(b *testing.B) {
+	x := mat.NewVecDense(2, []float64{0.3, -0.2})
+	f := func(y, x []float64) {
+		v, _ := Eval(pendulum, mat.NewVecDense(len(x), x), nil)
+		copy(y, v.RawVector().Data)
+	}
+	jac := mat.NewDense(2, 2, nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fd.Jacobian(jac, f, x.RawVector().Data, &fd.JacobianSettings{
+			Formula: fd.Central,
+		})
+	}
+}
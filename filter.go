@@ -44,6 +44,24 @@ type Model interface {
 	SystemDims() (nx, nu, ny, nz int)
 }
 
+// Linearizer provides exact Jacobians of a dynamical system model.
+// Nonlinear filters and smoothers use them instead of finite difference approximations if the model implements it.
+type Linearizer interface {
+	// StateJacobian returns Jacobian of the system propagation with respect to state x given input u
+	StateJacobian(x, u mat.Vector) (F mat.Matrix, err error)
+	// OutputJacobian returns Jacobian of the system observation with respect to state x given input u
+	OutputJacobian(x, u mat.Vector) (H mat.Matrix, err error)
+}
+
+// HessianProvider provides exact Hessians of a dynamical system model.
+// Second order nonlinear filters use them instead of finite difference approximations if the model implements it.
+type HessianProvider interface {
+	// StateHessians returns Hessians of the individual system propagation elements with respect to state x given input u
+	StateHessians(x, u mat.Vector) (F []mat.Symmetric, err error)
+	// OutputHessians returns Hessians of the individual system observation elements with respect to state x given input u
+	OutputHessians(x, u mat.Vector) (H []mat.Symmetric, err error)
+}
+
 // Smoother is a filter smoother
 type Smoother interface {
 	// Smooth implements filter smoothing and returns new estimates
@@ -63,7 +81,36 @@ type DiscreteModel interface {
 	OutputMatrix() (C mat.Matrix)
 	// FeedForwardMatrix returns observation control matrix
 	FeedForwardMatrix() (D mat.Matrix)
-	// TODO DisturbanceMatrix
+	// Disturber provides state disturbance matrix
+	Disturber
+}
+
+// Disturber provides disturbance matrix which maps disturbance input onto system state
+type Disturber interface {
+	// DisturbanceMatrix returns state disturbance matrix
+	DisturbanceMatrix() (E mat.Matrix)
+}
+
+// TimeVaryingModel is a dynamical system whose state is driven by
+// propagation and observation dynamics matrices which change at every time step k
+type TimeVaryingModel interface {
+	// SystemDims returns the dimension of state vector, input vector,
+	// output (measurements, written as y) vector and disturbance vector.
+	SystemDims() (nx, nu, ny, nz int)
+	// PropagateAt propagates internal state x of the system from step k to the next step.
+	PropagateAt(k int, x, u, z mat.Vector) (mat.Vector, error)
+	// ObserveAt observes external state of the system at step k.
+	ObserveAt(k int, x, u, wn mat.Vector) (y mat.Vector, err error)
+	// SystemMatrixAt returns state propagation matrix at step k
+	SystemMatrixAt(k int) (A mat.Matrix)
+	// ControlMatrixAt returns state propagation control matrix at step k
+	ControlMatrixAt(k int) (B mat.Matrix)
+	// OutputMatrixAt returns observation matrix at step k
+	OutputMatrixAt(k int) (C mat.Matrix)
+	// FeedForwardMatrixAt returns observation control matrix at step k
+	FeedForwardMatrixAt(k int) (D mat.Matrix)
+	// DisturbanceMatrixAt returns state disturbance matrix at step k
+	DisturbanceMatrixAt(k int) (E mat.Matrix)
 }
 
 // InitCond is initial state condition of the filter
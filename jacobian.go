@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"fmt"
+	"sync"
+
+	"gonum.org/v1/gonum/diff/fd"
+	"gonum.org/v1/gonum/mat"
+)
+
+// JacFunc returns function which evaluates system function y = f(x) given input u.
+// Its Jacobian is calculated using finite differences.
+type JacFunc func(u mat.Vector) func(y, x []float64)
+
+// FDJacobian calculates finite difference Jacobians of system model functions.
+// Finite difference routines don't allow the evaluated functions to fail, so FDJacobian
+// records the first error returned by the model and returns it once the Jacobian has been calculated.
+// FDJacobian is safe for concurrent use.
+type FDJacobian struct {
+	mu  sync.Mutex
+	err error
+}
+
+// set stores err unless an error has already been stored
+func (j *FDJacobian) set(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err == nil {
+		j.err = err
+	}
+}
+
+// take returns stored error and resets it
+func (j *FDJacobian) take() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	err := j.err
+	j.err = nil
+
+	return err
+}
+
+// PropagateFunc returns JacFunc which propagates state of model m without disturbance.
+// Propagation errors are recorded by j.
+func (j *FDJacobian) PropagateFunc(m Model) JacFunc {
+	nx, _, _, _ := m.SystemDims()
+
+	return func(u mat.Vector) func([]float64, []float64) {
+		z := mat.NewVecDense(nx, nil)
+
+		return func(xOut, xNow []float64) {
+			x := mat.NewVecDense(len(xNow), xNow)
+			xNext, err := m.Propagate(x, u, z)
+			if err != nil {
+				j.set(err)
+				return
+			}
+
+			for i := 0; i < len(xOut); i++ {
+				xOut[i] = xNext.At(i, 0)
+			}
+		}
+	}
+}
+
+// ObserveFunc returns JacFunc which observes output of model m without measurement noise.
+// Observation errors are recorded by j.
+func (j *FDJacobian) ObserveFunc(m Model) JacFunc {
+	_, _, ny, _ := m.SystemDims()
+
+	return func(u mat.Vector) func([]float64, []float64) {
+		wn := mat.NewVecDense(ny, nil)
+
+		return func(y, xNow []float64) {
+			x := mat.NewVecDense(len(xNow), xNow)
+			yNext, err := m.Observe(x, u, wn)
+			if err != nil {
+				j.set(err)
+				return
+			}
+
+			for i := 0; i < len(y); i++ {
+				y[i] = yNext.At(i, 0)
+			}
+		}
+	}
+}
+
+// StateJacobian calculates propagation Jacobian of model m at state x given input u and stores it in dst.
+// It uses exact Jacobian if m implements Linearizer, otherwise it uses central finite differences of fn.
+// It returns error if the Jacobian fails to be calculated or if its dimensions don't match dst.
+func (j *FDJacobian) StateJacobian(dst *mat.Dense, m Model, fn JacFunc, x, u mat.Vector) error {
+	if l, ok := m.(Linearizer); ok {
+		f, err := l.StateJacobian(x, u)
+		if err != nil {
+			return err
+		}
+
+		return copyJacobian(dst, f)
+	}
+
+	return j.jacobian(dst, fn(u), x)
+}
+
+// OutputJacobian calculates observation Jacobian of model m at state x given input u and stores it in dst.
+// It uses exact Jacobian if m implements Linearizer, otherwise it uses central finite differences of fn.
+// It returns error if the Jacobian fails to be calculated or if its dimensions don't match dst.
+func (j *FDJacobian) OutputJacobian(dst *mat.Dense, m Model, fn JacFunc, x, u mat.Vector) error {
+	if l, ok := m.(Linearizer); ok {
+		h, err := l.OutputJacobian(x, u)
+		if err != nil {
+			return err
+		}
+
+		return copyJacobian(dst, h)
+	}
+
+	return j.jacobian(dst, fn(u), x)
+}
+
+// jacobian calculates Jacobian of fn at x using central finite differences and stores it in dst.
+// It returns the first error recorded while evaluating fn.
+func (j *FDJacobian) jacobian(dst *mat.Dense, fn func(y, x []float64), x mat.Vector) error {
+	fd.Jacobian(dst, fn, mat.Col(nil, 0, x), &fd.JacobianSettings{
+		Formula:    fd.Central,
+		Concurrent: true,
+	})
+
+	return j.take()
+}
+
+// copyJacobian copies Jacobian src into dst.
+// It returns error if src dimensions are not the same as dst dimensions.
+func copyJacobian(dst *mat.Dense, src mat.Matrix) error {
+	rows, cols := dst.Dims()
+	if r, c := src.Dims(); r != rows || c != cols {
+		return fmt.Errorf("invalid Jacobian dimensions: [%d x %d]", r, c)
+	}
+
+	dst.Copy(src)
+
+	return nil
+}
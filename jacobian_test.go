@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+// quadModel propagates x to [x0^2, x0*x1] and observes x0+x1.
+// It fails to propagate and observe states whose first element is negative.
+type quadModel struct{}
+
+func (m *quadModel) Propagate(x, u, z mat.Vector) (mat.Vector, error) {
+	if x.AtVec(0) < 0 {
+		return nil, fmt.Errorf("invalid state")
+	}
+	return mat.NewVecDense(2, []float64{x.AtVec(0) * x.AtVec(0), x.AtVec(0) * x.AtVec(1)}), nil
+}
+
+func (m *quadModel) Observe(x, u, wn mat.Vector) (mat.Vector, error) {
+	if x.AtVec(0) < 0 {
+		return nil, fmt.Errorf("invalid state")
+	}
+	return mat.NewVecDense(1, []float64{x.AtVec(0) + x.AtVec(1)}), nil
+}
+
+func (m *quadModel) SystemDims() (nx, nu, ny, nz int) {
+	return 2, 0, 1, 0
+}
+
+// linQuadModel provides exact Jacobians of quadModel
+type linQuadModel struct {
+	quadModel
+	// bad makes the Jacobian dimensions invalid
+	bad bool
+}
+
+func (m *linQuadModel) StateJacobian(x, u mat.Vector) (mat.Matrix, error) {
+	if m.bad {
+		return mat.NewDense(3, 3, nil), nil
+	}
+	return mat.NewDense(2, 2, []float64{2 * x.AtVec(0), 0, x.AtVec(1), x.AtVec(0)}), nil
+}
+
+func (m *linQuadModel) OutputJacobian(x, u mat.Vector) (mat.Matrix, error) {
+	if m.bad {
+		return nil, fmt.Errorf("no Jacobian")
+	}
+	return mat.NewDense(1, 2, []float64{1, 1}), nil
+}
+
+func TestFDJacobian(t *testing.T) {
+	assert := assert.New(t)
+
+	x := mat.NewVecDense(2, []float64{2.0, 3.0})
+	expF := mat.NewDense(2, 2, []float64{4.0, 0.0, 3.0, 2.0})
+	expH := mat.NewDense(1, 2, []float64{1.0, 1.0})
+
+	f := mat.NewDense(2, 2, nil)
+	h := mat.NewDense(1, 2, nil)
+
+	// finite difference and exact Jacobians
+	for _, m := range []Model{&quadModel{}, &linQuadModel{}} {
+		jac := &FDJacobian{}
+
+		err := jac.StateJacobian(f, m, jac.PropagateFunc(m), x, nil)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(expF, f, 1e-6))
+
+		err = jac.OutputJacobian(h, m, jac.ObserveFunc(m), x, nil)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(expH, h, 1e-6))
+	}
+
+	// model errors are returned rather than panicking
+	m := &quadModel{}
+	jac := &FDJacobian{}
+	_x := mat.NewVecDense(2, []float64{0.0, 3.0})
+	err := jac.StateJacobian(f, m, jac.PropagateFunc(m), _x, nil)
+	assert.Error(err)
+	err = jac.OutputJacobian(h, m, jac.ObserveFunc(m), _x, nil)
+	assert.Error(err)
+
+	// errors are reset after they are returned
+	err = jac.StateJacobian(f, m, jac.PropagateFunc(m), x, nil)
+	assert.NoError(err)
+
+	// invalid exact Jacobians
+	lm := &linQuadModel{bad: true}
+	err = jac.StateJacobian(f, lm, jac.PropagateFunc(lm), x, nil)
+	assert.Error(err)
+	err = jac.OutputJacobian(h, lm, jac.ObserveFunc(lm), x, nil)
+	assert.Error(err)
+}
@@ -0,0 +1,77 @@
+package ckf
+
+import (
+	"fmt"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman/qkf"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+	"gonum.org/v1/gonum/mat"
+)
+
+// CubaturePoints represents CKF cubature points
+type CubaturePoints struct {
+	// X stores cubature points in its columns
+	X *mat.Dense
+	// W is the weight of every cubature point
+	W float64
+}
+
+// CKF is third degree spherical-radial Cubature Kalman Filter.
+// It uses 2n equally weighted cubature points where n is the state dimension.
+// Unlike UKF it has no tuning parameters and all its weights are positive
+// so the predicted covariances are always positive semi-definite.
+// State and output noise are assumed to be additive.
+type CKF struct {
+	*qkf.QKF
+}
+
+// New creates new CKF and returns it.
+// It accepts the following parameters:
+// - m:      dynamical system model
+// - init:   initial condition of the filter
+// - q:      state a.k.a. process noise
+// - r:      output a.k.a. measurement noise
+// It returns error if either of the following conditions is met:
+// - invalid model is given: model dimensions must be positive integers
+// - invalid state or output noise is given: noise covariance must either be nil or match the model dimensions
+func New(m filter.Model, init filter.InitCond, q, r filter.Noise) (*CKF, error) {
+	nx, _, _, _ := m.SystemDims()
+
+	// cubature points of standard normal distribution
+	unit, err := sigma.Cubature(nx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate cubature points: %v", err)
+	}
+
+	f, err := qkf.New(m, init, q, r, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CKF{QKF: f}, nil
+}
+
+// GenCubaturePoints generates CKF cubature points around x using the CKF covariance and returns them.
+// It returns error if it fails to generate new cubature points due to covariance Cholesky factorization failure.
+func (k *CKF) GenCubaturePoints(x mat.Vector) (*CubaturePoints, error) {
+	pts, err := k.GenPoints(x)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CubaturePoints{
+		X: pts.X,
+		W: pts.Wm[0],
+	}, nil
+}
+
+// CubaturePoints returns cubature points generated around the predicted state in the last Update.
+func (k *CKF) CubaturePoints() *CubaturePoints {
+	pts := k.Points()
+
+	return &CubaturePoints{
+		X: pts.X,
+		W: pts.Wm[0],
+	}
+}
@@ -0,0 +1,204 @@
+package ckf
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/kalman/kf"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+type invalidModel struct {
+	filter.Model
+}
+
+func (m *invalidModel) SystemDims() (nx, nu, ny, nz int) {
+	return -10, 0, 8, 0
+}
+
+var (
+	okModel  *sim.BaseModel
+	badModel *invalidModel
+	ic       *sim.InitCond
+	q        filter.Noise
+	r        filter.Noise
+	u        *mat.VecDense
+	z        *mat.VecDense
+)
+
+func setup() {
+	u = mat.NewVecDense(1, []float64{-1.0})
+	z = mat.NewVecDense(1, []float64{-1.5})
+
+	// initial condition
+	initState := mat.NewVecDense(2, []float64{1.0, 3.0})
+	initCov := mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25})
+	ic = sim.NewInitCond(initState, initCov)
+
+	// state and output noise
+	q, _ = noise.NewGaussian([]float64{0, 0}, initCov)
+	r, _ = noise.NewGaussian([]float64{0}, mat.NewSymDense(1, []float64{0.25}))
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	B := mat.NewDense(2, 1, []float64{0.5, 1.0})
+	C := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	D := mat.NewDense(1, 1, []float64{0.0})
+
+	okModel = &sim.BaseModel{A: A, B: B, C: C, D: D}
+	badModel = &invalidModel{okModel}
+}
+
+func TestMain(m *testing.M) {
+	// set up tests
+	setup()
+	// run the tests
+	retCode := m.Run()
+	// call with result of m.Run()
+	os.Exit(retCode)
+}
+
+func TestCKFNew(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	var _ kalman.Kalman = f
+
+	// invalid model: incorrect dimensions
+	f, err = New(badModel, ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid state noise dimension
+	_q, _ := noise.NewZero(20)
+	f, err = New(okModel, ic, _q, r)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid output noise dimension
+	_r, _ := noise.NewZero(20)
+	f, err = New(okModel, ic, q, _r)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// zero [state and output] noise
+	f, err = New(okModel, ic, nil, nil)
+	assert.NotNil(f)
+	assert.NoError(err)
+}
+
+func TestCKFGenCubaturePoints(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	cov := mat.NewSymDense(2, []float64{0.5, 0.1, 0.1, 0.3})
+	assert.NoError(f.SetCov(cov))
+
+	x := mat.VecDenseCopyOf(ic.State())
+	cp, err := f.GenCubaturePoints(x)
+	assert.NotNil(cp)
+	assert.NoError(err)
+
+	rows, cols := cp.X.Dims()
+	assert.Equal(2, rows)
+	assert.Equal(4, cols)
+	assert.Equal(0.25, cp.W)
+
+	// cubature points match the mean and covariance
+	w := []float64{cp.W, cp.W, cp.W, cp.W}
+	m := sigma.Mean(cp.X, w)
+	assert.True(mat.EqualApprox(x, m, 1e-12))
+	assert.True(mat.EqualApprox(cov, sigma.CrossCov(cp.X, m, cp.X, m, w), 1e-12))
+
+	// covariance which is not positive definite
+	assert.NoError(f.SetCov(mat.NewSymDense(2, []float64{1, 2, 2, 1})))
+	cp, err = f.GenCubaturePoints(x)
+	assert.Nil(cp)
+	assert.Error(err)
+}
+
+func TestCKFUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	cp := f.CubaturePoints()
+	_, cols := cp.X.Dims()
+	assert.Equal(4, cols)
+
+	// missing measurement
+	for _, _z := range []mat.Vector{nil, mat.NewVecDense(1, []float64{math.NaN()})} {
+		xm := mat.VecDenseCopyOf(x)
+		est, err = f.Update(xm, u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.True(mat.Equal(x, est.Val()))
+	}
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestCKFLinear(t *testing.T) {
+	assert := assert.New(t)
+
+	// cubature rule is exact for linear models so CKF matches KF
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	lkf, err := kf.New(okModel, ic, q, r)
+	assert.NotNil(lkf)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	xkf := mat.VecDenseCopyOf(ic.State())
+	for i := 0; i < 5; i++ {
+		est, err := f.Predict(x, u)
+		assert.NoError(err)
+		estKF, err := lkf.Predict(xkf, u)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(estKF.Cov(), est.Cov(), 1e-9))
+
+		x = mat.VecDenseCopyOf(est.Val())
+		xkf = mat.VecDenseCopyOf(estKF.Val())
+
+		est, err = f.Update(x, u, z)
+		assert.NoError(err)
+		estKF, err = lkf.Update(xkf, u, z)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(estKF.Cov(), est.Cov(), 1e-9))
+		assert.True(mat.EqualApprox(lkf.Gain(), f.Gain(), 1e-9))
+
+		x = mat.VecDenseCopyOf(est.Val())
+		xkf = mat.VecDenseCopyOf(estKF.Val())
+	}
+}
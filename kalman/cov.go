@@ -0,0 +1,127 @@
+package kalman
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// CovUpdate is Kalman filter covariance update strategy
+type CovUpdate int
+
+const (
+	// Joseph computes the corrected covariance in Joseph form: (I-K*H)*P*(I-K*H)' + K*R*K'.
+	// It's valid for any gain K and it's the default strategy.
+	Joseph CovUpdate = iota
+	// Simple computes the corrected covariance in simple form: (I-K*H)*P.
+	// It's the cheapest strategy, but it's only valid for optimal gain K.
+	Simple
+	// Stabilized computes the corrected covariance in simple form which is then
+	// symmetrized and projected onto the cone of positive semi-definite matrices.
+	Stabilized
+)
+
+// String implements the Stringer interface.
+func (c CovUpdate) String() string {
+	switch c {
+	case Joseph:
+		return "Joseph"
+	case Simple:
+		return "Simple"
+	case Stabilized:
+		return "Stabilized"
+	default:
+		return fmt.Sprintf("CovUpdate(%d)", int(c))
+	}
+}
+
+// UpdateCov computes corrected covariance from predicted covariance p, Kalman gain k,
+// observation matrix h and measurement noise covariance r using the update strategy c and returns it.
+// r can be nil if there is no measurement noise.
+// It returns error if unsupported update strategy is given or if the covariance fails to be projected.
+func UpdateCov(c CovUpdate, p mat.Symmetric, k, h mat.Matrix, r mat.Symmetric) (*mat.SymDense, error) {
+	n := p.SymmetricDim()
+
+	eye := mat.NewDiagDense(n, nil)
+	for i := 0; i < n; i++ {
+		eye.SetDiag(i, 1.0)
+	}
+	a := &mat.Dense{}
+	// K*H
+	a.Mul(k, h)
+	// eye - K*H
+	a.Sub(eye, a)
+
+	ap := &mat.Dense{}
+	ap.Mul(a, p)
+
+	pCorr := &mat.Dense{}
+	switch c {
+	case Joseph:
+		pCorr.Mul(ap, a.T())
+		// K*R*K'
+		if r != nil && r.SymmetricDim() > 0 {
+			kr := &mat.Dense{}
+			kr.Mul(k, r)
+			krk := &mat.Dense{}
+			krk.Mul(kr, k.T())
+			pCorr.Add(pCorr, krk)
+		}
+	case Simple, Stabilized:
+		pCorr.CloneFrom(ap)
+	default:
+		return nil, fmt.Errorf("unsupported covariance update: %v", c)
+	}
+
+	cov := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			if c == Simple {
+				cov.SetSym(i, j, pCorr.At(i, j))
+				continue
+			}
+			cov.SetSym(i, j, 0.5*(pCorr.At(i, j)+pCorr.At(j, i)))
+		}
+	}
+
+	if c == Stabilized {
+		return projectPSD(cov)
+	}
+
+	return cov, nil
+}
+
+// projectPSD projects symmetric matrix a onto the cone of positive semi-definite matrices
+// by clipping its negative eigenvalues to zero and returns it.
+// It returns error if a fails to be factorized.
+func projectPSD(a *mat.SymDense) (*mat.SymDense, error) {
+	var eig mat.EigenSym
+	if ok := eig.Factorize(a, true); !ok {
+		return nil, fmt.Errorf("eigen decomposition failed")
+	}
+
+	vals := eig.Values(nil)
+	clip := false
+	for _, val := range vals {
+		if val < 0 {
+			clip = true
+			break
+		}
+	}
+
+	if !clip {
+		return a, nil
+	}
+
+	vecs := &mat.Dense{}
+	eig.VectorsTo(vecs)
+
+	psd := mat.NewSymDense(a.SymmetricDim(), nil)
+	for i, val := range vals {
+		if val > 0 {
+			psd.SymRankOne(psd, val, vecs.ColView(i))
+		}
+	}
+
+	return psd, nil
+}
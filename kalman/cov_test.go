@@ -0,0 +1,93 @@
+package kalman
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestCovUpdateString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Joseph", Joseph.String())
+	assert.Equal("Simple", Simple.String())
+	assert.Equal("Stabilized", Stabilized.String())
+	assert.Equal("CovUpdate(10)", CovUpdate(10).String())
+}
+
+func TestUpdateCov(t *testing.T) {
+	assert := assert.New(t)
+
+	p := mat.NewSymDense(2, []float64{1.0, 0.5, 0.5, 2.0})
+	h := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	r := mat.NewSymDense(1, []float64{0.25})
+
+	// optimal gain: K = P*H'*(H*P*H' + R)^-1
+	k := mat.NewDense(2, 1, []float64{1.0 / 1.25, 0.5 / 1.25})
+	// expected covariance: P - K*H*P
+	exp := mat.NewSymDense(2, []float64{0.2, 0.1, 0.1, 1.8})
+
+	testCases := []struct {
+		c CovUpdate
+		r mat.Symmetric
+	}{
+		{Joseph, r},
+		{Simple, r},
+		{Stabilized, r},
+	}
+
+	for _, tc := range testCases {
+		cov, err := UpdateCov(tc.c, p, k, h, tc.r)
+		assert.NoError(err, tc.c.String())
+		assert.True(mat.EqualApprox(exp, cov, 1e-12), tc.c.String())
+	}
+
+	// no measurement noise: optimal gain is K = P*H'*(H*P*H')^-1
+	k = mat.NewDense(2, 1, []float64{1.0, 0.5})
+	exp = mat.NewSymDense(2, []float64{0.0, 0.0, 0.0, 1.75})
+
+	for _, c := range []CovUpdate{Joseph, Simple, Stabilized} {
+		cov, err := UpdateCov(c, p, k, h, nil)
+		assert.NoError(err, c.String())
+		assert.True(mat.EqualApprox(exp, cov, 1e-12), c.String())
+	}
+
+	// empty noise covariance is treated as no measurement noise
+	cov, err := UpdateCov(Joseph, p, k, h, &mat.SymDense{})
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(exp, cov, 1e-12))
+
+	// unsupported update strategy
+	cov, err = UpdateCov(CovUpdate(10), p, k, h, r)
+	assert.Nil(cov)
+	assert.Error(err)
+}
+
+func TestUpdateCovStabilized(t *testing.T) {
+	assert := assert.New(t)
+
+	p := mat.NewSymDense(2, []float64{1.0, 0.0, 0.0, 1.0})
+	h := mat.NewDense(1, 2, []float64{1.0, 0.0})
+
+	// suboptimal gain makes simple form indefinite
+	k := mat.NewDense(2, 1, []float64{2.0, 0.0})
+
+	cov, err := UpdateCov(Simple, p, k, h, nil)
+	assert.NoError(err)
+	assert.Equal(-1.0, cov.At(0, 0))
+
+	cov, err = UpdateCov(Stabilized, p, k, h, nil)
+	assert.NoError(err)
+
+	var eig mat.EigenSym
+	assert.True(eig.Factorize(cov, false))
+	for _, val := range eig.Values(nil) {
+		assert.True(val >= 0.0)
+	}
+
+	// Joseph form is valid for any gain
+	cov, err = UpdateCov(Joseph, p, k, h, nil)
+	assert.NoError(err)
+	assert.Equal(1.0, cov.At(0, 0))
+}
@@ -5,13 +5,13 @@ import (
 
 	filter "github.com/milosgajdos/go-estimate"
 	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
 	"github.com/milosgajdos/go-estimate/noise"
-	"gonum.org/v1/gonum/diff/fd"
 	"gonum.org/v1/gonum/mat"
 )
 
 // JacFunc defines jacobian function to calculate Jacobian matrix
-type JacFunc func(u mat.Vector) func(y, x []float64)
+type JacFunc = filter.JacFunc
 
 // EKF is Extended Kalman Filter
 type EKF struct {
@@ -21,11 +21,13 @@ type EKF struct {
 	q filter.Noise
 	// r is output noise a.k.a. measurement noise
 	r filter.Noise
-	// FJacFn is propagation Jacobian function
+	// FJacFn is propagation Jacobian function;
+	// it is not used if the model implements filter.Linearizer
 	FJacFn JacFunc
 	// f is EKF propagation matrix
 	f *mat.Dense
-	// HJacFn is observation Jacobian function
+	// HJacFn is observation Jacobian function;
+	// it is not used if the model implements filter.Linearizer
 	HJacFn JacFunc
 	// h is EKF observation matrix
 	h *mat.Dense
@@ -37,6 +39,14 @@ type EKF struct {
 	inn *mat.VecDense
 	// k is Kalman gain
 	k *mat.Dense
+	// jac calculates Jacobians and records model errors of finite difference Jacobian functions
+	jac *filter.FDJacobian
+	// CovUpdate is covariance update strategy; Joseph form by default
+	CovUpdate kalman.CovUpdate
+	// Sequential enables sequential processing of measurement elements one at a time.
+	// It avoids inverting innovation covariance but requires diagonal measurement noise covariance.
+	// IEKF always processes the measurement as a whole.
+	Sequential bool
 }
 
 // New creates new EKF and returns it.
@@ -56,8 +66,8 @@ func New(m filter.Model, init filter.InitCond, q, r filter.Noise) (*EKF, error)
 	}
 
 	if q != nil {
-		if q.Cov().SymmetricDim() != nx {
-			return nil, fmt.Errorf("invalid state noise dimension: %d", q.Cov().SymmetricDim())
+		if _, err := filter.StateNoiseCov(m, q.Cov()); err != nil {
+			return nil, fmt.Errorf("invalid state noise: %v", err)
 		}
 	} else {
 		q, _ = noise.NewNone()
@@ -71,41 +81,13 @@ func New(m filter.Model, init filter.InitCond, q, r filter.Noise) (*EKF, error)
 		r, _ = noise.NewNone()
 	}
 
+	// finite difference Jacobians record errors returned by the model
+	jac := &filter.FDJacobian{}
+
 	// propagation Jacobian
-	fJacFn := func(u mat.Vector) func([]float64, []float64) {
-		q, _ := noise.NewZero(nx)
-
-		return func(xOut, xNow []float64) {
-			x := mat.NewVecDense(len(xNow), xNow)
-			xNext, err := m.Propagate(x, u, q.Sample())
-			if err != nil {
-				panic(err)
-			}
-
-			for i := 0; i < len(xOut); i++ {
-				xOut[i] = xNext.At(i, 0)
-			}
-		}
-	}
 	f := mat.NewDense(nx, nx, nil)
 
 	// observation Jacobian
-	hJacFn := func(u mat.Vector) func([]float64, []float64) {
-		r, _ := noise.NewZero(ny)
-
-		return func(y, xNow []float64) {
-			x := mat.NewVecDense(len(xNow), xNow)
-			// observe system output in the next step
-			yNext, err := m.Observe(x, u, r.Sample())
-			if err != nil {
-				panic(err)
-			}
-
-			for i := 0; i < len(y); i++ {
-				y[i] = yNext.At(i, 0)
-			}
-		}
-	}
 	h := mat.NewDense(ny, nx, nil)
 
 	// initialize covariance matrix to initial condition covariance
@@ -125,14 +107,15 @@ func New(m filter.Model, init filter.InitCond, q, r filter.Noise) (*EKF, error)
 		m:      m,
 		q:      q,
 		r:      r,
-		FJacFn: fJacFn,
+		FJacFn: jac.PropagateFunc(m),
 		f:      f,
-		HJacFn: hJacFn,
+		HJacFn: jac.ObserveFunc(m),
 		h:      h,
 		p:      p,
 		pNext:  pNext,
 		inn:    inn,
 		k:      k,
+		jac:    jac,
 	}, nil
 }
 
@@ -147,17 +130,20 @@ func (k *EKF) Predict(x, u mat.Vector) (filter.Estimate, error) {
 	}
 
 	// calculate propagation Jacobian matrix
-	fd.Jacobian(k.f, k.FJacFn(u), mat.Col(nil, 0, x), &fd.JacobianSettings{
-		Formula:    fd.Central,
-		Concurrent: true,
-	})
+	if err := k.jac.StateJacobian(k.f, k.m, k.FJacFn, x, u); err != nil {
+		return nil, fmt.Errorf("failed to calculate propagation Jacobian: %v", err)
+	}
 
 	cov := &mat.Dense{}
 	cov.Mul(k.f, k.p)
 	cov.Mul(cov, k.f.T())
 
 	if _, ok := k.q.(*noise.None); !ok {
-		cov.Add(cov, k.q.Cov())
+		qCov, err := filter.StateNoiseCov(k.m, k.q.Cov())
+		if err != nil {
+			return nil, fmt.Errorf("failed to map state noise: %v", err)
+		}
+		cov.Add(cov, qCov)
 	}
 
 	// update EKF covariance matrix
@@ -172,11 +158,13 @@ func (k *EKF) Predict(x, u mat.Vector) (filter.Estimate, error) {
 }
 
 // Update corrects state x using the measurement z, given control intput u and returns corrected estimate.
+// Missing measurement elements can be marked with NaN: only the observed elements are then used to correct x.
+// If the measurement is missing entirely (nil or all NaN) x is not corrected and the predicted covariance is kept.
 // It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
 func (k *EKF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
 	nx, _, ny, _ := k.m.SystemDims()
 
-	if z.Len() != ny {
+	if z != nil && z.Len() != ny {
 		return nil, fmt.Errorf("invalid measurement supplied: %v", z)
 	}
 
@@ -186,84 +174,115 @@ func (k *EKF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
 		return nil, fmt.Errorf("failed to observe system output: %v", err)
 	}
 
-	// calculate observation Jacobian matrix
-	fd.Jacobian(k.h, k.HJacFn(u), mat.Col(nil, 0, x), &fd.JacobianSettings{
-		Formula:    fd.Central,
-		Concurrent: true,
-	})
-
-	pxy := mat.NewDense(nx, ny, nil)
-	pyy := mat.NewDense(ny, ny, nil)
-
-	// P*H'
-	pxy.Mul(k.pNext, k.h.T())
+	k.inn.Zero()
+	k.k.Zero()
 
-	// Note: pxy = P * H' so we reuse the result here
-	// H*P*H'
-	pyy.Mul(k.h, pxy)
-	// no measurement noise
-	if _, ok := k.r.(*noise.None); !ok {
-		pyy.Add(pyy, k.r.Cov())
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: keep predicted covariance
+		k.p.CopySym(k.pNext)
+		return estimate.NewBaseWithCov(x, k.p)
 	}
 
-	// calculate Kalman gain
-	pyyInv := &mat.Dense{}
-	if err := pyyInv.Inverse(pyy); err != nil {
-		return nil, fmt.Errorf("failed to calculat Pyy inverse: %v", err)
+	// calculate observation Jacobian matrix
+	if err := k.jac.OutputJacobian(k.h, k.m, k.HJacFn, x, u); err != nil {
+		return nil, fmt.Errorf("failed to calculate observation Jacobian: %v", err)
 	}
-	gain := &mat.Dense{}
-	gain.Mul(pxy, pyyInv)
+
+	// observation Jacobian rows and measurement noise covariance of observed measurement elements
+	h := kalman.SelectRows(k.h, idx)
+	rCov := k.outputNoiseCov(idx)
 
 	// innovation vector
 	inn := &mat.VecDense{}
-	inn.SubVec(z, y)
+	inn.SubVec(kalman.SelectVec(z, idx), kalman.SelectVec(y, idx))
+
+	gain, inn, pCorr, err := k.correct(h, rCov, inn)
+	if err != nil {
+		return nil, err
+	}
 
 	// update state x
-	corr := &mat.Dense{}
-	corr.Mul(gain, inn)
-	x.(*mat.VecDense).AddVec(x, corr.ColView(0))
-
-	// Joseph form update
-	eye := mat.NewDiagDense(x.Len(), nil)
-	for i := 0; i < x.Len(); i++ {
-		eye.SetDiag(i, 1.0)
+	corr := &mat.VecDense{}
+	corr.MulVec(gain, inn)
+	x.(*mat.VecDense).AddVec(x, corr)
+
+	// update EKF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
+	}
+	// update EKF covariance matrix
+	k.p.CopySym(pCorr)
+
+	return estimate.NewBaseWithCov(x, k.p)
+}
+
+// correct calculates Kalman gain and corrected covariance from the predicted covariance, observation Jacobian h,
+// measurement noise covariance r and innovation vector inn and returns them along with the innovation vector.
+// It returns error if it fails to calculate Kalman gain or the corrected covariance.
+func (k *EKF) correct(h mat.Matrix, r mat.Symmetric, inn *mat.VecDense) (*mat.Dense, *mat.VecDense, *mat.SymDense, error) {
+	if k.Sequential {
+		gain, pCorr, err := kalman.SequentialUpdate(k.CovUpdate, k.pNext, h, r)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("sequential update failed: %v", err)
+		}
+
+		return gain, inn, pCorr, nil
+	}
+
+	// calculate Kalman gain
+	gain, err := k.gain(h, r)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	a := &mat.Dense{}
-	// K*H
-	a.Mul(gain, k.h)
-	// eye - K*H
-	a.Sub(eye, a)
-
-	// K*R*K'
-	pkrk := &mat.Dense{}
-	// if there is some output noise
-	if _, ok := k.r.(*noise.None); !ok {
-		kr := &mat.Dense{}
-		kr.Mul(gain, k.r.Cov())
-		pkrk.Mul(kr, gain.T())
+
+	pCorr, err := kalman.UpdateCov(k.CovUpdate, k.pNext, gain, h, r)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update covariance: %v", err)
 	}
 
-	ap := &mat.Dense{}
-	ap.Mul(a, k.pNext)
-	apa := &mat.Dense{}
-	apa.Mul(ap, a.T())
+	return gain, inn, pCorr, nil
+}
 
-	pCorr := &mat.Dense{}
-	if !pkrk.IsEmpty() {
-		pCorr.Add(apa, pkrk)
+// outputNoiseCov returns measurement noise covariance of the observed measurement elements idx.
+// It returns nil if there is no measurement noise.
+func (k *EKF) outputNoiseCov(idx []int) mat.Symmetric {
+	if _, ok := k.r.(*noise.None); ok {
+		return nil
 	}
 
-	// update EKF innovation vector
-	k.inn.CopyVec(inn)
-	k.k.Copy(gain)
-	// update EKF covariance matrix
-	for i := 0; i < nx; i++ {
-		for j := i; j < nx; j++ {
-			k.p.SetSym(i, j, pCorr.At(i, j))
-		}
+	return kalman.SelectSym(k.r.Cov(), idx)
+}
+
+// gain calculates Kalman gain from the predicted covariance, observation Jacobian h
+// and measurement noise covariance r and returns it. r can be nil if there is no measurement noise.
+// It returns error if it fails to calculate the gain.
+func (k *EKF) gain(h mat.Matrix, r mat.Symmetric) (*mat.Dense, error) {
+	pxy := &mat.Dense{}
+	pyy := &mat.Dense{}
+
+	// P*H'
+	pxy.Mul(k.pNext, h.T())
+
+	// Note: pxy = P * H' so we reuse the result here
+	// H*P*H'
+	pyy.Mul(h, pxy)
+	// add measurement noise
+	if r != nil {
+		pyy.Add(pyy, r)
 	}
 
-	return estimate.NewBaseWithCov(x, k.p)
+	// calculate Kalman gain
+	pyyInv := &mat.Dense{}
+	if err := pyyInv.Inverse(pyy); err != nil {
+		return nil, fmt.Errorf("failed to calculat Pyy inverse: %v", err)
+	}
+	gain := &mat.Dense{}
+	gain.Mul(pxy, pyyInv)
+
+	return gain, nil
 }
 
 // Run runs one step of EKF for given state x, input u and measurement z.
@@ -322,10 +341,18 @@ func (k *EKF) SetCov(cov mat.Symmetric) error {
 	return nil
 }
 
-// Gain returns Kalman gain
+// Gain returns Kalman gain.
 func (k *EKF) Gain() mat.Matrix {
 	gain := &mat.Dense{}
 	gain.CloneFrom(k.k)
 
 	return gain
 }
+
+// Innov returns innovation vector of the last update.
+func (k *EKF) Innov() mat.Vector {
+	inn := mat.NewVecDense(k.inn.Len(), nil)
+	inn.CopyVec(k.inn)
+
+	return inn
+}
@@ -0,0 +1,166 @@
+package ekf
+
+import (
+	"fmt"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"gonum.org/v1/gonum/mat"
+)
+
+// EKF2 is second order (Gaussian) Extended Kalman Filter.
+// It extends EKF with Hessian based bias and covariance correction terms
+// which account for the curvature of the model propagation and observation.
+type EKF2 struct {
+	// ekf.EKF is extended Kalman filter
+	*EKF
+}
+
+// NewSecondOrder creates new second order EKF and returns it.
+// It accepts the following parameters:
+// - m:  dynamical system model
+// - ic: initial condition of the filter
+// - q:  state a.k.a. process noise
+// - r:  output a.k.a. measurement noise
+// Model Hessians are calculated using finite differences unless the model implements filter.HessianProvider.
+// It returns error if either of the following conditions is met:
+// - invalid model is given: model dimensions must be positive integers
+// - invalid state or output noise is given: noise covariance must either be nil or match the model dimensions
+func NewSecondOrder(m filter.Model, ic filter.InitCond, q, r filter.Noise) (*EKF2, error) {
+	f, err := New(m, ic, q, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EKF2{
+		EKF: f,
+	}, nil
+}
+
+// Predict calculates the next system state given the state x and input u and returns its estimate.
+// Propagated state is corrected by the second order bias 0.5*tr(Fi*P) of every state element
+// and predicted covariance by the second order term 0.5*tr(Fi*P*Fj*P) where Fi is Hessian of i-th state element.
+// It returns error if it fails to propagate x or to calculate the model Jacobian or Hessians.
+func (k *EKF2) Predict(x, u mat.Vector) (filter.Estimate, error) {
+	hess, err := k.stateHessians(x, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate propagation Hessians: %v", err)
+	}
+
+	bias, cov := secondOrder(hess, k.p)
+
+	pred, err := k.EKF.Predict(x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	xNext := mat.VecDenseCopyOf(pred.Val())
+	xNext.AddVec(xNext, bias)
+
+	k.pNext.AddSym(k.pNext, cov)
+
+	return estimate.NewBaseWithCov(xNext, k.pNext)
+}
+
+// Update corrects state x using the measurement z, given control intput u and returns corrected estimate.
+// Predicted output is corrected by the second order bias 0.5*tr(Hi*P) of every output element
+// and innovation covariance by the second order term 0.5*tr(Hi*P*Hj*P) where Hi is Hessian of i-th output element.
+// Missing measurement elements can be marked with NaN: only the observed elements are then used to correct x.
+// If the measurement is missing entirely (nil or all NaN) x is not corrected and the predicted covariance is kept.
+// Measurement is always processed as a whole: Sequential is ignored.
+// It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
+func (k *EKF2) Update(x, u, z mat.Vector) (filter.Estimate, error) {
+	nx, _, ny, _ := k.m.SystemDims()
+
+	if z != nil && z.Len() != ny {
+		return nil, fmt.Errorf("invalid measurement supplied: %v", z)
+	}
+
+	// observe system output in the next step
+	y, err := k.m.Observe(x, u, k.r.Sample())
+	if err != nil {
+		return nil, fmt.Errorf("failed to observe system output: %v", err)
+	}
+
+	k.inn.Zero()
+	k.k.Zero()
+
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: keep predicted covariance
+		k.p.CopySym(k.pNext)
+		return estimate.NewBaseWithCov(x, k.p)
+	}
+
+	// calculate observation Jacobian matrix
+	if err := k.jac.OutputJacobian(k.h, k.m, k.HJacFn, x, u); err != nil {
+		return nil, fmt.Errorf("failed to calculate observation Jacobian: %v", err)
+	}
+
+	hess, err := k.outputHessians(x, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate observation Hessians: %v", err)
+	}
+
+	bias, cov := secondOrder(hess, k.pNext)
+
+	// observation Jacobian rows of observed measurement elements
+	h := kalman.SelectRows(k.h, idx)
+
+	// second order covariance term acts as additional measurement noise
+	rCov := kalman.SelectSym(cov, idx)
+	if r := k.outputNoiseCov(idx); r != nil {
+		rCov.AddSym(rCov, r)
+	}
+
+	// innovation vector
+	yBias := kalman.SelectVec(y, idx)
+	yBias.AddVec(yBias, kalman.SelectVec(bias, idx))
+	inn := &mat.VecDense{}
+	inn.SubVec(kalman.SelectVec(z, idx), yBias)
+
+	// calculate Kalman gain
+	gain, err := k.gain(h, rCov)
+	if err != nil {
+		return nil, err
+	}
+
+	pCorr, err := kalman.UpdateCov(k.CovUpdate, k.pNext, gain, h, rCov)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update covariance: %v", err)
+	}
+
+	// update state x
+	corr := &mat.VecDense{}
+	corr.MulVec(gain, inn)
+	x.(*mat.VecDense).AddVec(x, corr)
+
+	// update EKF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
+	}
+	// update EKF covariance matrix
+	k.p.CopySym(pCorr)
+
+	return estimate.NewBaseWithCov(x, k.p)
+}
+
+// Run runs one step of EKF2 for given state x, input u and measurement z.
+// It corrects system state x using measurement z and returns new system estimate.
+// It returns error if it either fails to propagate or correct state x.
+func (k *EKF2) Run(x, u, z mat.Vector) (filter.Estimate, error) {
+	pred, err := k.Predict(x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	est, err := k.Update(pred.Val(), u, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return est, nil
+}
@@ -0,0 +1,198 @@
+package ekf
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+// quadModel observes squared distance of the state from the origin
+type quadModel struct {
+	*sim.BaseModel
+}
+
+func (m *quadModel) Observe(x, u, wn mat.Vector) (mat.Vector, error) {
+	y := mat.NewVecDense(1, []float64{mat.Dot(x, x)})
+	if wn != nil && wn.Len() == 1 {
+		y.AddVec(y, wn)
+	}
+	return y, nil
+}
+
+// hessModel provides exact Hessians of quadModel
+type hessModel struct {
+	*quadModel
+	err error
+}
+
+func (m *hessModel) StateHessians(x, u mat.Vector) ([]mat.Symmetric, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []mat.Symmetric{mat.NewSymDense(2, nil), mat.NewSymDense(2, nil)}, nil
+}
+
+func (m *hessModel) OutputHessians(x, u mat.Vector) ([]mat.Symmetric, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []mat.Symmetric{mat.NewSymDense(2, []float64{2, 0, 0, 2})}, nil
+}
+
+func TestNewSecondOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := NewSecondOrder(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// invalid model: incorrect dimensions
+	f, err = NewSecondOrder(badModel, ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+}
+
+func TestEKF2Linear(t *testing.T) {
+	assert := assert.New(t)
+
+	// second order terms of linear model vanish
+	f, err := NewSecondOrder(okModel, ic, nil, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	f1, err := New(okModel, ic, nil, r)
+	assert.NotNil(f1)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Predict(x, u)
+	assert.NoError(err)
+	est1, err := f1.Predict(x, u)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(est1.Val(), est.Val(), 1e-6))
+	assert.True(mat.EqualApprox(est1.Cov(), est.Cov(), 1e-6))
+
+	est, err = f.Run(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Predict(x, _u)
+	assert.Nil(est)
+	assert.Error(err)
+
+	est, err = f.Run(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestEKF2Update(t *testing.T) {
+	assert := assert.New(t)
+
+	z := mat.NewVecDense(1, []float64{11.0})
+	p := ic.Cov()
+
+	qm := &quadModel{okModel}
+
+	// finite difference and exact Hessians
+	for _, m := range []filter.Model{qm, &hessModel{quadModel: qm}} {
+		f, err := NewSecondOrder(m, ic, nil, nil)
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		f.pNext.CopySym(p)
+
+		x := mat.VecDenseCopyOf(ic.State())
+		est, err := f.Update(x, nil, z)
+		assert.NotNil(est)
+		assert.NoError(err)
+
+		// innovation includes second order bias tr(P)
+		bias := mat.Trace(p)
+		assert.InDelta(11.0-10.0-bias, f.Innov().AtVec(0), 1e-4)
+
+		// innovation covariance includes second order term 2*tr(P*P)
+		h := mat.NewDense(1, 2, []float64{2, 6})
+		hp := &mat.Dense{}
+		hp.Mul(h, p)
+		s := mat.Dot(hp.RowView(0), h.RowView(0)) + 2*(p.At(0, 0)*p.At(0, 0)+p.At(1, 1)*p.At(1, 1))
+		assert.InDelta(2*p.At(0, 0)/s, f.Gain().At(0, 0), 1e-4)
+
+		// missing measurement
+		f.pNext.CopySym(p)
+		for _, _z := range []mat.Vector{nil, mat.NewVecDense(1, []float64{math.NaN()})} {
+			xm := mat.VecDenseCopyOf(ic.State())
+			est, err = f.Update(xm, nil, _z)
+			assert.NotNil(est)
+			assert.NoError(err)
+			assert.True(mat.Equal(ic.State(), est.Val()))
+		}
+
+		// invalid measurement vector
+		_z := mat.NewVecDense(3, nil)
+		est, err = f.Update(x, nil, _z)
+		assert.Nil(est)
+		assert.Error(err)
+	}
+
+	// Hessian errors are returned
+	m := &hessModel{quadModel: qm, err: fmt.Errorf("hessian error")}
+	f, err := NewSecondOrder(m, ic, nil, nil)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Predict(x, nil)
+	assert.Nil(est)
+	assert.Error(err)
+
+	est, err = f.Update(x, nil, z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestHessians(t *testing.T) {
+	assert := assert.New(t)
+
+	// f(x) = [x0*x1^2, sin(x0)]
+	fn := func(x mat.Vector) (mat.Vector, error) {
+		return mat.NewVecDense(2, []float64{
+			x.AtVec(0) * x.AtVec(1) * x.AtVec(1),
+			math.Sin(x.AtVec(0)),
+		}), nil
+	}
+
+	x := mat.NewVecDense(2, []float64{0.5, 2.0})
+	hess, err := hessians(fn, x, 2)
+	assert.NoError(err)
+	assert.Len(hess, 2)
+
+	exp := mat.NewSymDense(2, []float64{0, 4, 4, 1})
+	assert.True(mat.EqualApprox(exp, hess[0], 1e-5))
+	exp = mat.NewSymDense(2, []float64{-math.Sin(0.5), 0, 0, 0})
+	assert.True(mat.EqualApprox(exp, hess[1], 1e-5))
+
+	// invalid function output length
+	hess, err = hessians(fn, x, 3)
+	assert.Nil(hess)
+	assert.Error(err)
+
+	// function errors
+	hess, err = hessians(func(x mat.Vector) (mat.Vector, error) {
+		return nil, fmt.Errorf("failed")
+	}, x, 2)
+	assert.Nil(hess)
+	assert.Error(err)
+
+	// second order terms
+	p := mat.NewSymDense(2, []float64{1, 0.5, 0.5, 2})
+	bias, cov := secondOrder([]mat.Symmetric{exp}, p)
+	assert.InDelta(-0.5*math.Sin(0.5), bias.AtVec(0), 1e-12)
+	assert.InDelta(0.5*math.Sin(0.5)*math.Sin(0.5), cov.At(0, 0), 1e-12)
+}
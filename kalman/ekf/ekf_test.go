@@ -1,10 +1,12 @@
 package ekf
 
 import (
+	"math"
 	"os"
 	"testing"
 
 	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
 	"github.com/milosgajdos/go-estimate/noise"
 	"github.com/milosgajdos/go-estimate/sim"
 	"github.com/stretchr/testify/assert"
@@ -125,6 +127,15 @@ func TestEKFUpdate(t *testing.T) {
 	assert.NotNil(est)
 	assert.NoError(err)
 
+	// missing measurement
+	for _, _z := range []mat.Vector{nil, mat.NewVecDense(1, []float64{math.NaN()})} {
+		xm := mat.VecDenseCopyOf(x)
+		est, err = f.Update(xm, u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.True(mat.Equal(x, est.Val()))
+	}
+
 	// invalid input vector
 	_u := mat.NewVecDense(3, nil)
 	est, err = f.Update(x, _u, z)
@@ -138,6 +149,64 @@ func TestEKFUpdate(t *testing.T) {
 	assert.Error(err)
 }
 
+// meanNoise is Gaussian noise which always samples its mean
+type meanNoise struct {
+	*noise.Gaussian
+}
+
+func newMeanNoise(cov mat.Symmetric) *meanNoise {
+	g, _ := noise.NewGaussian(make([]float64, cov.SymmetricDim()), cov)
+
+	return &meanNoise{Gaussian: g}
+}
+
+func (n *meanNoise) Sample() mat.Vector {
+	return mat.NewVecDense(n.Cov().SymmetricDim(), n.Mean())
+}
+
+func TestEKFPartial(t *testing.T) {
+	assert := assert.New(t)
+
+	// model with two outputs and its reduction to the first output
+	C := mat.NewDense(2, 2, []float64{1.0, 0.0, 1.0, 1.0})
+	D := mat.NewDense(2, 1, []float64{0.0, 0.5})
+	m := &sim.BaseModel{A: okModel.A, B: okModel.B, C: C, D: D}
+	rm := &sim.BaseModel{A: okModel.A, B: okModel.B, C: mat.DenseCopyOf(C.Slice(0, 1, 0, 2)), D: mat.DenseCopyOf(D.Slice(0, 1, 0, 1))}
+
+	_r := newMeanNoise(mat.NewSymDense(2, []float64{0.25, 0, 0, 0.5}))
+	_rr := newMeanNoise(mat.NewSymDense(1, []float64{0.25}))
+	_z := mat.NewVecDense(2, []float64{-1.5, math.NaN()})
+
+	for _, seq := range []bool{false, true} {
+		f, err := New(m, ic, q, _r)
+		assert.NotNil(f)
+		assert.NoError(err)
+		f.Sequential = seq
+
+		fr, err := New(rm, ic, q, _rr)
+		assert.NotNil(fr)
+		assert.NoError(err)
+		fr.Sequential = seq
+
+		// partial measurement is equivalent to the measurement of the reduced model
+		est, err := f.Update(mat.VecDenseCopyOf(ic.State()), u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		estR, err := fr.Update(mat.VecDenseCopyOf(ic.State()), u, z)
+		assert.NotNil(estR)
+		assert.NoError(err)
+
+		assert.True(mat.EqualApprox(estR.Val(), est.Val(), 1e-12))
+		assert.True(mat.EqualApprox(estR.Cov(), est.Cov(), 1e-12))
+
+		// gain of the missing measurement element is zero
+		gain := f.Gain()
+		assert.True(mat.EqualApprox(fr.Gain(), gain.(*mat.Dense).Slice(0, 2, 0, 1), 1e-12))
+		assert.Equal([]float64{0, 0}, mat.Col(nil, 1, gain))
+		assert.Equal(0.0, f.Innov().AtVec(1))
+	}
+}
+
 func TestEKFRun(t *testing.T) {
 	assert := assert.New(t)
 
@@ -218,3 +287,103 @@ func TestEKFGain(t *testing.T) {
 	gain := f.Gain()
 	assert.NotNil(gain)
 }
+
+func TestEKFCovUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range []kalman.CovUpdate{kalman.Joseph, kalman.Simple, kalman.Stabilized} {
+		// with and without measurement noise
+		for _, _r := range []filter.Noise{r, nil} {
+			f, err := New(okModel, ic, q, _r)
+			assert.NotNil(f)
+			assert.NoError(err)
+
+			f.CovUpdate = c
+
+			x := mat.VecDenseCopyOf(ic.State())
+			est, err := f.Run(x, u, z)
+			assert.NotNil(est, c.String())
+			assert.NoError(err, c.String())
+
+			cov := f.Cov()
+			assert.True(cov.At(0, 0) >= 0.0, c.String())
+			assert.True(cov.At(1, 1) > 0.0, c.String())
+			assert.True(mat.Equal(cov, est.Cov()), c.String())
+		}
+	}
+
+	// all update strategies produce the same covariance for optimal gain
+	var covs []mat.Symmetric
+	for _, c := range []kalman.CovUpdate{kalman.Joseph, kalman.Simple, kalman.Stabilized} {
+		_r := r
+		f, err := New(okModel, ic, q, _r)
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		f.CovUpdate = c
+
+		x := mat.VecDenseCopyOf(ic.State())
+		_, err = f.Run(x, u, z)
+		assert.NoError(err)
+		covs = append(covs, f.Cov())
+	}
+	assert.True(mat.EqualApprox(covs[0], covs[1], 1e-9))
+	assert.True(mat.EqualApprox(covs[0], covs[2], 1e-9))
+
+	// unsupported covariance update strategy
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	f.CovUpdate = kalman.CovUpdate(10)
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Run(x, u, z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestEKFSequential(t *testing.T) {
+	assert := assert.New(t)
+
+	// model with two outputs
+	C := mat.NewDense(2, 2, []float64{1.0, 0.0, 1.0, 1.0})
+	D := mat.NewDense(2, 1, []float64{0.0, 0.5})
+	m := &sim.BaseModel{A: okModel.A, B: okModel.B, C: C, D: D}
+
+	_q := newMeanNoise(q.Cov())
+	cases := []struct {
+		m filter.Model
+		r filter.Noise
+		z mat.Vector
+	}{
+		{okModel, newMeanNoise(r.Cov()), z},
+		{m, newMeanNoise(mat.NewSymDense(2, []float64{0.25, 0, 0, 0.5})), mat.NewVecDense(2, []float64{-1.5, 2.0})},
+	}
+
+	for _, tc := range cases {
+		f, err := New(tc.m, ic, _q, tc.r)
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		s, err := New(tc.m, ic, _q, tc.r)
+		assert.NotNil(s)
+		assert.NoError(err)
+		s.Sequential = true
+
+		x := mat.VecDenseCopyOf(ic.State())
+		est, err := f.Run(x, u, tc.z)
+		assert.NotNil(est)
+		assert.NoError(err)
+
+		x = mat.VecDenseCopyOf(ic.State())
+		estS, err := s.Run(x, u, tc.z)
+		assert.NotNil(estS)
+		assert.NoError(err)
+
+		// sequential update produces the same estimate, gain and innovation as batch update
+		assert.True(mat.EqualApprox(est.Val(), estS.Val(), 1e-9))
+		assert.True(mat.EqualApprox(f.Cov(), s.Cov(), 1e-9))
+		assert.True(mat.EqualApprox(f.Gain(), s.Gain(), 1e-9))
+		assert.True(mat.EqualApprox(f.Innov(), s.Innov(), 1e-9))
+	}
+}
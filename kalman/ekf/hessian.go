@@ -0,0 +1,172 @@
+package ekf
+
+import (
+	"fmt"
+	"math"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/noise"
+	"gonum.org/v1/gonum/mat"
+)
+
+// hessStep is relative finite difference step used to approximate Hessians
+const hessStep = 1e-4
+
+// stateHessians calculates Hessians of the individual system propagation elements at state x given input u.
+// It uses exact Hessians if the model implements filter.HessianProvider, otherwise it uses finite differences.
+// It returns error if the Hessians fail to be calculated.
+func (k *EKF2) stateHessians(x, u mat.Vector) ([]mat.Symmetric, error) {
+	nx, _, _, _ := k.m.SystemDims()
+
+	if hp, ok := k.m.(filter.HessianProvider); ok {
+		hess, err := hp.StateHessians(x, u)
+		if err != nil {
+			return nil, err
+		}
+
+		return hess, checkHessians(hess, nx, nx)
+	}
+
+	q, _ := noise.NewZero(nx)
+
+	return hessians(func(x mat.Vector) (mat.Vector, error) {
+		return k.m.Propagate(x, u, q.Sample())
+	}, x, nx)
+}
+
+// outputHessians calculates Hessians of the individual system observation elements at state x given input u.
+// It uses exact Hessians if the model implements filter.HessianProvider, otherwise it uses finite differences.
+// It returns error if the Hessians fail to be calculated.
+func (k *EKF2) outputHessians(x, u mat.Vector) ([]mat.Symmetric, error) {
+	nx, _, ny, _ := k.m.SystemDims()
+
+	if hp, ok := k.m.(filter.HessianProvider); ok {
+		hess, err := hp.OutputHessians(x, u)
+		if err != nil {
+			return nil, err
+		}
+
+		return hess, checkHessians(hess, ny, nx)
+	}
+
+	r, _ := noise.NewZero(ny)
+
+	return hessians(func(x mat.Vector) (mat.Vector, error) {
+		return k.m.Observe(x, u, r.Sample())
+	}, x, ny)
+}
+
+// checkHessians checks there are n Hessians of dimension dim.
+func checkHessians(hess []mat.Symmetric, n, dim int) error {
+	if len(hess) != n {
+		return fmt.Errorf("invalid number of Hessians: %d", len(hess))
+	}
+
+	for i := range hess {
+		if hess[i].SymmetricDim() != dim {
+			return fmt.Errorf("invalid Hessian %d dimension: %d", i, hess[i].SymmetricDim())
+		}
+	}
+
+	return nil
+}
+
+// hessians approximates Hessians of the n elements of vector function fn at x using central finite differences:
+// H[j,l] = (f(x+dj+dl) - f(x+dj-dl) - f(x-dj+dl) + f(x-dj-dl)) / (4*hj*hl)
+// It returns error if fn fails to be evaluated or it returns vector of invalid length.
+func hessians(fn func(x mat.Vector) (mat.Vector, error), x mat.Vector, n int) ([]mat.Symmetric, error) {
+	nx := x.Len()
+
+	hess := make([]*mat.SymDense, n)
+	for i := range hess {
+		hess[i] = mat.NewSymDense(nx, nil)
+	}
+
+	step := make([]float64, nx)
+	for j := range step {
+		step[j] = hessStep * math.Max(1.0, math.Abs(x.AtVec(j)))
+	}
+
+	xd := mat.NewVecDense(nx, nil)
+	eval := func(j, l int, sj, sl float64) (mat.Vector, error) {
+		xd.CopyVec(x)
+		xd.SetVec(j, xd.AtVec(j)+sj*step[j])
+		xd.SetVec(l, xd.AtVec(l)+sl*step[l])
+
+		y, err := fn(xd)
+		if err != nil {
+			return nil, err
+		}
+
+		if y.Len() != n {
+			return nil, fmt.Errorf("invalid function output length: %d", y.Len())
+		}
+
+		return y, nil
+	}
+
+	for j := 0; j < nx; j++ {
+		for l := j; l < nx; l++ {
+			pp, err := eval(j, l, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			pm, err := eval(j, l, 1, -1)
+			if err != nil {
+				return nil, err
+			}
+			mp, err := eval(j, l, -1, 1)
+			if err != nil {
+				return nil, err
+			}
+			mm, err := eval(j, l, -1, -1)
+			if err != nil {
+				return nil, err
+			}
+
+			for i := 0; i < n; i++ {
+				d := pp.AtVec(i) - pm.AtVec(i) - mp.AtVec(i) + mm.AtVec(i)
+				hess[i].SetSym(j, l, d/(4*step[j]*step[l]))
+			}
+		}
+	}
+
+	out := make([]mat.Symmetric, n)
+	for i := range hess {
+		out[i] = hess[i]
+	}
+
+	return out, nil
+}
+
+// secondOrder calculates second order bias and covariance terms of Hessians hess given covariance p:
+// bias[i] = 0.5*tr(Hi*P) and cov[i,j] = 0.5*tr(Hi*P*Hj*P)
+func secondOrder(hess []mat.Symmetric, p mat.Symmetric) (*mat.VecDense, *mat.SymDense) {
+	n := len(hess)
+
+	hp := make([]*mat.Dense, n)
+	bias := mat.NewVecDense(n, nil)
+	for i := range hess {
+		hp[i] = &mat.Dense{}
+		hp[i].Mul(hess[i], p)
+		bias.SetVec(i, 0.5*mat.Trace(hp[i]))
+	}
+
+	cov := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			// tr(A*B) is the sum of the elements of A and B'
+			cov.SetSym(i, j, 0.5*mat.Sum(mulElem(hp[i], hp[j].T())))
+		}
+	}
+
+	return bias, cov
+}
+
+// mulElem returns element-wise product of a and b
+func mulElem(a, b mat.Matrix) *mat.Dense {
+	m := &mat.Dense{}
+	m.MulElem(a, b)
+
+	return m
+}
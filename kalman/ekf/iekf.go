@@ -5,8 +5,7 @@ import (
 
 	filter "github.com/milosgajdos/go-estimate"
 	"github.com/milosgajdos/go-estimate/estimate"
-	"github.com/milosgajdos/go-estimate/noise"
-	"gonum.org/v1/gonum/diff/fd"
+	"github.com/milosgajdos/go-estimate/kalman"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -47,11 +46,13 @@ func NewIter(m filter.Model, ic filter.InitCond, q, r filter.Noise, n int) (*IEK
 }
 
 // Update corrects state x using the measurement z, given control intput u and returns corrected estimate of x.
+// Missing measurement elements can be marked with NaN: only the observed elements are then used to correct x.
+// If the measurement is missing entirely (nil or all NaN) x is not corrected and the predicted covariance is kept.
 // It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
 func (k *IEKF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
 	nx, _, ny, _ := k.m.SystemDims()
 
-	if z.Len() != ny {
+	if z != nil && z.Len() != ny {
 		return nil, fmt.Errorf("invalid measurement supplied: %v", z)
 	}
 
@@ -61,89 +62,64 @@ func (k *IEKF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
 		return nil, fmt.Errorf("failed to observe system output: %v", err)
 	}
 
-	pxy := mat.NewDense(nx, ny, nil)
-	pyy := mat.NewDense(ny, ny, nil)
+	k.inn.Zero()
+	k.k.Zero()
+
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: keep predicted covariance
+		k.p.CopySym(k.pNext)
+		return estimate.NewBaseWithCov(x, k.Cov())
+	}
+
+	// measurement noise covariance of observed measurement elements
+	rCov := k.outputNoiseCov(idx)
 
 	// innovation vector
 	inn := &mat.VecDense{}
-	inn.SubVec(z, y)
+	inn.SubVec(kalman.SelectVec(z, idx), kalman.SelectVec(y, idx))
 
 	// kalman gain
-	gain := &mat.Dense{}
+	var gain *mat.Dense
 
-	// corrected covariance
-	corr := &mat.Dense{}
+	// observation Jacobian rows of observed measurement elements
+	var h *mat.Dense
+
+	// state correction
+	corr := &mat.VecDense{}
 
 	// iterate k.n number of iterations and keep updating x
 	for i := 0; i < k.n; i++ {
 		// calculate Jacobian matrix
-		fd.Jacobian(k.h, k.HJacFn(u), mat.Col(nil, 0, x), &fd.JacobianSettings{
-			Formula:    fd.Central,
-			Concurrent: true,
-		})
-
-		// P*H'
-		pxy.Mul(k.pNext, k.h.T())
-
-		// Note: pxy = P * H' so we reuse the result here
-		// H*P*H'
-		pyy.Mul(k.h, pxy)
-		// if there is any measurement noise
-		if _, ok := k.r.(*noise.None); !ok {
-			pyy.Add(pyy, k.r.Cov())
+		if err := k.jac.OutputJacobian(k.h, k.m, k.HJacFn, x, u); err != nil {
+			return nil, fmt.Errorf("failed to calculate observation Jacobian: %v", err)
 		}
+		h = kalman.SelectRows(k.h, idx)
 
 		// calculate Kalman gain
-		pyyInv := &mat.Dense{}
-		if err := pyyInv.Inverse(pyy); err != nil {
-			return nil, fmt.Errorf("failed to calculat Pyy inverse: %v", err)
+		gain, err = k.gain(h, rCov)
+		if err != nil {
+			return nil, err
 		}
-		gain.Mul(pxy, pyyInv)
 
 		// update state x
-		corr.Mul(gain, inn)
-		x.(*mat.VecDense).AddVec(x, corr.ColView(0))
+		corr.MulVec(gain, inn)
+		x.(*mat.VecDense).AddVec(x, corr)
 	}
 
-	// Joseph form update
-	eye := mat.NewDiagDense(x.Len(), nil)
-	for i := 0; i < x.Len(); i++ {
-		eye.SetDiag(i, 1.0)
-	}
-	a := &mat.Dense{}
-	// K*H
-	a.Mul(gain, k.h)
-	// eye - K*H
-	a.Sub(eye, a)
-
-	// K*R*K'
-	pkrk := &mat.Dense{}
-	// if there is some output noise
-	if _, ok := k.r.(*noise.None); !ok {
-		kr := &mat.Dense{}
-		kr.Mul(gain, k.r.Cov())
-		pkrk.Mul(kr, gain.T())
+	pCorr, err := kalman.UpdateCov(k.CovUpdate, k.pNext, gain, h, rCov)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update covariance: %v", err)
 	}
 
-	ap := &mat.Dense{}
-	ap.Mul(a, k.pNext)
-	apa := &mat.Dense{}
-	apa.Mul(ap, a.T())
-
-	pCorr := &mat.Dense{}
-	if !pkrk.IsEmpty() {
-		pCorr.Add(apa, pkrk)
+	// update EKF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
 	}
-
-	// update EKF innovation vector
-	k.inn.CopyVec(inn)
-	k.k.Copy(gain)
 	// update EKF covariance matrix
-	for i := 0; i < nx; i++ {
-		for j := i; j < nx; j++ {
-			k.p.SetSym(i, j, pCorr.At(i, j))
-		}
-	}
+	k.p.CopySym(pCorr)
 
 	return estimate.NewBaseWithCov(x, k.Cov())
 }
@@ -3,6 +3,8 @@ package ekf
 import (
 	"testing"
 
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
 	"github.com/stretchr/testify/assert"
 	"gonum.org/v1/gonum/mat"
 )
@@ -74,3 +76,57 @@ func TestIEKFRun(t *testing.T) {
 	assert.Nil(est)
 	assert.Error(err)
 }
+
+func TestIEKFCovUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range []kalman.CovUpdate{kalman.Joseph, kalman.Simple, kalman.Stabilized} {
+		// with and without measurement noise
+		for _, _r := range []filter.Noise{r, nil} {
+			f, err := NewIter(okModel, ic, q, _r, 3)
+			assert.NotNil(f)
+			assert.NoError(err)
+
+			f.CovUpdate = c
+
+			x := mat.VecDenseCopyOf(ic.State())
+			est, err := f.Run(x, u, z)
+			assert.NotNil(est, c.String())
+			assert.NoError(err, c.String())
+
+			cov := f.Cov()
+			assert.True(cov.At(0, 0) >= 0.0, c.String())
+			assert.True(cov.At(1, 1) > 0.0, c.String())
+			assert.True(mat.Equal(cov, est.Cov()), c.String())
+		}
+	}
+
+	// all update strategies produce the same covariance for optimal gain
+	var covs []mat.Symmetric
+	for _, c := range []kalman.CovUpdate{kalman.Joseph, kalman.Simple, kalman.Stabilized} {
+		_r := r
+		f, err := NewIter(okModel, ic, q, _r, 3)
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		f.CovUpdate = c
+
+		x := mat.VecDenseCopyOf(ic.State())
+		_, err = f.Run(x, u, z)
+		assert.NoError(err)
+		covs = append(covs, f.Cov())
+	}
+	assert.True(mat.EqualApprox(covs[0], covs[1], 1e-9))
+	assert.True(mat.EqualApprox(covs[0], covs[2], 1e-9))
+
+	// unsupported covariance update strategy
+	f, err := NewIter(okModel, ic, q, r, 3)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	f.CovUpdate = kalman.CovUpdate(10)
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Run(x, u, z)
+	assert.Nil(est)
+	assert.Error(err)
+}
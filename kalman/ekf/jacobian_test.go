@@ -0,0 +1,242 @@
+package ekf
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/dual"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+// linModel provides exact Jacobians of a linear model
+type linModel struct {
+	*sim.BaseModel
+	err error
+	// bad makes the state Jacobian dimensions invalid
+	bad bool
+}
+
+func (m *linModel) StateJacobian(x, u mat.Vector) (mat.Matrix, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.bad {
+		return mat.NewDense(3, 3, nil), nil
+	}
+	return m.SystemMatrix(), nil
+}
+
+func (m *linModel) OutputJacobian(x, u mat.Vector) (mat.Matrix, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.OutputMatrix(), nil
+}
+
+// gridModel fails to propagate and observe states whose first element is not an integer
+type gridModel struct {
+	*sim.BaseModel
+}
+
+func (m *gridModel) Propagate(x, u, wd mat.Vector) (mat.Vector, error) {
+	if x.AtVec(0) != math.Trunc(x.AtVec(0)) {
+		return nil, fmt.Errorf("state off grid")
+	}
+	return m.BaseModel.Propagate(x, u, wd)
+}
+
+func (m *gridModel) Observe(x, u, wn mat.Vector) (mat.Vector, error) {
+	if x.AtVec(0) != math.Trunc(x.AtVec(0)) {
+		return nil, fmt.Errorf("state off grid")
+	}
+	return m.BaseModel.Observe(x, u, wn)
+}
+
+func TestEKFLinearizer(t *testing.T) {
+	assert := assert.New(t)
+
+	fd, err := New(okModel, ic, q, r)
+	assert.NotNil(fd)
+	assert.NoError(err)
+
+	lm := &linModel{BaseModel: okModel}
+	f, err := New(lm, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	estFD, err := fd.Predict(x, u)
+	assert.NoError(err)
+	est, err := f.Predict(x, u)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(estFD.Cov(), est.Cov(), 1e-6))
+
+	estFD, err = fd.Update(estFD.Val(), u, z)
+	assert.NoError(err)
+	est, err = f.Update(est.Val(), u, z)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(estFD.Cov(), est.Cov(), 1e-6))
+
+	// iterated update
+	iter, err := NewIter(lm, ic, q, r, 5)
+	assert.NotNil(iter)
+	assert.NoError(err)
+	est, err = iter.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// Jacobian errors are returned
+	lm.err = fmt.Errorf("jacobian error")
+	est, err = f.Predict(x, u)
+	assert.Nil(est)
+	assert.Error(err)
+
+	est, err = f.Update(x, u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	est, err = iter.Update(x, u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid Jacobian dimensions
+	lm.err, lm.bad = nil, true
+	est, err = f.Predict(x, u)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+// fdModel hides exact Jacobians of the wrapped model
+type fdModel struct {
+	filter.Model
+}
+
+func TestEKFDualModel(t *testing.T) {
+	assert := assert.New(t)
+
+	// discretised pendulum with angle measurement
+	prop := func(x []dual.Dual, u mat.Vector) ([]dual.Dual, error) {
+		return []dual.Dual{
+			x[0].Add(x[1].Scale(0.1)),
+			x[1].Sub(dual.Sin(x[0]).Scale(0.981)).Add(dual.Const(u.AtVec(0)).Scale(0.1)),
+		}, nil
+	}
+	obs := func(x []dual.Dual, u mat.Vector) ([]dual.Dual, error) {
+		return []dual.Dual{dual.Sin(x[0])}, nil
+	}
+
+	m, err := dual.NewModel(prop, obs, 2, 1, 1)
+	assert.NotNil(m)
+	assert.NoError(err)
+
+	f, err := New(m, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	fd, err := New(&fdModel{m}, ic, q, r)
+	assert.NotNil(fd)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Predict(x, u)
+	assert.NoError(err)
+	estFD, err := fd.Predict(x, u)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(estFD.Cov(), est.Cov(), 1e-6))
+
+	est, err = f.Update(mat.VecDenseCopyOf(x), u, z)
+	assert.NoError(err)
+	estFD, err = fd.Update(mat.VecDenseCopyOf(x), u, z)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(estFD.Cov(), est.Cov(), 1e-6))
+}
+
+func TestEKFJacobianError(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(&gridModel{okModel}, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// model errors in finite difference Jacobian are returned rather than panicking
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Predict(x, u)
+	assert.Nil(est)
+	assert.Error(err)
+
+	est, err = f.Update(x, u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	iter, err := NewIter(&gridModel{okModel}, ic, q, r, 5)
+	assert.NotNil(iter)
+	assert.NoError(err)
+
+	est, err = iter.Update(x, u, z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func BenchmarkEKFPredictFD(b *testing.B) {
+	f, err := New(okModel, ic, q, r)
+	if err != nil {
+		b.Fatalf("failed to create EKF: %v", err)
+	}
+	x := mat.VecDenseCopyOf(ic.State())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Predict(x, u); err != nil {
+			b.Fatalf("failed to predict: %v", err)
+		}
+	}
+}
+
+func BenchmarkEKFPredictLinearizer(b *testing.B) {
+	f, err := New(&linModel{BaseModel: okModel}, ic, q, r)
+	if err != nil {
+		b.Fatalf("failed to create EKF: %v", err)
+	}
+	x := mat.VecDenseCopyOf(ic.State())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Predict(x, u); err != nil {
+			b.Fatalf("failed to predict: %v", err)
+		}
+	}
+}
+
+func BenchmarkEKFUpdateFD(b *testing.B) {
+	f, err := New(okModel, ic, q, r)
+	if err != nil {
+		b.Fatalf("failed to create EKF: %v", err)
+	}
+	x := mat.VecDenseCopyOf(ic.State())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Update(x, u, z); err != nil {
+			b.Fatalf("failed to update: %v", err)
+		}
+	}
+}
+
+func BenchmarkEKFUpdateLinearizer(b *testing.B) {
+	f, err := New(&linModel{BaseModel: okModel}, ic, q, r)
+	if err != nil {
+		b.Fatalf("failed to create EKF: %v", err)
+	}
+	x := mat.VecDenseCopyOf(ic.State())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Update(x, u, z); err != nil {
+			b.Fatalf("failed to update: %v", err)
+		}
+	}
+}
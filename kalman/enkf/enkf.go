@@ -0,0 +1,580 @@
+package enkf
+
+import (
+	"fmt"
+	"math"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Analysis is EnKF analysis scheme
+type Analysis int
+
+const (
+	// PerturbedObs is stochastic analysis scheme: every ensemble member
+	// is corrected using a measurement perturbed with a sample of output noise
+	PerturbedObs Analysis = iota
+	// ETKF is deterministic square root Ensemble Transform Kalman Filter analysis scheme:
+	// ensemble is corrected by a transform of its anomalies so no measurement perturbations are needed
+	ETKF
+)
+
+// String implements fmt.Stringer interface
+func (a Analysis) String() string {
+	switch a {
+	case PerturbedObs:
+		return "PerturbedObs"
+	case ETKF:
+		return "ETKF"
+	}
+
+	return fmt.Sprintf("Analysis(%d)", int(a))
+}
+
+// Config contains EnKF configuration parameters
+type Config struct {
+	// Analysis is ensemble analysis scheme
+	Analysis Analysis
+	// Inflation is multiplicative covariance inflation factor applied to the ensemble before analysis;
+	// values less or equal to 1 disable inflation
+	Inflation float64
+	// Localization is [nx x ny] matrix which weighs the impact of every measurement element on every state element.
+	// Its elements are typically in [0,1] and they're calculated from the distance of state and measurement
+	// elements e.g. using GaspariCohn. If nil, localization is disabled.
+	Localization mat.Matrix
+}
+
+// EnKF is Ensemble Kalman Filter.
+// It represents the state distribution by an ensemble of state vectors, just like particle filters,
+// so it never propagates the full state covariance matrix which makes it suitable for high dimensional states.
+// For more information about EnKF see:
+// https://en.wikipedia.org/wiki/Ensemble_Kalman_filter
+type EnKF struct {
+	// m is EnKF system model
+	m filter.Model
+	// q is state noise a.k.a. process noise
+	q filter.Noise
+	// r is output noise a.k.a. measurement noise
+	r filter.Noise
+	// c is EnKF configuration
+	c Config
+	// x stores ensemble members as column vectors
+	x *mat.Dense
+	// inn is innovation vector
+	inn *mat.VecDense
+	// k is Kalman gain
+	k *mat.Dense
+}
+
+// New creates new EnKF and returns it.
+// It accepts the following parameters:
+// - m:      dynamical system model
+// - init:   initial condition of the filter
+// - q:      state a.k.a. process noise
+// - r:      output a.k.a. measurement noise
+// - n:      number of ensemble members
+// - c:      filter configuration; if nil, PerturbedObs analysis without inflation and localization is used
+// It returns error if either of the following conditions is met:
+// - invalid model is given: model dimensions must be positive integers
+// - invalid state or output noise is given: noise covariance must either be nil or match the model dimensions
+// - less than two ensemble members are requested
+// - invalid configuration is given
+// - ensemble fails to be generated
+func New(m filter.Model, init filter.InitCond, q, r filter.Noise, n int, c *Config) (*EnKF, error) {
+	// size of the input and output vectors
+	nx, _, ny, _ := m.SystemDims()
+	if nx <= 0 || ny <= 0 {
+		return nil, fmt.Errorf("invalid model dimensions: [%d x %d]", nx, ny)
+	}
+
+	// sample covariance requires at least two members
+	if n < 2 {
+		return nil, fmt.Errorf("invalid ensemble size: %d", n)
+	}
+
+	if q != nil {
+		if _, err := filter.StateNoiseCov(m, q.Cov()); err != nil {
+			return nil, fmt.Errorf("invalid state noise: %v", err)
+		}
+	} else {
+		q, _ = noise.NewZero(nx)
+	}
+
+	if r != nil {
+		if r.Cov().SymmetricDim() != ny {
+			return nil, fmt.Errorf("invalid output noise dimension: %d", r.Cov().SymmetricDim())
+		}
+	} else {
+		r, _ = noise.NewZero(ny)
+	}
+
+	var conf Config
+	if c != nil {
+		conf = *c
+	}
+
+	if conf.Analysis != PerturbedObs && conf.Analysis != ETKF {
+		return nil, fmt.Errorf("invalid analysis scheme: %v", conf.Analysis)
+	}
+
+	if conf.Inflation < 0 {
+		return nil, fmt.Errorf("invalid inflation factor: %f", conf.Inflation)
+	}
+
+	if conf.Localization != nil {
+		rows, cols := conf.Localization.Dims()
+		if rows != nx || cols != ny {
+			return nil, fmt.Errorf("invalid localization matrix dimensions: [%d x %d]", rows, cols)
+		}
+	}
+
+	// draw ensemble from distribution with covariance InitCond.Cov()
+	x, err := rand.WithCovN(init.Cov(), n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ensemble: %v", err)
+	}
+
+	// center ensemble around initial state condition init.State()
+	for j := 0; j < n; j++ {
+		col := x.Slice(0, nx, j, j+1).(*mat.Dense)
+		col.Add(col, init.State())
+	}
+
+	// innovation vector
+	inn := mat.NewVecDense(ny, nil)
+
+	// kalman gain
+	k := mat.NewDense(nx, ny, nil)
+
+	return &EnKF{
+		m:   m,
+		q:   q,
+		r:   r,
+		c:   conf,
+		x:   x,
+		inn: inn,
+		k:   k,
+	}, nil
+}
+
+// Predict propagates the ensemble to the next step given the input u and returns the ensemble mean.
+// Every ensemble member is propagated with its own sample of state noise. The state x is not used.
+// It returns error if it fails to propagate the ensemble members.
+func (k *EnKF) Predict(x, u mat.Vector) (filter.Estimate, error) {
+	rows, cols := k.x.Dims()
+	xPred := mat.NewDense(rows, cols, nil)
+
+	// propagate ensemble members to the next step
+	for j := 0; j < cols; j++ {
+		xNext, err := k.m.Propagate(k.x.ColView(j), u, k.q.Sample())
+		if err != nil {
+			return nil, fmt.Errorf("ensemble member propagation failed: %v", err)
+		}
+		xPred.Slice(0, rows, j, j+1).(*mat.Dense).Copy(xNext)
+	}
+
+	// update ensemble
+	k.x.Copy(xPred)
+
+	return estimate.NewBase(k.mean(k.x))
+}
+
+// Update corrects the ensemble using the measurement z given control intput u and returns the ensemble mean.
+// The state x is not used: the corrected state is the mean of the corrected ensemble.
+// Missing measurement elements can be marked with NaN: only the observed elements are then used to correct the ensemble.
+// If the measurement is missing entirely (nil or all NaN) the ensemble is not corrected.
+// It returns error if either invalid measurement was supplied or if the ensemble fails to be corrected.
+func (k *EnKF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
+	nx, _, ny, _ := k.m.SystemDims()
+
+	if z != nil && z.Len() != ny {
+		return nil, fmt.Errorf("invalid measurement supplied: %v", z)
+	}
+
+	k.inn.Zero()
+	k.k.Zero()
+
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: ensemble remains unchanged
+		return estimate.NewBase(k.mean(k.x))
+	}
+
+	_, n := k.x.Dims()
+
+	xMean := k.mean(k.x)
+	xa := anomalies(k.x, xMean)
+
+	// inflate ensemble around its mean
+	if k.c.Inflation > 1 {
+		xa.Scale(math.Sqrt(k.c.Inflation), xa)
+	}
+
+	// observe ensemble outputs
+	y := mat.NewDense(ny, n, nil)
+	for j := 0; j < n; j++ {
+		member := &mat.VecDense{}
+		member.AddVec(xMean, xa.ColView(j))
+		yj, err := k.m.Observe(member, u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("ensemble member observation failed: %v", err)
+		}
+		y.Slice(0, ny, j, j+1).(*mat.Dense).Copy(yj)
+	}
+
+	// keep only the outputs of observed measurement elements
+	loc := k.c.Localization
+	if len(idx) < ny {
+		y = kalman.SelectRows(y, idx)
+		if loc != nil {
+			loc = kalman.SelectRows(loc.T(), idx).T()
+		}
+	}
+
+	yMean := k.mean(y)
+	ya := anomalies(y, yMean)
+
+	rCov := kalman.SelectSym(k.r.Cov(), idx)
+
+	// innovation vector
+	inn := &mat.VecDense{}
+	inn.SubVec(kalman.SelectVec(z, idx), yMean)
+
+	var xCorr, gain *mat.Dense
+	var err error
+
+	switch k.c.Analysis {
+	case ETKF:
+		xCorr, gain, err = etkf(xa, ya, rCov, inn, loc)
+	default:
+		xCorr, gain, err = k.perturbedObs(xa, ya, y, rCov, z, idx, loc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to correct ensemble: %v", err)
+	}
+
+	// corrected ensemble: mean plus corrected anomalies
+	for j := 0; j < n; j++ {
+		col := k.x.Slice(0, nx, j, j+1).(*mat.Dense)
+		col.Add(xMean, xCorr.ColView(j))
+	}
+
+	// update EnKF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
+	}
+
+	return estimate.NewBase(k.mean(k.x))
+}
+
+// perturbedObs corrects ensemble anomalies xa using perturbed measurements and returns them with the Kalman gain.
+// It returns error if the output covariance can't be inverted.
+func (k *EnKF) perturbedObs(xa, ya, y *mat.Dense, rCov mat.Symmetric, z mat.Vector, idx []int, loc mat.Matrix) (*mat.Dense, *mat.Dense, error) {
+	_, n := xa.Dims()
+	m := len(idx)
+
+	// ensemble cross covariance of state and output
+	pxy := &mat.Dense{}
+	pxy.Mul(xa, ya.T())
+	pxy.Scale(1/float64(n-1), pxy)
+	if loc != nil {
+		pxy.MulElem(pxy, loc)
+	}
+
+	// ensemble output covariance
+	pyy := mat.NewSymDense(m, nil)
+	pyy.SymOuterK(1/float64(n-1), ya)
+	pyy.AddSym(pyy, rCov)
+
+	// K = Pxy * Pyy^-1 i.e. Pyy * K' = Pxy'
+	var chol mat.Cholesky
+	if ok := chol.Factorize(pyy); !ok {
+		return nil, nil, fmt.Errorf("output covariance is not positive definite")
+	}
+
+	gainT := &mat.Dense{}
+	if err := chol.SolveTo(gainT, pxy.T()); err != nil {
+		return nil, nil, err
+	}
+	gain := mat.DenseCopyOf(gainT.T())
+
+	// every member is corrected using its own perturbed measurement
+	xCorr := mat.DenseCopyOf(xa)
+	zObs := kalman.SelectVec(z, idx)
+	d := mat.NewVecDense(m, nil)
+	corr := mat.NewVecDense(xCorr.RawMatrix().Rows, nil)
+	for j := 0; j < n; j++ {
+		d.SubVec(zObs, y.ColView(j))
+		d.AddVec(d, kalman.SelectVec(k.r.Sample(), idx))
+		corr.MulVec(gain, d)
+		col := xCorr.ColView(j).(*mat.VecDense)
+		col.AddVec(col, corr)
+	}
+
+	return xCorr, gain, nil
+}
+
+// etkf corrects ensemble anomalies xa using ensemble transform and returns them with the Kalman gain.
+// If localization loc is given, every state element is corrected separately using local analysis
+// in which the measurement precision is weighed by the corresponding row of loc.
+// It returns error if the transform fails to be calculated.
+func etkf(xa, ya *mat.Dense, rCov mat.Symmetric, inn *mat.VecDense, loc mat.Matrix) (*mat.Dense, *mat.Dense, error) {
+	nx, n := xa.Dims()
+	m := inn.Len()
+
+	// measurement precision
+	var chol mat.Cholesky
+	if ok := chol.Factorize(rCov); !ok {
+		return nil, nil, fmt.Errorf("output noise covariance is not positive definite")
+	}
+	rInv := mat.NewSymDense(m, nil)
+	if err := chol.InverseTo(rInv); err != nil {
+		return nil, nil, err
+	}
+
+	if loc == nil {
+		t, g, err := transform(ya, rInv, inn)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		xCorr := &mat.Dense{}
+		xCorr.Mul(xa, t)
+		gain := &mat.Dense{}
+		gain.Mul(xa, g)
+
+		return xCorr, gain, nil
+	}
+
+	xCorr := mat.NewDense(nx, n, nil)
+	gain := mat.NewDense(nx, m, nil)
+	rLoc := mat.NewSymDense(m, nil)
+
+	for i := 0; i < nx; i++ {
+		// localized measurement precision: D*R^-1*D where D = diag(sqrt(loc[i,:]))
+		for a := 0; a < m; a++ {
+			for b := a; b < m; b++ {
+				rLoc.SetSym(a, b, math.Sqrt(loc.At(i, a)*loc.At(i, b))*rInv.At(a, b))
+			}
+		}
+
+		t, g, err := transform(ya, rLoc, inn)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		xCorr.Slice(i, i+1, 0, n).(*mat.Dense).Mul(xa.Slice(i, i+1, 0, n), t)
+		gain.Slice(i, i+1, 0, m).(*mat.Dense).Mul(xa.Slice(i, i+1, 0, n), g)
+	}
+
+	return xCorr, gain, nil
+}
+
+// transform calculates ETKF ensemble transform T = W + w*1' where W is the symmetric square root of
+// (n-1)*Pa, Pa = [(n-1)*I + Ya'*R^-1*Ya]^-1 is the analysis covariance in the ensemble space and
+// w = Pa*Ya'*R^-1*inn is the mean analysis weight. It returns the transform and the gain factor Pa*Ya'*R^-1.
+// It returns error if Pa fails to be calculated.
+func transform(ya *mat.Dense, rInv mat.Symmetric, inn *mat.VecDense) (*mat.Dense, *mat.Dense, error) {
+	_, n := ya.Dims()
+
+	// C = Ya'*R^-1
+	c := &mat.Dense{}
+	c.Mul(ya.T(), rInv)
+
+	// Pa^-1 = (n-1)*I + C*Ya
+	cy := &mat.Dense{}
+	cy.Mul(c, ya)
+	paInv := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			paInv.SetSym(i, j, 0.5*(cy.At(i, j)+cy.At(j, i)))
+		}
+		paInv.SetSym(i, i, paInv.At(i, i)+float64(n-1))
+	}
+
+	var eig mat.EigenSym
+	if ok := eig.Factorize(paInv, true); !ok {
+		return nil, nil, fmt.Errorf("eigen decomposition failed")
+	}
+
+	vals := eig.Values(nil)
+	inv := make([]float64, n)
+	sqrtInv := make([]float64, n)
+	for i := range vals {
+		if vals[i] <= 0 {
+			return nil, nil, fmt.Errorf("analysis covariance is not positive definite")
+		}
+		inv[i] = 1 / vals[i]
+		sqrtInv[i] = math.Sqrt(float64(n-1) / vals[i])
+	}
+
+	vecs := &mat.Dense{}
+	eig.VectorsTo(vecs)
+
+	// Pa = V*D^-1*V'
+	pa := &mat.Dense{}
+	pa.Mul(vecs, mat.NewDiagDense(n, inv))
+	pa.Mul(pa, vecs.T())
+
+	// W = V*sqrt((n-1)*D^-1)*V'
+	t := &mat.Dense{}
+	t.Mul(vecs, mat.NewDiagDense(n, sqrtInv))
+	t.Mul(t, vecs.T())
+
+	g := &mat.Dense{}
+	g.Mul(pa, c)
+
+	w := &mat.VecDense{}
+	w.MulVec(g, inn)
+
+	// T = W + w*1'
+	for j := 0; j < n; j++ {
+		col := t.ColView(j).(*mat.VecDense)
+		col.AddVec(col, w)
+	}
+
+	return t, g, nil
+}
+
+// mean calculates the mean of the columns of x and returns it
+func (k *EnKF) mean(x *mat.Dense) *mat.VecDense {
+	rows, cols := x.Dims()
+
+	xMean := mat.NewVecDense(rows, nil)
+	for j := 0; j < cols; j++ {
+		xMean.AddVec(xMean, x.ColView(j))
+	}
+	xMean.ScaleVec(1/float64(cols), xMean)
+
+	return xMean
+}
+
+// anomalies returns deviations of the columns of x from their mean xMean
+func anomalies(x *mat.Dense, xMean mat.Vector) *mat.Dense {
+	rows, cols := x.Dims()
+
+	a := mat.DenseCopyOf(x)
+	for j := 0; j < cols; j++ {
+		col := a.Slice(0, rows, j, j+1).(*mat.Dense)
+		col.Sub(col, xMean)
+	}
+
+	return a
+}
+
+// Run runs one step of EnKF for given state x, input u and measurement z.
+// It corrects the ensemble using measurement z and returns new system estimate.
+// It returns error if it either fails to propagate or correct the ensemble.
+func (k *EnKF) Run(x, u, z mat.Vector) (filter.Estimate, error) {
+	pred, err := k.Predict(x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	est, err := k.Update(pred.Val(), u, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return est, nil
+}
+
+// Model returns EnKF model
+func (k *EnKF) Model() filter.Model {
+	return k.m
+}
+
+// StateNoise retruns state noise
+func (k *EnKF) StateNoise() filter.Noise {
+	return k.q
+}
+
+// OutputNoise retruns output noise
+func (k *EnKF) OutputNoise() filter.Noise {
+	return k.r
+}
+
+// Ensemble returns EnKF ensemble members stored in matrix columns
+func (k *EnKF) Ensemble() mat.Matrix {
+	x := &mat.Dense{}
+	x.CloneFrom(k.x)
+
+	return x
+}
+
+// Cov returns EnKF covariance i.e. the sample covariance of the ensemble.
+// Note that for high dimensional states the covariance matrix can be very large.
+func (k *EnKF) Cov() mat.Symmetric {
+	rows, n := k.x.Dims()
+
+	cov := mat.NewSymDense(rows, nil)
+	cov.SymOuterK(1/float64(n-1), anomalies(k.x, k.mean(k.x)))
+
+	return cov
+}
+
+// SetCov replaces the ensemble with new members drawn from distribution with covariance cov.
+// The mean of the ensemble is preserved.
+// It returns error if either cov is nil, its dimensions are not the same as EnKF covariance dimensions
+// or if the ensemble fails to be generated.
+func (k *EnKF) SetCov(cov mat.Symmetric) error {
+	if cov == nil {
+		return fmt.Errorf("invalid covariance matrix: %v", cov)
+	}
+
+	rows, n := k.x.Dims()
+	if cov.SymmetricDim() != rows {
+		return fmt.Errorf("invalid covariance matrix dims: [%d x %d]", cov.SymmetricDim(), cov.SymmetricDim())
+	}
+
+	x, err := rand.WithCovN(cov, n)
+	if err != nil {
+		return fmt.Errorf("failed to generate ensemble: %v", err)
+	}
+
+	// new members are centered so the ensemble mean is preserved
+	x = anomalies(x, k.mean(x))
+	xMean := k.mean(k.x)
+	for j := 0; j < n; j++ {
+		col := x.Slice(0, rows, j, j+1).(*mat.Dense)
+		col.Add(col, xMean)
+	}
+
+	k.x.Copy(x)
+
+	return nil
+}
+
+// Gain returns Kalman gain
+func (k *EnKF) Gain() mat.Matrix {
+	gain := &mat.Dense{}
+	gain.CloneFrom(k.k)
+
+	return gain
+}
+
+// GaspariCohn returns Gaspari-Cohn localization weight of two elements at distance d.
+// The weight is a compactly supported fifth order piecewise rational function which
+// approximates a Gaussian with half-width c and which drops to zero at distance 2c.
+func GaspariCohn(d, c float64) float64 {
+	if c <= 0 {
+		return 0
+	}
+
+	r := math.Abs(d) / c
+	switch {
+	case r <= 1:
+		return -0.25*math.Pow(r, 5) + 0.5*math.Pow(r, 4) + 0.625*math.Pow(r, 3) - 5.0/3.0*r*r + 1
+	case r <= 2:
+		return math.Pow(r, 5)/12 - 0.5*math.Pow(r, 4) + 0.625*math.Pow(r, 3) + 5.0/3.0*r*r - 5*r + 4 - 2.0/(3*r)
+	}
+
+	return 0
+}
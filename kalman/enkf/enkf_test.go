@@ -0,0 +1,357 @@
+package enkf
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+type invalidModel struct {
+	filter.Model
+}
+
+func (m *invalidModel) SystemDims() (nx, nu, ny, nz int) {
+	return -10, 0, 8, 0
+}
+
+// EnKF can replace the other Kalman filters
+var _ kalman.Kalman = (*EnKF)(nil)
+
+var (
+	okModel  *sim.BaseModel
+	badModel *invalidModel
+	ic       *sim.InitCond
+	q        filter.Noise
+	r        filter.Noise
+	u        *mat.VecDense
+	z        *mat.VecDense
+)
+
+func setup() {
+	u = mat.NewVecDense(1, []float64{-1.0})
+	z = mat.NewVecDense(2, []float64{-1.5, 2.5})
+
+	// initial condition
+	initState := mat.NewVecDense(2, []float64{1.0, 3.0})
+	initCov := mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25})
+	ic = sim.NewInitCond(initState, initCov)
+
+	// state and output noise
+	q, _ = noise.NewGaussian([]float64{0, 0}, initCov)
+	r, _ = noise.NewGaussian([]float64{0, 0}, mat.NewSymDense(2, []float64{0.25, 0.05, 0.05, 0.5}))
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	B := mat.NewDense(2, 1, []float64{0.5, 1.0})
+	C := mat.NewDense(2, 2, []float64{1.0, 0.0, 0.5, 1.0})
+	D := mat.NewDense(2, 1, []float64{0.0, 0.0})
+
+	okModel = &sim.BaseModel{A: A, B: B, C: C, D: D}
+	badModel = &invalidModel{okModel}
+}
+
+func TestMain(m *testing.M) {
+	// set up tests
+	setup()
+	// run the tests
+	retCode := m.Run()
+	// call with result of m.Run()
+	os.Exit(retCode)
+}
+
+// kalmanUpdate returns Kalman filter gain, corrected mean and covariance of prior with mean x and covariance p
+func kalmanUpdate(x mat.Vector, p mat.Symmetric, z mat.Vector) (*mat.Dense, *mat.VecDense, *mat.Dense) {
+	h := okModel.OutputMatrix()
+
+	pht := &mat.Dense{}
+	pht.Mul(p, h.T())
+	s := &mat.Dense{}
+	s.Mul(h, pht)
+	s.Add(s, r.Cov())
+	sInv := &mat.Dense{}
+	_ = sInv.Inverse(s)
+	gain := &mat.Dense{}
+	gain.Mul(pht, sInv)
+
+	inn := &mat.VecDense{}
+	inn.MulVec(h, x)
+	inn.SubVec(z, inn)
+	xCorr := &mat.VecDense{}
+	xCorr.MulVec(gain, inn)
+	xCorr.AddVec(x, xCorr)
+
+	kh := &mat.Dense{}
+	kh.Mul(gain, h)
+	pCorr := &mat.Dense{}
+	pCorr.Mul(kh, p)
+	pCorr.Sub(p, pCorr)
+
+	return gain, xCorr, pCorr
+}
+
+func TestEnKFNew(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, 10, nil)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// no noise
+	f, err = New(okModel, ic, nil, nil, 10, &Config{Analysis: ETKF})
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// invalid model: incorrect dimensions
+	f, err = New(badModel, ic, q, r, 10, nil)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid ensemble size
+	f, err = New(okModel, ic, q, r, 1, nil)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid output noise
+	_r, _ := noise.NewZero(3)
+	f, err = New(okModel, ic, q, _r, 10, nil)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid configuration
+	confs := []*Config{
+		{Analysis: Analysis(10)},
+		{Inflation: -1.0},
+		{Localization: mat.NewDense(3, 2, nil)},
+	}
+	for _, c := range confs {
+		f, err = New(okModel, ic, q, r, 10, c)
+		assert.Nil(f)
+		assert.Error(err)
+	}
+}
+
+func TestEnKFPredict(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, nil, r, 10, nil)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x0 := f.Ensemble()
+
+	est, err := f.Predict(nil, u)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// without state noise every member is propagated by the model
+	exp := &mat.Dense{}
+	exp.Mul(okModel.SystemMatrix(), x0)
+	_, cols := exp.Dims()
+	for j := 0; j < cols; j++ {
+		col := exp.ColView(j).(*mat.VecDense)
+		col.AddVec(col, mat.NewVecDense(2, []float64{-0.5, -1.0}))
+	}
+	assert.True(mat.EqualApprox(exp, f.Ensemble(), 1e-12))
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Predict(nil, _u)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestEnKFUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range []*Config{
+		{Analysis: PerturbedObs},
+		{Analysis: ETKF},
+		{Analysis: ETKF, Inflation: 1.5},
+		{Analysis: ETKF, Localization: mat.NewDense(2, 2, []float64{1, 1, 1, 1})},
+		{Analysis: PerturbedObs, Inflation: 1.5, Localization: mat.NewDense(2, 2, []float64{1, 1, 1, 1})},
+	} {
+		f, err := New(okModel, ic, q, r, 20, c)
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		// prior ensemble statistics
+		p := mat.NewSymDense(2, nil)
+		p.CopySym(f.Cov())
+		if c.Inflation > 1 {
+			p.ScaleSym(c.Inflation, p)
+		}
+		xMean := f.mean(f.x)
+
+		est, err := f.Update(nil, u, z)
+		assert.NotNil(est)
+		assert.NoError(err)
+
+		gain, xCorr, pCorr := kalmanUpdate(xMean, p, z)
+
+		// EnKF gain is Kalman gain of the ensemble covariance
+		assert.True(mat.EqualApprox(gain, f.Gain(), 1e-9))
+
+		// ETKF corrects the ensemble mean and covariance exactly
+		if c.Analysis == ETKF {
+			assert.True(mat.EqualApprox(xCorr, est.Val(), 1e-9))
+			assert.True(mat.EqualApprox(pCorr, f.Cov(), 1e-9))
+		}
+	}
+}
+
+func TestEnKFUpdateLocalization(t *testing.T) {
+	assert := assert.New(t)
+
+	// measurement elements have no impact on state elements
+	for _, a := range []Analysis{PerturbedObs, ETKF} {
+		f, err := New(okModel, ic, q, r, 10, &Config{Analysis: a, Localization: mat.NewDense(2, 2, nil)})
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		x := f.Ensemble()
+		est, err := f.Update(nil, u, z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(x, f.Ensemble(), 1e-9))
+	}
+
+	// only the first state element is corrected by the second measurement element
+	loc := mat.NewDense(2, 2, []float64{0, 1, 0, 0})
+	zp := mat.NewVecDense(2, []float64{math.NaN(), 2.5})
+	for _, a := range []Analysis{PerturbedObs, ETKF} {
+		f, err := New(okModel, ic, q, r, 10, &Config{Analysis: a, Localization: loc})
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		x := f.Ensemble()
+		est, err := f.Update(nil, u, zp)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.False(mat.EqualApprox(x.(*mat.Dense).RowView(0), f.Ensemble().(*mat.Dense).RowView(0), 1e-9))
+		assert.True(mat.EqualApprox(x.(*mat.Dense).RowView(1), f.Ensemble().(*mat.Dense).RowView(1), 1e-9))
+		assert.Equal(0.0, f.Gain().At(0, 0))
+	}
+}
+
+func TestEnKFMissingMeasurement(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, 10, &Config{Analysis: ETKF})
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	for _, _z := range []mat.Vector{nil, mat.NewVecDense(2, []float64{math.NaN(), math.NaN()})} {
+		x := f.Ensemble()
+		est, err := f.Update(nil, u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.True(mat.Equal(x, f.Ensemble()))
+	}
+
+	// partial measurement corrects the ensemble using the observed elements
+	p := mat.NewSymDense(2, nil)
+	p.CopySym(f.Cov())
+	xMean := f.mean(f.x)
+
+	est, err := f.Update(nil, u, mat.NewVecDense(2, []float64{-1.5, math.NaN()}))
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	h := okModel.OutputMatrix().(*mat.Dense).Slice(0, 1, 0, 2)
+	pht := &mat.Dense{}
+	pht.Mul(p, h.T())
+	s := &mat.Dense{}
+	s.Mul(h, pht)
+	gain := &mat.Dense{}
+	gain.Scale(1/(s.At(0, 0)+r.Cov().At(0, 0)), pht)
+	assert.True(mat.EqualApprox(gain, f.Gain().(*mat.Dense).Slice(0, 2, 0, 1), 1e-9))
+	assert.Equal(0.0, f.Gain().At(0, 1))
+
+	xCorr := mat.VecDenseCopyOf(xMean)
+	xCorr.AddScaledVec(xCorr, -1.5-xMean.AtVec(0), gain.ColView(0))
+	assert.True(mat.EqualApprox(xCorr, est.Val(), 1e-9))
+
+	// invalid measurement
+	est, err = f.Update(nil, u, mat.NewVecDense(3, nil))
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestEnKFRun(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, 10, nil)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	est, err := f.Run(nil, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Run(nil, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Run(nil, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestEnKFAccessors(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, 10, nil)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	assert.Equal(okModel, f.Model())
+	assert.NotNil(f.StateNoise())
+	assert.NotNil(f.OutputNoise())
+	assert.NotNil(f.Gain())
+
+	rows, cols := f.Ensemble().Dims()
+	assert.Equal(2, rows)
+	assert.Equal(10, cols)
+
+	cov := f.Cov()
+	assert.NotNil(cov)
+	assert.Equal(2, cov.SymmetricDim())
+
+	xMean := f.mean(f.x)
+	err = f.SetCov(mat.NewSymDense(2, []float64{1.0, 0.5, 0.5, 2.0}))
+	assert.NoError(err)
+	assert.False(mat.Equal(cov, f.Cov()))
+	assert.True(mat.EqualApprox(xMean, f.mean(f.x), 1e-9))
+
+	// invalid covariance
+	err = f.SetCov(nil)
+	assert.Error(err)
+
+	err = f.SetCov(mat.NewSymDense(3, nil))
+	assert.Error(err)
+}
+
+func TestGaspariCohn(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.InDelta(1.0, GaspariCohn(0, 1.0), 1e-12)
+	assert.InDelta(5.0/24.0, GaspariCohn(1.0, 1.0), 1e-12)
+	assert.InDelta(5.0/24.0, GaspariCohn(-1.0, 1.0), 1e-12)
+	assert.InDelta(0.0, GaspariCohn(2.0, 1.0), 1e-12)
+	assert.Equal(0.0, GaspariCohn(3.0, 1.0))
+	assert.Equal(0.0, GaspariCohn(1.0, 0.0))
+
+	// weights decrease with distance
+	assert.Greater(GaspariCohn(0.5, 1.0), GaspariCohn(0.7, 1.0))
+	assert.Greater(GaspariCohn(1.2, 1.0), GaspariCohn(1.5, 1.0))
+}
@@ -0,0 +1,46 @@
+package ghkf
+
+import (
+	"fmt"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman/qkf"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+)
+
+// GHKF is Gauss-Hermite quadrature Kalman Filter.
+// It uses tensor product Gauss-Hermite quadrature points of configurable order: there are order^n points
+// where n is the state dimension. The quadrature is exact for polynomials of degree up to 2*order-1,
+// which makes it accurate for low dimensional but highly nonlinear models.
+// State and output noise are assumed to be additive.
+type GHKF struct {
+	*qkf.QKF
+}
+
+// New creates new GHKF and returns it.
+// It accepts the following parameters:
+// - m:      dynamical system model
+// - init:   initial condition of the filter
+// - q:      state a.k.a. process noise
+// - r:      output a.k.a. measurement noise
+// - order:  number of quadrature points per state dimension
+// It returns error if either of the following conditions is met:
+// - invalid model is given: model dimensions must be positive integers
+// - invalid state or output noise is given: noise covariance must either be nil or match the model dimensions
+// - invalid quadrature order is given: order must be positive and order^n must not exceed sigma.MaxPoints
+func New(m filter.Model, init filter.InitCond, q, r filter.Noise, order int) (*GHKF, error) {
+	nx, _, _, _ := m.SystemDims()
+
+	// quadrature points of standard normal distribution
+	unit, err := sigma.GaussHermite(nx, order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate quadrature points: %v", err)
+	}
+
+	f, err := qkf.New(m, init, q, r, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GHKF{QKF: f}, nil
+}
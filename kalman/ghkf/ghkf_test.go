@@ -0,0 +1,209 @@
+package ghkf
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+type invalidModel struct {
+	filter.Model
+}
+
+func (m *invalidModel) SystemDims() (nx, nu, ny, nz int) {
+	return -10, 0, 8, 0
+}
+
+var (
+	okModel  *sim.BaseModel
+	badModel *invalidModel
+	ic       *sim.InitCond
+	q        filter.Noise
+	r        filter.Noise
+	u        *mat.VecDense
+	z        *mat.VecDense
+	order    int
+)
+
+func setup() {
+	u = mat.NewVecDense(1, []float64{-1.0})
+	z = mat.NewVecDense(1, []float64{-1.5})
+
+	// initial condition
+	initState := mat.NewVecDense(2, []float64{1.0, 3.0})
+	initCov := mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25})
+	ic = sim.NewInitCond(initState, initCov)
+
+	// state and output noise
+	q, _ = noise.NewGaussian([]float64{0, 0}, initCov)
+	r, _ = noise.NewGaussian([]float64{0}, mat.NewSymDense(1, []float64{0.25}))
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	B := mat.NewDense(2, 1, []float64{0.5, 1.0})
+	C := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	D := mat.NewDense(1, 1, []float64{0.0})
+
+	okModel = &sim.BaseModel{A: A, B: B, C: C, D: D}
+	badModel = &invalidModel{okModel}
+
+	order = 3
+}
+
+func TestMain(m *testing.M) {
+	// set up tests
+	setup()
+	// run the tests
+	retCode := m.Run()
+	// call with result of m.Run()
+	os.Exit(retCode)
+}
+
+func TestGHKFNew(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, order)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	var _ kalman.Kalman = f
+
+	// invalid model: incorrect dimensions
+	f, err = New(badModel, ic, q, r, order)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid state noise dimension
+	_q, _ := noise.NewZero(20)
+	f, err = New(okModel, ic, _q, r, order)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid output noise dimension
+	_r, _ := noise.NewZero(20)
+	f, err = New(okModel, ic, q, _r, order)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid quadrature order
+	for _, _order := range []int{0, 1 << 20} {
+		f, err = New(okModel, ic, q, r, _order)
+		assert.Nil(f)
+		assert.Error(err)
+	}
+
+	// zero [state and output] noise
+	f, err = New(okModel, ic, nil, nil, order)
+	assert.NotNil(f)
+	assert.NoError(err)
+}
+
+func TestGHKFGenPoints(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, order)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	cov := mat.NewSymDense(2, []float64{0.5, 0.1, 0.1, 0.3})
+	assert.NoError(f.SetCov(cov))
+
+	x := mat.VecDenseCopyOf(ic.State())
+	qp, err := f.GenPoints(x)
+	assert.NotNil(qp)
+	assert.NoError(err)
+
+	rows, cols := qp.X.Dims()
+	assert.Equal(2, rows)
+	assert.Equal(9, cols)
+
+	// covariance which is not positive definite
+	assert.NoError(f.SetCov(mat.NewSymDense(2, []float64{1, 2, 2, 1})))
+	qp, err = f.GenPoints(x)
+	assert.Nil(qp)
+	assert.Error(err)
+}
+
+func TestGHKFUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, order)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	qp := f.Points()
+	_, cols := qp.X.Dims()
+	assert.Equal(9, cols)
+	assert.Len(qp.Wm, 9)
+
+	// missing measurement
+	for _, _z := range []mat.Vector{nil, mat.NewVecDense(1, []float64{math.NaN()})} {
+		xm := mat.VecDenseCopyOf(x)
+		est, err = f.Update(xm, u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.True(mat.Equal(x, est.Val()))
+	}
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+// cubeModel propagates scalar state as x^3
+type cubeModel struct {
+	*sim.BaseModel
+}
+
+func (m *cubeModel) Propagate(x, u, wd mat.Vector) (mat.Vector, error) {
+	return mat.NewVecDense(1, []float64{math.Pow(x.AtVec(0), 3)}), nil
+}
+
+func TestGHKFNonlinear(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &cubeModel{&sim.BaseModel{A: mat.NewDense(1, 1, []float64{1}), C: mat.NewDense(1, 1, []float64{1})}}
+	init := sim.NewInitCond(mat.NewVecDense(1, []float64{1.0}), mat.NewSymDense(1, []float64{0.25}))
+
+	// moments of x^3 for x ~ N(1, 0.25)
+	expMean := 1.75
+	expVar := 7.796875 - expMean*expMean
+
+	// fourth order rule integrates sixth moments exactly
+	f, err := New(m, init, nil, nil, 4)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	est, err := f.Predict(init.State(), nil)
+	assert.NoError(err)
+	assert.InDelta(expMean, est.Val().AtVec(0), 1e-10)
+	assert.InDelta(expVar, est.Cov().At(0, 0), 1e-10)
+
+	// lower order rule does not
+	f, err = New(m, init, nil, nil, 2)
+	assert.NoError(err)
+
+	est, err = f.Predict(init.State(), nil)
+	assert.NoError(err)
+	assert.InDelta(expMean, est.Val().AtVec(0), 1e-10)
+	assert.True(math.Abs(expVar-est.Cov().At(0, 0)) > 0.1)
+}
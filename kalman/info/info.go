@@ -0,0 +1,577 @@
+package info
+
+import (
+	"fmt"
+	"math"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/noise"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Sensor is a linear measurement source which observes the system state as y = C*x + r
+type Sensor struct {
+	// C is sensor observation matrix
+	C mat.Matrix
+	// R is sensor measurement noise covariance
+	R mat.Symmetric
+}
+
+// Info computes the information contribution of measurement z taken by the sensor and returns it.
+// The contribution consists of the information matrix C'*R^-1*C and the information vector C'*R^-1*z.
+// It returns error if the sensor dimensions do not match z or if R fails to be inverted.
+func (s *Sensor) Info(z mat.Vector) (*mat.SymDense, *mat.VecDense, error) {
+	ny, nx := s.C.Dims()
+	if s.R == nil || s.R.SymmetricDim() != ny {
+		return nil, nil, fmt.Errorf("invalid sensor noise covariance")
+	}
+
+	if z.Len() != ny {
+		return nil, nil, fmt.Errorf("invalid measurement supplied: %v", z)
+	}
+
+	var chol mat.Cholesky
+	if ok := chol.Factorize(s.R); !ok {
+		return nil, nil, fmt.Errorf("failed to factorize sensor noise covariance")
+	}
+
+	rInv := mat.NewSymDense(ny, nil)
+	if err := chol.InverseTo(rInv); err != nil {
+		return nil, nil, fmt.Errorf("failed to invert sensor noise covariance: %v", err)
+	}
+
+	// C'*R^-1
+	cr := &mat.Dense{}
+	cr.Mul(s.C.T(), rInv)
+
+	// C'*R^-1*C
+	crc := &mat.Dense{}
+	crc.Mul(cr, s.C)
+
+	info := mat.NewSymDense(nx, nil)
+	for i := 0; i < nx; i++ {
+		for j := i; j < nx; j++ {
+			info.SetSym(i, j, 0.5*(crc.At(i, j)+crc.At(j, i)))
+		}
+	}
+
+	// C'*R^-1*z
+	infoVec := mat.NewVecDense(nx, nil)
+	infoVec.MulVec(cr, z)
+
+	return info, infoVec, nil
+}
+
+// IF is Information Filter.
+// Instead of the state covariance matrix P and the state x it carries the information
+// matrix Y = P^-1 and the information vector y = Y*x which allows it to start from a completely
+// unknown state (zero information) and fuse measurements from several sensors by simple addition.
+type IF struct {
+	// m is IF system model
+	m filter.DiscreteModel
+	// q is state noise a.k.a. process noise
+	q filter.Noise
+	// r is output noise a.k.a. measurement noise
+	r filter.Noise
+	// aInv is inverse of the system propagation matrix
+	aInv *mat.Dense
+	// sq is square root of state noise covariance
+	sq *mat.Dense
+	// y is the IF information matrix
+	y *mat.SymDense
+	// yNext is the IF predicted information matrix
+	yNext *mat.SymDense
+	// v is the IF information vector
+	v *mat.VecDense
+	// p is the IF covariance matrix matching the information matrix
+	p *mat.SymDense
+	// inn is innovation vector
+	inn *mat.VecDense
+	// k is Kalman gain
+	k *mat.Dense
+}
+
+// New creates new IF and returns it.
+// It accepts the following parameters:
+//   - m:      dynamical system model
+//   - init:   initial condition of the filter
+//   - z:      disturbance input a.k.a. process noise corresponding to E disturbance matrix
+//   - wn:     output noise a.k.a. measurement noise
+//
+// It returns error if either of the following conditions is met:
+//   - invalid model is given: model dimensions must be positive integers
+//   - invalid state or output noise is given: state noise must either be nil or match the model dimensions,
+//     output noise must match the model dimensions and have invertible covariance
+//   - system propagation matrix or initial condition covariance are not invertible
+//   - initial information matrix fails to be inverted
+func New(m filter.DiscreteModel, init filter.InitCond, z, wn filter.Noise) (*IF, error) {
+	var chol mat.Cholesky
+	if ok := chol.Factorize(init.Cov()); !ok {
+		return nil, fmt.Errorf("failed to factorize initial condition covariance")
+	}
+
+	info := mat.NewSymDense(init.Cov().SymmetricDim(), nil)
+	if err := chol.InverseTo(info); err != nil {
+		return nil, fmt.Errorf("failed to invert initial condition covariance: %v", err)
+	}
+
+	return NewWithInfo(m, info, z, wn)
+}
+
+// NewWithInfo creates new IF initialized with information matrix info and returns it.
+// Unlike New it accepts singular information matrices: zero info represents a completely unknown initial state.
+// See New for the description of the rest of the parameters and the returned errors.
+func NewWithInfo(m filter.DiscreteModel, info mat.Symmetric, z, wn filter.Noise) (*IF, error) {
+	// size of the input and output vectors
+	nx, _, ny, _ := m.SystemDims()
+	if nx <= 0 || ny <= 0 {
+		return nil, fmt.Errorf("invalid model dimensions: [%d x %d]", nx, ny)
+	}
+
+	if info == nil || info.SymmetricDim() != nx {
+		return nil, fmt.Errorf("invalid information matrix")
+	}
+
+	if z != nil {
+		if z.Cov().SymmetricDim() != nx {
+			return nil, fmt.Errorf("invalid state noise dimension: %d", z.Cov().SymmetricDim())
+		}
+	} else {
+		z, _ = noise.NewNone()
+	}
+
+	// information filter requires output noise covariance to be invertible
+	if wn == nil || wn.Cov().SymmetricDim() != ny {
+		return nil, fmt.Errorf("invalid output noise")
+	}
+
+	rows, cols := m.SystemMatrix().Dims()
+	if rows != nx || cols != nx {
+		return nil, fmt.Errorf("invalid propagation matrix dimensions: [%d x %d]", rows, cols)
+	}
+
+	rows, cols = m.OutputMatrix().Dims()
+	if rows != ny || cols != nx {
+		return nil, fmt.Errorf("invalid observation matrix dimensions: [%d x %d]", rows, cols)
+	}
+
+	// check the output noise covariance can be inverted
+	s := &Sensor{C: m.OutputMatrix(), R: wn.Cov()}
+	if _, _, err := s.Info(mat.NewVecDense(ny, nil)); err != nil {
+		return nil, err
+	}
+
+	aInv := &mat.Dense{}
+	if err := aInv.Inverse(m.SystemMatrix()); err != nil {
+		return nil, fmt.Errorf("failed to invert propagation matrix: %v", err)
+	}
+
+	var sq *mat.Dense
+	if _, ok := z.(*noise.None); !ok {
+		sq = sqrtPSD(z.Cov())
+	}
+
+	y := mat.NewSymDense(nx, nil)
+	y.CopySym(info)
+
+	// predicted information matrix
+	yNext := mat.NewSymDense(nx, nil)
+	yNext.CopySym(info)
+
+	// information vector
+	v := mat.NewVecDense(nx, nil)
+
+	// covariance matrix
+	pinv, null, err := pinvSym(y)
+	if err != nil {
+		return nil, fmt.Errorf("failed to invert information matrix: %v", err)
+	}
+	p := infCov(pinv, null)
+
+	// innovation vector
+	inn := mat.NewVecDense(ny, nil)
+
+	// kalman gain
+	k := mat.NewDense(nx, ny, nil)
+
+	return &IF{
+		m:     m,
+		q:     z,
+		r:     wn,
+		aInv:  aInv,
+		sq:    sq,
+		y:     y,
+		yNext: yNext,
+		v:     v,
+		p:     p,
+		inn:   inn,
+		k:     k,
+	}, nil
+}
+
+// Predict calculates the next system state given the state x and input u and returns its estimate.
+// The information matrix is propagated in information form, so it does not need to be invertible:
+//
+//	M = A^-T * Y * A^-1
+//	Y = (I - M*Sq*(I + Sq'*M*Sq)^-1*Sq') * M
+//
+// where Q = Sq*Sq'. Returned estimate covariance is the pseudo-inverse of the predicted information matrix
+// with +Inf variance of the state components about which there is no information (see Cov).
+// It returns error if it fails to propagate x to the next step or to invert the predicted information matrix.
+func (k *IF) Predict(x, u mat.Vector) (filter.Estimate, error) {
+	nx, _, _, _ := k.m.SystemDims()
+
+	// propagate input state to the next step
+	xNext, err := k.m.Propagate(x, u, k.q.Sample())
+	if err != nil {
+		return nil, fmt.Errorf("system state propagation failed: %v", err)
+	}
+
+	// M = A^-T * Y * A^-1
+	mm := &mat.Dense{}
+	mm.Mul(k.aInv.T(), k.y)
+	mm.Mul(mm, k.aInv)
+
+	yNext := mat.DenseCopyOf(mm)
+	if k.sq != nil {
+		_, nq := k.sq.Dims()
+
+		// M*Sq
+		ms := &mat.Dense{}
+		ms.Mul(mm, k.sq)
+
+		// G = I + Sq'*M*Sq
+		g := &mat.Dense{}
+		g.Mul(k.sq.T(), ms)
+		for i := 0; i < nq; i++ {
+			g.Set(i, i, g.At(i, i)+1.0)
+		}
+
+		// M*Sq*G^-1*Sq'*M
+		gInv := &mat.Dense{}
+		if err := gInv.Inverse(g); err != nil {
+			return nil, fmt.Errorf("failed to propagate information matrix: %v", err)
+		}
+		c := &mat.Dense{}
+		c.Mul(ms, gInv)
+		c.Mul(c, ms.T())
+
+		yNext.Sub(yNext, c)
+	}
+
+	// update IF predicted information matrix
+	for i := 0; i < nx; i++ {
+		for j := i; j < nx; j++ {
+			k.yNext.SetSym(i, j, 0.5*(yNext.At(i, j)+yNext.At(j, i)))
+		}
+	}
+
+	pinv, null, err := pinvSym(k.yNext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to invert information matrix: %v", err)
+	}
+
+	return estimate.NewBaseWithCov(xNext, infCov(pinv, null))
+}
+
+// Update corrects state x using the measurement z, given control intput u and returns corrected estimate.
+// It adds the information contribution of z to the predicted information matrix and vector.
+// Missing measurement elements can be marked with NaN: only the information contribution of the observed elements is then added.
+// If the measurement is missing entirely (nil or all NaN) the predicted information matrix and vector are kept.
+// It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
+func (k *IF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
+	nx, _, ny, _ := k.m.SystemDims()
+
+	if z != nil && z.Len() != ny {
+		return nil, fmt.Errorf("invalid measurement supplied: %v", z)
+	}
+
+	if x.Len() != nx {
+		return nil, fmt.Errorf("invalid state supplied: %v", x)
+	}
+
+	// observe system output in the next step
+	y, err := k.m.Observe(x, u, k.r.Sample())
+	if err != nil {
+		return nil, fmt.Errorf("failed to observe system output: %v", err)
+	}
+
+	k.inn.Zero()
+	k.k.Zero()
+
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: keep predicted information
+		pinv, null, err := pinvSym(k.yNext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to invert information matrix: %v", err)
+		}
+
+		k.y.CopySym(k.yNext)
+		k.v.MulVec(k.yNext, x)
+		k.p.CopySym(infCov(pinv, null))
+
+		return estimate.NewBaseWithCov(x, k.Cov())
+	}
+
+	// observation matrix rows and output noise covariance of observed measurement elements
+	h := kalman.SelectRows(k.m.OutputMatrix(), idx)
+	rCov := kalman.SelectSym(k.r.Cov(), idx)
+
+	// innovation vector
+	inn := &mat.VecDense{}
+	inn.SubVec(kalman.SelectVec(z, idx), kalman.SelectVec(y, idx))
+
+	// measurement with the feedforward part removed: C*x + inn
+	cz := &mat.VecDense{}
+	cz.MulVec(h, x)
+	cz.AddVec(cz, inn)
+
+	s := &Sensor{C: h, R: rCov}
+	est, err := k.Fuse(x, []*Sensor{s}, []mat.Vector{cz})
+	if err != nil {
+		return nil, err
+	}
+
+	// K = P * C' * R^-1 where P is the pseudo-inverse of the information matrix
+	pinv, _, err := pinvSym(k.y)
+	if err != nil {
+		return nil, fmt.Errorf("failed to invert information matrix: %v", err)
+	}
+	rInv := &mat.Dense{}
+	if err := rInv.Inverse(rCov); err != nil {
+		return nil, fmt.Errorf("failed to invert output noise covariance: %v", err)
+	}
+	gain := &mat.Dense{}
+	gain.Mul(pinv, h.T())
+	gain.Mul(gain, rInv)
+
+	// update IF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
+	}
+
+	return est, nil
+}
+
+// Fuse corrects the predicted state x with measurements zs taken by sensors ss and returns corrected estimate.
+// Information contributions of all measurements are simply added to the predicted information matrix and vector.
+// Returned estimate covariance is the same as the one returned by Cov.
+// It returns error if the number of sensors and measurements differ, if any of the measurements is invalid
+// or if the corrected information matrix fails to be inverted.
+func (k *IF) Fuse(x mat.Vector, ss []*Sensor, zs []mat.Vector) (filter.Estimate, error) {
+	nx, _, _, _ := k.m.SystemDims()
+
+	if len(ss) != len(zs) {
+		return nil, fmt.Errorf("invalid number of measurements: %d != %d", len(zs), len(ss))
+	}
+
+	if x.Len() != nx {
+		return nil, fmt.Errorf("invalid state supplied: %v", x)
+	}
+
+	// predicted information matrix and vector
+	y := mat.NewSymDense(nx, nil)
+	y.CopySym(k.yNext)
+	v := mat.NewVecDense(nx, nil)
+	v.MulVec(k.yNext, x)
+
+	for i := range ss {
+		if _, cols := ss[i].C.Dims(); cols != nx {
+			return nil, fmt.Errorf("invalid sensor %d observation matrix dimensions", i)
+		}
+
+		info, infoVec, err := ss[i].Info(zs[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid sensor %d measurement: %v", i, err)
+		}
+
+		y.AddSym(y, info)
+		v.AddVec(v, infoVec)
+	}
+
+	pinv, null, err := pinvSym(y)
+	if err != nil {
+		return nil, fmt.Errorf("failed to invert information matrix: %v", err)
+	}
+	cov := infCov(pinv, null)
+
+	// update IF information matrix and vector
+	k.y.CopySym(y)
+	k.v.CopyVec(v)
+	k.p.CopySym(cov)
+
+	xCorr := mat.NewVecDense(nx, nil)
+	xCorr.MulVec(pinv, v)
+
+	return estimate.NewBaseWithCov(xCorr, cov)
+}
+
+// Run runs one step of IF for given state x, input u and measurement z.
+// It corrects system state x using measurement z and returns new system estimate.
+// It returns error if it either fails to propagate or correct state x.
+func (k *IF) Run(x, u, z mat.Vector) (filter.Estimate, error) {
+	pred, err := k.Predict(x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	est, err := k.Update(pred.Val(), u, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return est, nil
+}
+
+// Model returns IF model
+func (k *IF) Model() filter.Model {
+	return k.m
+}
+
+// StateNoise retruns state noise
+func (k *IF) StateNoise() filter.Noise {
+	return k.q
+}
+
+// OutputNoise retruns output noise
+func (k *IF) OutputNoise() filter.Noise {
+	return k.r
+}
+
+// Info returns IF information matrix
+func (k *IF) Info() mat.Symmetric {
+	info := mat.NewSymDense(k.y.SymmetricDim(), nil)
+	info.CopySym(k.y)
+
+	return info
+}
+
+// InfoVec returns IF information vector
+func (k *IF) InfoVec() mat.Vector {
+	v := &mat.VecDense{}
+	v.CloneFromVec(k.v)
+
+	return v
+}
+
+// Cov returns IF covariance i.e. the [pseudo-]inverse of the information matrix.
+// NOTE: if the information matrix is singular, the state is not fully known and its covariance is unbounded:
+// variance of every state component which lies in the null space of the information matrix is reported
+// as +Inf on the diagonal. The rest of the covariance matrix is the pseudo-inverse of the information matrix.
+func (k *IF) Cov() mat.Symmetric {
+	cov := mat.NewSymDense(k.p.SymmetricDim(), nil)
+	cov.CopySym(k.p)
+
+	return cov
+}
+
+// SetCov sets IF information matrix to the inverse of cov.
+// It returns error if either cov is nil, its dimensions are not the same as IF covariance dimensions
+// or if it's not invertible.
+func (k *IF) SetCov(cov mat.Symmetric) error {
+	if cov == nil {
+		return fmt.Errorf("invalid covariance matrix: %v", cov)
+	}
+
+	if cov.SymmetricDim() != k.y.SymmetricDim() {
+		return fmt.Errorf("invalid covariance matrix dims: [%d x %d]", cov.SymmetricDim(), cov.SymmetricDim())
+	}
+
+	var chol mat.Cholesky
+	if ok := chol.Factorize(cov); !ok {
+		return fmt.Errorf("failed to factorize covariance matrix")
+	}
+
+	if err := chol.InverseTo(k.y); err != nil {
+		return fmt.Errorf("failed to invert covariance matrix: %v", err)
+	}
+	k.p.CopySym(cov)
+
+	return nil
+}
+
+// Gain returns Kalman gain
+func (k *IF) Gain() mat.Matrix {
+	gain := &mat.Dense{}
+	gain.CloneFrom(k.k)
+
+	return gain
+}
+
+// pinvSym computes pseudo-inverse of symmetric positive semi-definite matrix a and returns it
+// along with the diagonal of the orthogonal projector onto the null space of a.
+// Eigenvalues which are negligible relative to the largest one are treated as zero.
+// It returns error if eigen decomposition of a fails.
+func pinvSym(a mat.Symmetric) (*mat.SymDense, []float64, error) {
+	n := a.SymmetricDim()
+	inv := mat.NewSymDense(n, nil)
+	null := make([]float64, n)
+
+	var eig mat.EigenSym
+	if ok := eig.Factorize(a, true); !ok {
+		return nil, nil, fmt.Errorf("eigen decomposition failed")
+	}
+
+	vals := eig.Values(nil)
+	vecs := &mat.Dense{}
+	eig.VectorsTo(vecs)
+
+	maxVal := 0.0
+	for _, val := range vals {
+		maxVal = math.Max(maxVal, math.Abs(val))
+	}
+	tol := float64(n) * maxVal * 1e-12
+
+	for i, val := range vals {
+		if val > tol {
+			inv.SymRankOne(inv, 1/val, vecs.ColView(i))
+			continue
+		}
+
+		for j := range null {
+			null[j] += vecs.At(j, i) * vecs.At(j, i)
+		}
+	}
+
+	return inv, null, nil
+}
+
+// infCov returns covariance matching the information matrix with pseudo-inverse pinv and null space projector diagonal null.
+// Variance of the state components which have non-negligible projection onto the null space is set to +Inf.
+func infCov(pinv *mat.SymDense, null []float64) *mat.SymDense {
+	cov := mat.NewSymDense(pinv.SymmetricDim(), nil)
+	cov.CopySym(pinv)
+
+	for i := range null {
+		if null[i] > 1e-12 {
+			cov.SetSym(i, i, math.Inf(1))
+		}
+	}
+
+	return cov
+}
+
+// sqrtPSD computes a square root S of symmetric positive semi-definite matrix a such that a = S*S' and returns it.
+func sqrtPSD(a mat.Symmetric) *mat.Dense {
+	n := a.SymmetricDim()
+	s := mat.NewDense(n, n, nil)
+
+	var eig mat.EigenSym
+	if ok := eig.Factorize(a, true); !ok {
+		return s
+	}
+
+	vals := eig.Values(nil)
+	eig.VectorsTo(s)
+	for i := range vals {
+		vals[i] = math.Sqrt(math.Max(vals[i], 0))
+	}
+	s.Mul(s, mat.NewDiagDense(n, vals))
+
+	return s
+}
@@ -0,0 +1,447 @@
+package info
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman/kf"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+type invalidModel struct {
+	filter.DiscreteModel
+	nx int
+	nu int
+	ny int
+}
+
+func (m *invalidModel) SystemDims() (nx, nu, ny, nz int) {
+	return m.nx, m.nu, m.ny, 0
+}
+
+var (
+	okModel  *sim.BaseModel
+	badModel *invalidModel
+	ic       *sim.InitCond
+	q        filter.Noise
+	r        filter.Noise
+	u        *mat.VecDense
+	z        *mat.VecDense
+)
+
+func setup() {
+	u = mat.NewVecDense(1, []float64{-1.0})
+	z = mat.NewVecDense(1, []float64{-1.5})
+
+	// initial condition
+	initState := mat.NewVecDense(2, []float64{1.0, 3.0})
+	initCov := mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25})
+	ic = sim.NewInitCond(initState, initCov)
+
+	// state and output noise
+	q, _ = noise.NewGaussian([]float64{0, 0}, initCov)
+	r, _ = noise.NewGaussian([]float64{0}, mat.NewSymDense(1, []float64{0.25}))
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	B := mat.NewDense(2, 1, []float64{0.5, 1.0})
+	C := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	D := mat.NewDense(1, 1, []float64{0.0})
+
+	okModel = &sim.BaseModel{A: A, B: B, C: C, D: D}
+	badModel = &invalidModel{DiscreteModel: okModel, nx: 10, ny: 10}
+}
+
+func TestMain(m *testing.M) {
+	// set up tests
+	setup()
+	// run the tests
+	retCode := m.Run()
+	// call with result of m.Run()
+	os.Exit(retCode)
+}
+
+func TestIFNew(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NoError(err)
+	assert.NotNil(f)
+
+	// invalid model: negative dimensions
+	badModel.nx, badModel.ny = -10, 20
+	f, err = New(badModel, ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid state noise dimension
+	_q := q
+	q, _ = noise.NewZero(20)
+	f, err = New(okModel, ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+	q = _q
+
+	// invalid output noise dimension
+	_r := r
+	r, _ = noise.NewZero(20)
+	f, err = New(okModel, ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+	r = _r
+
+	// output noise covariance must be invertible
+	_r = r
+	r, _ = noise.NewZero(1)
+	f, err = New(okModel, ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+	r = _r
+
+	// zero state noise
+	f, err = New(okModel, ic, nil, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// singular initial covariance
+	_ic := sim.NewInitCond(ic.State(), mat.NewSymDense(2, nil))
+	f, err = New(okModel, _ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// zero information
+	f, err = NewWithInfo(okModel, mat.NewSymDense(2, nil), q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// invalid information matrix
+	f, err = NewWithInfo(okModel, mat.NewSymDense(3, nil), q, r)
+	assert.Nil(f)
+	assert.Error(err)
+}
+
+func TestIFPredict(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Predict(x, u)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Predict(x, _u)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestIFUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+// meanNoise is Gaussian noise which always samples its mean
+type meanNoise struct {
+	*noise.Gaussian
+}
+
+func newMeanNoise(cov mat.Symmetric) *meanNoise {
+	g, _ := noise.NewGaussian(make([]float64, cov.SymmetricDim()), cov)
+
+	return &meanNoise{Gaussian: g}
+}
+
+func (n *meanNoise) Sample() mat.Vector {
+	return mat.NewVecDense(n.Cov().SymmetricDim(), n.Mean())
+}
+
+func TestIFPartial(t *testing.T) {
+	assert := assert.New(t)
+
+	// model with two outputs and its reduction to the first output
+	C := mat.NewDense(2, 2, []float64{1.0, 0.0, 1.0, 1.0})
+	D := mat.NewDense(2, 1, []float64{0.0, 0.5})
+	m := &sim.BaseModel{A: okModel.A, B: okModel.B, C: C, D: D}
+	rm := &sim.BaseModel{A: okModel.A, B: okModel.B, C: mat.DenseCopyOf(C.Slice(0, 1, 0, 2)), D: mat.DenseCopyOf(D.Slice(0, 1, 0, 1))}
+
+	_r := newMeanNoise(mat.NewSymDense(2, []float64{0.25, 0.1, 0.1, 0.5}))
+	_rr := newMeanNoise(mat.NewSymDense(1, []float64{0.25}))
+	_z := mat.NewVecDense(2, []float64{-1.5, math.NaN()})
+
+	f, err := New(m, ic, q, _r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	fr, err := New(rm, ic, q, _rr)
+	assert.NotNil(fr)
+	assert.NoError(err)
+
+	// partial measurement is equivalent to the measurement of the reduced model
+	est, err := f.Update(mat.VecDenseCopyOf(ic.State()), u, _z)
+	assert.NotNil(est)
+	assert.NoError(err)
+	estR, err := fr.Update(mat.VecDenseCopyOf(ic.State()), u, z)
+	assert.NotNil(estR)
+	assert.NoError(err)
+
+	assert.True(mat.EqualApprox(estR.Val(), est.Val(), 1e-12))
+	assert.True(mat.EqualApprox(estR.Cov(), est.Cov(), 1e-12))
+	assert.True(mat.EqualApprox(fr.Info(), f.Info(), 1e-12))
+
+	// gain of the missing measurement element is zero
+	gain := f.Gain()
+	assert.True(mat.EqualApprox(fr.Gain(), gain.(*mat.Dense).Slice(0, 2, 0, 1), 1e-12))
+	assert.Equal([]float64{0, 0}, mat.Col(nil, 1, gain))
+
+	// missing measurement: state is not corrected and predicted information is kept
+	f, err = NewWithInfo(m, mat.NewSymDense(2, []float64{4, 0, 0, 0}), q, _r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	pred, err := f.Predict(mat.VecDenseCopyOf(ic.State()), u)
+	assert.NotNil(pred)
+	assert.NoError(err)
+
+	for _, mz := range []mat.Vector{nil, mat.NewVecDense(2, []float64{math.NaN(), math.NaN()})} {
+		x := mat.VecDenseCopyOf(pred.Val())
+		est, err = f.Update(x, u, mz)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.True(mat.Equal(pred.Val(), est.Val()))
+		assert.True(mat.Equal(pred.Cov(), est.Cov()))
+		assert.True(mat.Equal(pred.Cov(), f.Cov()))
+		assert.Equal([]float64{0, 0, 0, 0}, f.Gain().(*mat.Dense).RawMatrix().Data)
+	}
+}
+
+func TestIFRun(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Run(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestIFMatchesKF(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	k, err := kf.New(okModel, ic, q, r)
+	assert.NotNil(k)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	for i := 0; i < 10; i++ {
+		_, err = f.Run(mat.VecDenseCopyOf(x), u, z)
+		assert.NoError(err)
+		_, err = k.Run(mat.VecDenseCopyOf(x), u, z)
+		assert.NoError(err)
+
+		assert.True(mat.EqualApprox(f.Cov(), k.Cov(), 1e-9))
+		assert.True(mat.EqualApprox(f.Gain(), k.Gain(), 1e-9))
+	}
+}
+
+func TestIFZeroInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := NewWithInfo(okModel, mat.NewSymDense(2, nil), q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// variance of the completely unknown state is unbounded
+	assert.True(math.IsInf(f.Cov().At(0, 0), 1))
+	assert.True(math.IsInf(f.Cov().At(1, 1), 1))
+
+	// initial state is completely unknown
+	x := mat.NewVecDense(2, nil)
+	est, err := f.Run(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// single measurement does not make the whole state observable
+	var eig mat.EigenSym
+	assert.True(eig.Factorize(f.Info(), false))
+	assert.InDelta(0.0, eig.Values(nil)[0], 1e-9)
+
+	// only the measured state component is known
+	assert.InDelta(r.Cov().At(0, 0), f.Cov().At(0, 0), 1e-12)
+	assert.True(math.IsInf(f.Cov().At(1, 1), 1))
+	assert.True(mat.Equal(f.Cov(), est.Cov()))
+	for _, v := range f.Gain().(*mat.Dense).RawMatrix().Data {
+		assert.False(math.IsNaN(v) || math.IsInf(v, 0))
+	}
+
+	est, err = f.Run(est.Val(), u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// the state is observable after two steps
+	assert.True(eig.Factorize(f.Info(), false))
+	assert.True(eig.Values(nil)[0] > 0.0)
+	assert.True(f.Cov().At(0, 0) > 0.0)
+	assert.True(f.Cov().At(1, 1) > 0.0)
+	assert.False(math.IsInf(f.Cov().At(1, 1), 1))
+}
+
+func TestIFFuse(t *testing.T) {
+	assert := assert.New(t)
+
+	s1 := &Sensor{
+		C: mat.NewDense(1, 2, []float64{1.0, 0.0}),
+		R: mat.NewSymDense(1, []float64{0.25}),
+	}
+	s2 := &Sensor{
+		C: mat.NewDense(1, 2, []float64{0.0, 1.0}),
+		R: mat.NewSymDense(1, []float64{0.5}),
+	}
+	s := &Sensor{
+		C: mat.NewDense(2, 2, []float64{1.0, 0.0, 0.0, 1.0}),
+		R: mat.NewSymDense(2, []float64{0.25, 0, 0, 0.5}),
+	}
+
+	z1 := mat.NewVecDense(1, []float64{1.5})
+	z2 := mat.NewVecDense(1, []float64{2.5})
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	g, err := New(okModel, ic, q, r)
+	assert.NotNil(g)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est1, err := f.Fuse(x, []*Sensor{s1, s2}, []mat.Vector{z1, z2})
+	assert.NotNil(est1)
+	assert.NoError(err)
+
+	est2, err := g.Fuse(x, []*Sensor{s}, []mat.Vector{mat.NewVecDense(2, []float64{1.5, 2.5})})
+	assert.NotNil(est2)
+	assert.NoError(err)
+
+	assert.True(mat.EqualApprox(est1.Val(), est2.Val(), 1e-12))
+	assert.True(mat.EqualApprox(est1.Cov(), est2.Cov(), 1e-12))
+	assert.True(mat.EqualApprox(f.Info(), g.Info(), 1e-12))
+	assert.True(mat.EqualApprox(f.InfoVec(), g.InfoVec(), 1e-12))
+
+	// mismatched sensors and measurements
+	est, err := f.Fuse(x, []*Sensor{s1, s2}, []mat.Vector{z1})
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement
+	est, err = f.Fuse(x, []*Sensor{s1}, []mat.Vector{mat.NewVecDense(2, nil)})
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestIFModel(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	m := f.Model()
+	assert.NotNil(m)
+}
+
+func TestIFNoise(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	sn := f.StateNoise()
+	assert.NotNil(sn)
+
+	on := f.OutputNoise()
+	assert.NotNil(on)
+}
+
+func TestIFCov(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	cov := f.Cov()
+	assert.NotNil(cov)
+	assert.True(mat.EqualApprox(cov, ic.Cov(), 1e-12))
+
+	err = f.SetCov(nil)
+	assert.Error(err)
+
+	err = f.SetCov(mat.NewSymDense(30, nil))
+	assert.Error(err)
+
+	err = f.SetCov(mat.NewSymDense(2, nil))
+	assert.Error(err)
+
+	err = f.SetCov(mat.NewSymDense(2, []float64{0.5, 0, 0, 0.5}))
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(f.Info(), mat.NewSymDense(2, []float64{2, 0, 0, 2}), 1e-12))
+	assert.True(mat.EqualApprox(f.Cov(), mat.NewSymDense(2, []float64{0.5, 0, 0, 0.5}), 1e-12))
+}
+
+func TestIFGain(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	gain := f.Gain()
+	assert.NotNil(gain)
+}
@@ -5,6 +5,7 @@ import (
 
 	filter "github.com/milosgajdos/go-estimate"
 	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
 	"github.com/milosgajdos/go-estimate/noise"
 	"gonum.org/v1/gonum/mat"
 )
@@ -25,6 +26,15 @@ type KF struct {
 	inn *mat.VecDense
 	// k is Kalman gain
 	k *mat.Dense
+	// CovUpdate is covariance update strategy; Joseph form by default
+	CovUpdate kalman.CovUpdate
+	// Sequential enables sequential processing of measurement elements one at a time.
+	// It avoids inverting innovation covariance but requires diagonal measurement noise covariance.
+	Sequential bool
+	// tv is time-varying system model; nil for time-invariant models
+	tv filter.TimeVaryingModel
+	// step is time step of time-varying model
+	step int
 }
 
 // New creates new KF and returns it.
@@ -46,8 +56,8 @@ func New(m filter.DiscreteModel, init filter.InitCond, z, wn filter.Noise) (*KF,
 	}
 
 	if z != nil {
-		if z.Cov().SymmetricDim() != nx {
-			return nil, fmt.Errorf("invalid state noise dimension: %d != %d", z.Cov().SymmetricDim(), ny)
+		if _, err := filter.StateNoiseCov(m, z.Cov()); err != nil {
+			return nil, fmt.Errorf("invalid state noise: %v", err)
 		}
 	} else {
 		z, _ = noise.NewNone()
@@ -109,6 +119,21 @@ func New(m filter.DiscreteModel, init filter.InitCond, z, wn filter.Noise) (*KF,
 	}, nil
 }
 
+// NewTimeVarying creates new KF for time-varying model m and returns it.
+// KF starts at time step 0 and every call to Predict advances it to the next step:
+// Predict uses the model matrices at the current step, Update uses the matrices at the step reached by Predict.
+// It accepts the same parameters and returns the same errors as New; model matrices are validated at step 0.
+func NewTimeVarying(m filter.TimeVaryingModel, init filter.InitCond, z, wn filter.Noise) (*KF, error) {
+	k, err := New(filter.AtStep(m, 0), init, z, wn)
+	if err != nil {
+		return nil, err
+	}
+
+	k.tv = m
+
+	return k, nil
+}
+
 // Predict calculates the next system state given the state x and input u and returns its estimate.
 // It first generates new sigma points around x and then attempts to propagate them to the next step.
 // It returns error if it either fails to generate or propagate the sigma points (and x) to the next step.
@@ -124,7 +149,11 @@ func (k *KF) Predict(x, u mat.Vector) (filter.Estimate, error) {
 	cov.Mul(cov, k.m.SystemMatrix().T())
 
 	if _, ok := k.q.(*noise.None); !ok {
-		cov.Add(cov, k.q.Cov())
+		qCov, err := filter.StateNoiseCov(k.m, k.q.Cov())
+		if err != nil {
+			return nil, fmt.Errorf("failed to map state noise: %v", err)
+		}
+		cov.Add(cov, qCov)
 	}
 
 	// update KF predicted covariance matrix
@@ -135,15 +164,29 @@ func (k *KF) Predict(x, u mat.Vector) (filter.Estimate, error) {
 		}
 	}
 
+	k.advance()
+
 	return estimate.NewBaseWithCov(xNext, k.pNext)
 }
 
+// advance advances time-varying model to the next time step.
+func (k *KF) advance() {
+	if k.tv == nil {
+		return
+	}
+
+	k.step++
+	k.m = filter.AtStep(k.tv, k.step)
+}
+
 // Update corrects state x using the measurement z, given control intput u and returns corrected estimate.
+// Missing measurement elements can be marked with NaN: only the observed elements are then used to correct x.
+// If the measurement is missing entirely (nil or all NaN) x is not corrected and the predicted covariance is kept.
 // It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
 func (k *KF) Update(x, u, ym mat.Vector) (filter.Estimate, error) {
 	nx, _, ny, _ := k.m.SystemDims()
 
-	if ym.Len() != ny {
+	if ym != nil && ym.Len() != ny {
 		return nil, fmt.Errorf("invalid measurement supplied: %v", ym)
 	}
 
@@ -153,77 +196,94 @@ func (k *KF) Update(x, u, ym mat.Vector) (filter.Estimate, error) {
 		return nil, fmt.Errorf("failed to observe system output: %v", err)
 	}
 
+	k.inn.Zero()
+	k.k.Zero()
+
+	// indices of observed measurement elements
+	idx := filter.Observed(ym)
+	if len(idx) == 0 {
+		// no measurement: keep predicted covariance
+		k.p.CopySym(k.pNext)
+		return estimate.NewBaseWithCov(x, k.p)
+	}
+
+	gain, inn, pCorr, err := k.correct(ym, yNext, idx)
+	if err != nil {
+		return nil, err
+	}
+
+	// update state x
+	corr := &mat.VecDense{}
+	corr.MulVec(gain, inn)
+	x.(*mat.VecDense).AddVec(x, corr)
+
+	// update KF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
+	}
+	// update KF covariance matrix
+	k.p.CopySym(pCorr)
+	return estimate.NewBaseWithCov(x, k.p)
+}
+
+// correct calculates Kalman gain, innovation vector and corrected covariance from the predicted covariance
+// using the observed elements idx of measurement ym and the predicted system output yNext.
+// It returns error if it fails to calculate Kalman gain or the corrected covariance.
+func (k *KF) correct(ym, yNext mat.Vector, idx []int) (*mat.Dense, *mat.VecDense, *mat.SymDense, error) {
+	nx, _, _, _ := k.m.SystemDims()
+	ny := len(idx)
+
+	// observation matrix rows of observed measurement elements
+	h := kalman.SelectRows(k.m.OutputMatrix(), idx)
+
+	// measurement noise covariance of observed measurement elements
+	var rCov mat.Symmetric
+	if _, ok := k.r.(*noise.None); !ok {
+		rCov = kalman.SelectSym(k.r.Cov(), idx)
+	}
+
+	// innovation vector
+	inn := &mat.VecDense{}
+	inn.SubVec(kalman.SelectVec(ym, idx), kalman.SelectVec(yNext, idx))
+
+	if k.Sequential {
+		gain, pCorr, err := kalman.SequentialUpdate(k.CovUpdate, k.pNext, h, rCov)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("sequential update failed: %v", err)
+		}
+
+		return gain, inn, pCorr, nil
+	}
+
 	pxy := mat.NewDense(nx, ny, nil)
 	pyy := mat.NewDense(ny, ny, nil)
 
 	// P*H'
-	pxy.Mul(k.pNext, k.m.OutputMatrix().T())
+	pxy.Mul(k.pNext, h.T())
 
 	// Note: pxy = P * H' so we reuse the result here
 	// H*P*H'
-	pyy.Mul(k.m.OutputMatrix(), pxy)
-	// no measurement noise
-	if _, ok := k.r.(*noise.None); !ok {
-		pyy.Add(pyy, k.r.Cov())
+	pyy.Mul(h, pxy)
+	// add measurement noise
+	if rCov != nil {
+		pyy.Add(pyy, rCov)
 	}
 
 	// calculate Kalman gain
 	pyyInv := &mat.Dense{}
 	if err := pyyInv.Inverse(pyy); err != nil {
-		return nil, fmt.Errorf("failed to calculat Pyy inverse: %v", err)
+		return nil, nil, nil, fmt.Errorf("failed to calculat Pyy inverse: %v", err)
 	}
 	gain := &mat.Dense{}
 	gain.Mul(pxy, pyyInv)
 
-	// innovation vector
-	inn := &mat.VecDense{}
-	inn.SubVec(ym, yNext)
-
-	// update state x
-	corr := &mat.Dense{}
-	corr.Mul(gain, inn)
-	x.(*mat.VecDense).AddVec(x, corr.ColView(0))
-
-	// Joseph form update
-	eye := mat.NewDiagDense(x.Len(), nil)
-	for i := 0; i < x.Len(); i++ {
-		eye.SetDiag(i, 1.0)
-	}
-	a := &mat.Dense{}
-	// K*H
-	a.Mul(gain, k.m.OutputMatrix())
-	// eye - K*H
-	a.Sub(eye, a)
-
-	// K*R*K'
-	pkrk := &mat.Dense{}
-	// if there is some output noise
-	if _, ok := k.r.(*noise.None); !ok {
-		kr := &mat.Dense{}
-		kr.Mul(gain, k.r.Cov())
-		pkrk.Mul(kr, gain.T())
-	}
-
-	ap := &mat.Dense{}
-	ap.Mul(a, k.pNext)
-	apa := &mat.Dense{}
-	apa.Mul(ap, a.T())
-
-	pCorr := &mat.Dense{}
-	if !pkrk.IsEmpty() {
-		pCorr.Add(apa, pkrk)
+	pCorr, err := kalman.UpdateCov(k.CovUpdate, k.pNext, gain, h, rCov)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to update covariance: %v", err)
 	}
 
-	// update KF innovation vector
-	k.inn.CopyVec(inn)
-	k.k.Copy(gain)
-	// update KF covariance matrix
-	for i := 0; i < nx; i++ {
-		for j := i; j < nx; j++ {
-			k.p.SetSym(i, j, pCorr.At(i, j))
-		}
-	}
-	return estimate.NewBaseWithCov(x, k.p)
+	return gain, inn, pCorr, nil
 }
 
 // Run runs one step of KF for given state x, input u and measurement z.
@@ -243,11 +303,17 @@ func (k *KF) Run(x, u, z mat.Vector) (filter.Estimate, error) {
 	return est, nil
 }
 
-// Model returns KF models
+// Model returns KF models.
+// For time-varying models it returns the model at the current time step.
 func (k *KF) Model() filter.Model {
 	return k.m
 }
 
+// Step returns current time step of time-varying model.
+func (k *KF) Step() int {
+	return k.step
+}
+
 // StateNoise retruns state noise
 func (k *KF) StateNoise() filter.Noise {
 	return k.q
@@ -282,10 +348,18 @@ func (k *KF) SetCov(cov mat.Symmetric) error {
 	return nil
 }
 
-// Gain returns Kalman gain
+// Gain returns Kalman gain.
 func (k *KF) Gain() mat.Matrix {
 	gain := &mat.Dense{}
 	gain.CloneFrom(k.k)
 
 	return gain
 }
+
+// Innov returns innovation vector of the last update.
+func (k *KF) Innov() mat.Vector {
+	inn := mat.NewVecDense(k.inn.Len(), nil)
+	inn.CopyVec(k.inn)
+
+	return inn
+}
@@ -0,0 +1,307 @@
+package kf
+
+import (
+	"fmt"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	// dareMaxIter is the maximum number of DARE solver iterations
+	dareMaxIter = 100
+	// dareTol is DARE solver relative convergence tolerance
+	dareTol = 1e-12
+)
+
+// SteadyKF is Steady-State Kalman Filter.
+// It solves the Discrete Algebraic Riccati Equation (DARE) once when it's created
+// and then runs Predict and Update with a fixed Kalman gain and covariance.
+// Unlike KF its covariance can't be modified.
+type SteadyKF struct {
+	// kf is Kalman filter which stores the steady-state gain and covariances
+	kf *KF
+}
+
+// steadyInit is a steady-state initial condition
+type steadyInit struct {
+	state *mat.VecDense
+	cov   *mat.SymDense
+}
+
+// State returns initial state
+func (s *steadyInit) State() mat.Vector { return s.state }
+
+// Cov returns initial covariance
+func (s *steadyInit) Cov() mat.Symmetric { return s.cov }
+
+// NewSteady creates new Steady-State KF and returns it.
+// It accepts the following parameters:
+//   - m:      time-invariant dynamical system model
+//   - z:      disturbance input a.k.a. process noise corresponding to E disturbance matrix
+//   - wn:     output noise a.k.a. measurement noise
+//
+// It returns error if either of the following conditions is met:
+//   - invalid model is given: model dimensions must be positive integers
+//   - invalid state or output noise is given: noise covariance must either be nil or match the model dimensions
+//   - output noise covariance is not invertible
+//   - DARE fails to be solved for the given model and noise
+func NewSteady(m filter.DiscreteModel, z, wn filter.Noise) (*SteadyKF, error) {
+	nx, _, ny, _ := m.SystemDims()
+	if nx <= 0 || ny <= 0 {
+		return nil, fmt.Errorf("invalid model dimensions: [%d x %d]", nx, ny)
+	}
+
+	if wn == nil || wn.Cov().SymmetricDim() != ny {
+		return nil, fmt.Errorf("invalid output noise")
+	}
+
+	qCov := mat.NewSymDense(nx, nil)
+	if z != nil {
+		zCov, err := filter.StateNoiseCov(m, z.Cov())
+		if err != nil {
+			return nil, fmt.Errorf("invalid state noise: %v", err)
+		}
+		qCov.CopySym(zCov)
+	}
+
+	pPred, err := SolveDARE(m.SystemMatrix(), m.OutputMatrix(), qCov, wn.Cov())
+	if err != nil {
+		return nil, fmt.Errorf("failed to solve DARE: %v", err)
+	}
+
+	f, err := New(m, &steadyInit{state: mat.NewVecDense(nx, nil), cov: pPred}, z, wn)
+	if err != nil {
+		return nil, err
+	}
+
+	// P*C'
+	pxy := &mat.Dense{}
+	pxy.Mul(pPred, m.OutputMatrix().T())
+	// C*P*C' + R
+	pyy := &mat.Dense{}
+	pyy.Mul(m.OutputMatrix(), pxy)
+	pyy.Add(pyy, wn.Cov())
+
+	// steady-state Kalman gain
+	pyyInv := &mat.Dense{}
+	if err := pyyInv.Inverse(pyy); err != nil {
+		return nil, fmt.Errorf("failed to calculate Pyy inverse: %v", err)
+	}
+	gain := &mat.Dense{}
+	gain.Mul(pxy, pyyInv)
+
+	// steady-state corrected covariance
+	pCorr, err := kalman.UpdateCov(f.CovUpdate, pPred, gain, m.OutputMatrix(), wn.Cov())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update covariance: %v", err)
+	}
+
+	f.p.CopySym(pCorr)
+	f.pNext.CopySym(pPred)
+	f.k.Copy(gain)
+
+	return &SteadyKF{
+		kf: f,
+	}, nil
+}
+
+// Predict calculates the next system state given the state x and input u and returns its estimate.
+// The returned estimate carries the steady-state predicted covariance.
+// It returns error if it fails to propagate x to the next step.
+func (k *SteadyKF) Predict(x, u mat.Vector) (filter.Estimate, error) {
+	// propagate input state to the next step
+	xNext, err := k.kf.m.Propagate(x, u, k.kf.q.Sample())
+	if err != nil {
+		return nil, fmt.Errorf("system state propagation failed: %v", err)
+	}
+
+	return estimate.NewBaseWithCov(xNext, k.kf.pNext)
+}
+
+// Update corrects state x using the measurement ym, given control intput u and returns corrected estimate.
+// It uses the steady-state Kalman gain and returns the estimate with the steady-state corrected covariance.
+// Missing measurement elements can be marked with NaN: Kalman gain for the observed elements is then
+// calculated from the steady-state predicted covariance, but the steady-state gain and covariance are not modified.
+// If the measurement is missing entirely (nil or all NaN) x is not corrected.
+// It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
+func (k *SteadyKF) Update(x, u, ym mat.Vector) (filter.Estimate, error) {
+	_, _, ny, _ := k.kf.m.SystemDims()
+
+	if ym != nil && ym.Len() != ny {
+		return nil, fmt.Errorf("invalid measurement supplied: %v", ym)
+	}
+
+	// observe system output in the next step
+	yNext, err := k.kf.m.Observe(x, u, k.kf.r.Sample())
+	if err != nil {
+		return nil, fmt.Errorf("failed to observe system output: %v", err)
+	}
+
+	// indices of observed measurement elements
+	idx := filter.Observed(ym)
+	switch {
+	case len(idx) == 0:
+		// no measurement: keep predicted covariance
+		k.kf.inn.Zero()
+		return estimate.NewBaseWithCov(x, k.kf.pNext)
+	case len(idx) < ny:
+		gain, inn, pCorr, err := k.kf.correct(ym, yNext, idx)
+		if err != nil {
+			return nil, err
+		}
+
+		// update state x
+		corr := &mat.VecDense{}
+		corr.MulVec(gain, inn)
+		x.(*mat.VecDense).AddVec(x, corr)
+
+		k.kf.inn.Zero()
+		for i, r := range idx {
+			k.kf.inn.SetVec(r, inn.AtVec(i))
+		}
+
+		return estimate.NewBaseWithCov(x, pCorr)
+	}
+
+	// innovation vector
+	k.kf.inn.SubVec(ym, yNext)
+
+	// update state x
+	corr := &mat.VecDense{}
+	corr.MulVec(k.kf.k, k.kf.inn)
+	x.(*mat.VecDense).AddVec(x, corr)
+
+	return estimate.NewBaseWithCov(x, k.kf.p)
+}
+
+// Run runs one step of Steady-State KF for given state x, input u and measurement z.
+// It corrects system state x using measurement z and returns new system estimate.
+// It returns error if it either fails to propagate or correct state x.
+func (k *SteadyKF) Run(x, u, z mat.Vector) (filter.Estimate, error) {
+	pred, err := k.Predict(x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	est, err := k.Update(pred.Val(), u, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return est, nil
+}
+
+// Model returns Steady-State KF model
+func (k *SteadyKF) Model() filter.Model {
+	return k.kf.Model()
+}
+
+// StateNoise retruns state noise
+func (k *SteadyKF) StateNoise() filter.Noise {
+	return k.kf.StateNoise()
+}
+
+// OutputNoise retruns output noise
+func (k *SteadyKF) OutputNoise() filter.Noise {
+	return k.kf.OutputNoise()
+}
+
+// Cov returns steady-state corrected covariance
+func (k *SteadyKF) Cov() mat.Symmetric {
+	return k.kf.Cov()
+}
+
+// Gain returns steady-state Kalman gain.
+func (k *SteadyKF) Gain() mat.Matrix {
+	return k.kf.Gain()
+}
+
+// Innov returns innovation vector of the last update.
+func (k *SteadyKF) Innov() mat.Vector {
+	return k.kf.Innov()
+}
+
+// SolveDARE solves the filter Discrete Algebraic Riccati Equation
+//
+//	P = A*P*A' - A*P*C'*(C*P*C' + R)^-1*C*P*A' + Q
+//
+// using the Structure-preserving Doubling Algorithm (SDA) and returns the predicted covariance P.
+// It returns error if R is not invertible or if the algorithm fails to converge.
+func SolveDARE(a, c mat.Matrix, q, r mat.Symmetric) (*mat.SymDense, error) {
+	nx, _ := a.Dims()
+
+	rInv := &mat.Dense{}
+	if err := rInv.Inverse(r); err != nil {
+		return nil, fmt.Errorf("failed to invert output noise covariance: %v", err)
+	}
+
+	// A0 = A'
+	ak := mat.DenseCopyOf(a.T())
+	// G0 = C'*R^-1*C
+	cr := &mat.Dense{}
+	cr.Mul(c.T(), rInv)
+	gk := &mat.Dense{}
+	gk.Mul(cr, c)
+	// H0 = Q
+	hk := mat.DenseCopyOf(q)
+
+	eye := mat.NewDiagDense(nx, nil)
+	for i := 0; i < nx; i++ {
+		eye.SetDiag(i, 1.0)
+	}
+
+	w := &mat.Dense{}
+	wInv := &mat.Dense{}
+	aw := &mat.Dense{}
+	tmp := &mat.Dense{}
+	diff := &mat.Dense{}
+
+	for i := 0; i < dareMaxIter; i++ {
+		// W = I + G*H
+		w.Mul(gk, hk)
+		w.Add(eye, w)
+		if err := wInv.Inverse(w); err != nil {
+			return nil, fmt.Errorf("failed to invert SDA matrix: %v", err)
+		}
+		// A*W^-1
+		aw.Mul(ak, wInv)
+
+		// H = H + A'*H*W^-1*A
+		hNext := &mat.Dense{}
+		tmp.Mul(hk, wInv)
+		tmp.Mul(tmp, ak)
+		hNext.Mul(ak.T(), tmp)
+		hNext.Add(hk, hNext)
+
+		// G = G + A*W^-1*G*A'
+		gNext := &mat.Dense{}
+		tmp.Mul(aw, gk)
+		gNext.Mul(tmp, ak.T())
+		gNext.Add(gk, gNext)
+
+		// A = A*W^-1*A
+		aNext := &mat.Dense{}
+		aNext.Mul(aw, ak)
+
+		diff.Sub(hNext, hk)
+		converged := mat.Norm(diff, 1) <= dareTol*mat.Norm(hNext, 1)
+
+		ak, gk, hk = aNext, gNext, hNext
+
+		if converged {
+			p := mat.NewSymDense(nx, nil)
+			for i := 0; i < nx; i++ {
+				for j := i; j < nx; j++ {
+					p.SetSym(i, j, 0.5*(hk.At(i, j)+hk.At(j, i)))
+				}
+			}
+
+			return p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to converge in %d iterations", dareMaxIter)
+}
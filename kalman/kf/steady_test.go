@@ -0,0 +1,155 @@
+package kf
+
+import (
+	"testing"
+
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SteadyKF is a Kalman filter
+var _ kalman.Kalman = (*SteadyKF)(nil)
+
+func TestNewSteadyKF(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := NewSteady(okModel, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// zero state noise
+	f, err = NewSteady(okModel, nil, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// output noise must be given
+	f, err = NewSteady(okModel, q, nil)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// output noise covariance must be invertible
+	_r, _ := noise.NewZero(1)
+	f, err = NewSteady(okModel, q, _r)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid state noise dimension
+	_q, _ := noise.NewZero(20)
+	f, err = NewSteady(okModel, _q, r)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid model: negative dimensions
+	badModel.nx, badModel.ny = -10, 20
+	f, err = NewSteady(badModel, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+}
+
+func TestSolveDARE(t *testing.T) {
+	assert := assert.New(t)
+
+	A := okModel.SystemMatrix()
+	C := okModel.OutputMatrix()
+
+	p, err := SolveDARE(A, C, q.Cov(), r.Cov())
+	assert.NotNil(p)
+	assert.NoError(err)
+
+	// P = A*P*A' - A*P*C'*(C*P*C' + R)^-1*C*P*A' + Q
+	apa := &mat.Dense{}
+	apa.Mul(A, p)
+	apa.Mul(apa, A.T())
+
+	pc := &mat.Dense{}
+	pc.Mul(p, C.T())
+	s := &mat.Dense{}
+	s.Mul(C, pc)
+	s.Add(s, r.Cov())
+	sInv := &mat.Dense{}
+	assert.NoError(sInv.Inverse(s))
+
+	apc := &mat.Dense{}
+	apc.Mul(A, pc)
+	apcs := &mat.Dense{}
+	apcs.Mul(apc, sInv)
+	corr := &mat.Dense{}
+	corr.Mul(apcs, apc.T())
+
+	res := &mat.Dense{}
+	res.Sub(apa, corr)
+	res.Add(res, q.Cov())
+	assert.True(mat.EqualApprox(res, p, 1e-9))
+
+	// singular output noise covariance
+	p, err = SolveDARE(A, C, q.Cov(), mat.NewSymDense(1, nil))
+	assert.Nil(p)
+	assert.Error(err)
+}
+
+func TestSteadyKFMatchesKF(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := NewSteady(okModel, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	k, err := New(okModel, ic, q, r)
+	assert.NotNil(k)
+	assert.NoError(err)
+
+	// KF gain converges to steady-state gain
+	x := mat.VecDenseCopyOf(ic.State())
+	for i := 0; i < 100; i++ {
+		_, err = k.Run(mat.VecDenseCopyOf(x), u, z)
+		assert.NoError(err)
+	}
+
+	assert.True(mat.EqualApprox(f.Gain(), k.Gain(), 1e-9))
+	assert.True(mat.EqualApprox(f.Cov(), k.Cov(), 1e-9))
+}
+
+func TestSteadyKFRun(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := NewSteady(okModel, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	gain := f.Gain()
+	cov := f.Cov()
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Run(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// gain and covariance stay fixed
+	assert.True(mat.Equal(gain, f.Gain()))
+	assert.True(mat.Equal(cov, f.Cov()))
+	assert.True(mat.Equal(cov, est.Cov()))
+	assert.NotNil(f.Model())
+	assert.NotNil(f.StateNoise())
+	assert.NotNil(f.OutputNoise())
+	assert.Equal(1, f.Innov().Len())
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid state vector
+	_x := mat.NewVecDense(3, nil)
+	est, err = f.Update(_x, u, z)
+	assert.Nil(est)
+	assert.Error(err)
+}
@@ -0,0 +1,46 @@
+package kalman
+
+import (
+	"gonum.org/v1/gonum/mat"
+)
+
+// SelectRows returns a new matrix which contains rows of m with indices idx.
+// It panics if idx is empty.
+func SelectRows(m mat.Matrix, idx []int) *mat.Dense {
+	_, cols := m.Dims()
+	rows := mat.NewDense(len(idx), cols, nil)
+
+	for i, r := range idx {
+		for j := 0; j < cols; j++ {
+			rows.Set(i, j, m.At(r, j))
+		}
+	}
+
+	return rows
+}
+
+// SelectVec returns a new vector which contains elements of v with indices idx.
+// It panics if idx is empty.
+func SelectVec(v mat.Vector, idx []int) *mat.VecDense {
+	vec := mat.NewVecDense(len(idx), nil)
+
+	for i, r := range idx {
+		vec.SetVec(i, v.AtVec(r))
+	}
+
+	return vec
+}
+
+// SelectSym returns a new symmetric matrix which contains rows and columns of s with indices idx.
+// It panics if idx is empty.
+func SelectSym(s mat.Symmetric, idx []int) *mat.SymDense {
+	sym := mat.NewSymDense(len(idx), nil)
+
+	for i, r := range idx {
+		for j := i; j < len(idx); j++ {
+			sym.SetSym(i, j, s.At(r, idx[j]))
+		}
+	}
+
+	return sym
+}
@@ -0,0 +1,25 @@
+package kalman
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestSelect(t *testing.T) {
+	assert := assert.New(t)
+
+	m := mat.NewDense(3, 2, []float64{1, 2, 3, 4, 5, 6})
+	rows := SelectRows(m, []int{0, 2})
+	assert.True(mat.Equal(rows, mat.NewDense(2, 2, []float64{1, 2, 5, 6})))
+
+	v := mat.NewVecDense(3, []float64{1, 2, 3})
+	vec := SelectVec(v, []int{2})
+	assert.True(mat.Equal(vec, mat.NewVecDense(1, []float64{3})))
+
+	s := mat.NewSymDense(3, []float64{1, 2, 3, 2, 4, 5, 3, 5, 6})
+	sym := SelectSym(s, []int{0, 2})
+	assert.True(mat.Equal(sym, mat.NewSymDense(2, []float64{1, 3, 3, 6})))
+
+}
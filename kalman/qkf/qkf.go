@@ -0,0 +1,302 @@
+package qkf
+
+import (
+	"fmt"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+	"github.com/milosgajdos/go-estimate/noise"
+	"gonum.org/v1/gonum/mat"
+)
+
+// QKF is quadrature Kalman Filter.
+// It approximates the state distribution by fixed weighted points of standard normal distribution
+// which are transformed to match the state mean and covariance, so it implements any deterministic
+// sampling filter whose points don't depend on the filter state, such as cubature or Gauss-Hermite filters.
+// State and output noise are assumed to be additive.
+type QKF struct {
+	// m is QKF system model
+	m filter.Model
+	// q is state noise a.k.a. process noise
+	q filter.Noise
+	// r is output noise a.k.a. measurement noise
+	r filter.Noise
+	// unit stores quadrature points of standard normal distribution
+	unit *sigma.Points
+	// qp stores quadrature points generated around the predicted state
+	qp *mat.Dense
+	// p is the QKF covariance matrix
+	p *mat.SymDense
+	// pNext is the QKF predicted covariance matrix
+	pNext *mat.SymDense
+	// inn is innovation vector
+	inn *mat.VecDense
+	// k is Kalman gain
+	k *mat.Dense
+}
+
+// New creates new QKF and returns it.
+// It accepts the following parameters:
+// - m:      dynamical system model
+// - init:   initial condition of the filter
+// - q:      state a.k.a. process noise
+// - r:      output a.k.a. measurement noise
+// - unit:   quadrature points of standard normal distribution of the state dimension
+// It returns error if either of the following conditions is met:
+// - invalid model is given: model dimensions must be positive integers
+// - invalid state or output noise is given: noise covariance must either be nil or match the model dimensions
+// - invalid quadrature points are given: points must have the state dimension and a weight per point
+func New(m filter.Model, init filter.InitCond, q, r filter.Noise, unit *sigma.Points) (*QKF, error) {
+	// size of the input and output vectors
+	nx, _, ny, _ := m.SystemDims()
+	if nx <= 0 || ny <= 0 {
+		return nil, fmt.Errorf("invalid model dimensions: [%d x %d]", nx, ny)
+	}
+
+	if q != nil {
+		if _, err := filter.StateNoiseCov(m, q.Cov()); err != nil {
+			return nil, fmt.Errorf("invalid state noise: %v", err)
+		}
+	} else {
+		q, _ = noise.NewNone()
+	}
+
+	if r != nil {
+		if r.Cov().SymmetricDim() != ny {
+			return nil, fmt.Errorf("invalid output noise dimension: %d", r.Cov().SymmetricDim())
+		}
+	} else {
+		r, _ = noise.NewNone()
+	}
+
+	if unit == nil || unit.X == nil {
+		return nil, fmt.Errorf("invalid quadrature points: %v", unit)
+	}
+
+	rows, cols := unit.X.Dims()
+	if rows != nx || len(unit.Wm) != cols || len(unit.Wc) != cols {
+		return nil, fmt.Errorf("invalid quadrature points dimensions: [%d x %d]", rows, cols)
+	}
+
+	// predicted quadrature points
+	qp := mat.NewDense(nx, cols, nil)
+
+	// predicted covariance; this covariance is corrected using new measurement
+	pNext := mat.NewSymDense(init.Cov().SymmetricDim(), nil)
+	pNext.CopySym(init.Cov())
+
+	// initialize covariance matrix to initial condition covariance
+	p := mat.NewSymDense(init.Cov().SymmetricDim(), nil)
+	p.CopySym(init.Cov())
+
+	// innovation vector
+	inn := mat.NewVecDense(ny, nil)
+
+	// kalman gain
+	k := mat.NewDense(nx, ny, nil)
+
+	return &QKF{
+		m:     m,
+		q:     q,
+		r:     r,
+		unit:  unit,
+		qp:    qp,
+		p:     p,
+		pNext: pNext,
+		inn:   inn,
+		k:     k,
+	}, nil
+}
+
+// GenPoints generates QKF quadrature points around x using the QKF covariance and returns them.
+// It returns error if it fails to generate new quadrature points due to covariance Cholesky factorization failure.
+func (k *QKF) GenPoints(x mat.Vector) (*sigma.Points, error) {
+	return sigma.Transform(k.unit, x, k.p)
+}
+
+// Points returns quadrature points generated around the predicted state in the last Update.
+func (k *QKF) Points() *sigma.Points {
+	return &sigma.Points{
+		X:  mat.DenseCopyOf(k.qp),
+		Wm: append([]float64(nil), k.unit.Wm...),
+		Wc: append([]float64(nil), k.unit.Wc...),
+	}
+}
+
+// Predict calculates the next system state given the state x and input u and returns its estimate.
+// It first generates new quadrature points around x and then attempts to propagate them to the next step.
+// Predicted state is the mean of the propagated quadrature points.
+// It returns error if it either fails to generate or propagate the quadrature points to the next step.
+func (k *QKF) Predict(x, u mat.Vector) (filter.Estimate, error) {
+	nx, _, _, _ := k.m.SystemDims()
+
+	qp, err := k.GenPoints(x)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate quadrature points: %v", err)
+	}
+
+	// propagated quadrature points
+	xPts, err := sigma.Propagate(k.m, qp.X, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propagate quadrature points: %v", err)
+	}
+
+	xMean := sigma.Mean(xPts, k.unit.Wm)
+	cov := sigma.CrossCov(xPts, xMean, xPts, xMean, k.unit.Wc)
+
+	if _, ok := k.q.(*noise.None); !ok {
+		qCov, err := filter.StateNoiseCov(k.m, k.q.Cov())
+		if err != nil {
+			return nil, fmt.Errorf("failed to map state noise: %v", err)
+		}
+		cov.Add(cov, qCov)
+	}
+
+	// update QKF predicted covariance matrix
+	for i := 0; i < nx; i++ {
+		for j := i; j < nx; j++ {
+			k.pNext.SetSym(i, j, cov.At(i, j))
+		}
+	}
+
+	return estimate.NewBaseWithCov(xMean, k.pNext)
+}
+
+// Update corrects state x using the measurement z, given control intput u and returns corrected estimate.
+// x is expected to be the predicted state: new quadrature points are generated around it using the predicted covariance.
+// Missing measurement elements can be marked with NaN: only the observed elements are then used to correct x.
+// If the measurement is missing entirely (nil or all NaN) x is not corrected and the predicted covariance is kept.
+// It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
+func (k *QKF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
+	nx, _, ny, _ := k.m.SystemDims()
+
+	if z != nil && z.Len() != ny {
+		return nil, fmt.Errorf("invalid measurement supplied: %v", z)
+	}
+
+	k.inn.Zero()
+	k.k.Zero()
+
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: keep predicted covariance
+		k.p.CopySym(k.pNext)
+		return estimate.NewBaseWithCov(x, k.p)
+	}
+
+	qp, err := sigma.Transform(k.unit, x, k.pNext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate quadrature points: %v", err)
+	}
+
+	// observed quadrature points
+	yPts, err := sigma.Observe(k.m, qp.X, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to observe quadrature points: %v", err)
+	}
+
+	// keep only the outputs of observed measurement elements
+	yPts = kalman.SelectRows(yPts, idx)
+
+	xMean := sigma.Mean(qp.X, k.unit.Wm)
+	yMean := sigma.Mean(yPts, k.unit.Wm)
+
+	pxy := sigma.CrossCov(qp.X, xMean, yPts, yMean, k.unit.Wc)
+	pyy := sigma.CrossCov(yPts, yMean, yPts, yMean, k.unit.Wc)
+	if _, ok := k.r.(*noise.None); !ok {
+		pyy.Add(pyy, kalman.SelectSym(k.r.Cov(), idx))
+	}
+
+	// calculate Kalman gain and correct QKF covariance
+	gain, pCorr, err := sigma.Correct(k.pNext, pxy, pyy)
+	if err != nil {
+		return nil, err
+	}
+
+	// innovation vector
+	inn := &mat.VecDense{}
+	inn.SubVec(kalman.SelectVec(z, idx), yMean)
+
+	// update state x
+	corr := &mat.VecDense{}
+	corr.MulVec(gain, inn)
+	x.(*mat.VecDense).AddVec(x, corr)
+
+	// update QKF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
+	}
+	// update QKF covariance matrix
+	k.p.CopySym(pCorr)
+	k.qp.Copy(qp.X)
+
+	return estimate.NewBaseWithCov(x, k.p)
+}
+
+// Run runs one step of QKF for given state x, input u and measurement z.
+// It corrects system state x using measurement z and returns new system estimate.
+// It returns error if it either fails to propagate or correct state x.
+func (k *QKF) Run(x, u, z mat.Vector) (filter.Estimate, error) {
+	pred, err := k.Predict(x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	est, err := k.Update(pred.Val(), u, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return est, nil
+}
+
+// Model returns QKF model
+func (k *QKF) Model() filter.Model {
+	return k.m
+}
+
+// StateNoise retruns state noise
+func (k *QKF) StateNoise() filter.Noise {
+	return k.q
+}
+
+// OutputNoise retruns output noise
+func (k *QKF) OutputNoise() filter.Noise {
+	return k.r
+}
+
+// Cov returns QKF covariance
+func (k *QKF) Cov() mat.Symmetric {
+	cov := mat.NewSymDense(k.p.SymmetricDim(), nil)
+	cov.CopySym(k.p)
+
+	return cov
+}
+
+// SetCov sets QKF covariance matrix to cov.
+// It returns error if either cov is nil or its dimensions are not the same as QKF covariance dimensions.
+func (k *QKF) SetCov(cov mat.Symmetric) error {
+	if cov == nil {
+		return fmt.Errorf("invalid covariance matrix: %v", cov)
+	}
+
+	if cov.SymmetricDim() != k.p.SymmetricDim() {
+		return fmt.Errorf("invalid covariance matrix dims: [%d x %d]", cov.SymmetricDim(), cov.SymmetricDim())
+	}
+
+	k.p.CopySym(cov)
+
+	return nil
+}
+
+// Gain returns Kalman gain
+func (k *QKF) Gain() mat.Matrix {
+	gain := &mat.Dense{}
+	gain.CloneFrom(k.k)
+
+	return gain
+}
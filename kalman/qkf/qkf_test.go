@@ -0,0 +1,317 @@
+package qkf
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/kalman/kf"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+type invalidModel struct {
+	filter.Model
+}
+
+func (m *invalidModel) SystemDims() (nx, nu, ny, nz int) {
+	return -10, 0, 8, 0
+}
+
+var (
+	okModel  *sim.BaseModel
+	badModel *invalidModel
+	ic       *sim.InitCond
+	q        filter.Noise
+	r        filter.Noise
+	u        *mat.VecDense
+	z        *mat.VecDense
+	unit     *sigma.Points
+)
+
+func setup() {
+	u = mat.NewVecDense(1, []float64{-1.0})
+	z = mat.NewVecDense(1, []float64{-1.5})
+
+	// initial condition
+	initState := mat.NewVecDense(2, []float64{1.0, 3.0})
+	initCov := mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25})
+	ic = sim.NewInitCond(initState, initCov)
+
+	// state and output noise
+	q, _ = noise.NewGaussian([]float64{0, 0}, initCov)
+	r, _ = noise.NewGaussian([]float64{0}, mat.NewSymDense(1, []float64{0.25}))
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	B := mat.NewDense(2, 1, []float64{0.5, 1.0})
+	C := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	D := mat.NewDense(1, 1, []float64{0.0})
+
+	okModel = &sim.BaseModel{A: A, B: B, C: C, D: D}
+	badModel = &invalidModel{okModel}
+
+	unit, _ = sigma.GaussHermite(2, 3)
+}
+
+func TestMain(m *testing.M) {
+	// set up tests
+	setup()
+	// run the tests
+	retCode := m.Run()
+	// call with result of m.Run()
+	os.Exit(retCode)
+}
+
+func TestQKFNew(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	var _ kalman.Kalman = f
+
+	// invalid model: incorrect dimensions
+	f, err = New(badModel, ic, q, r, unit)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid state noise dimension
+	_q, _ := noise.NewZero(20)
+	f, err = New(okModel, ic, _q, r, unit)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid output noise dimension
+	_r, _ := noise.NewZero(20)
+	f, err = New(okModel, ic, q, _r, unit)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid quadrature points
+	_unit, _ := sigma.Cubature(3)
+	for _, pts := range []*sigma.Points{nil, {}, _unit, {X: unit.X, Wm: unit.Wm[1:], Wc: unit.Wc}} {
+		f, err = New(okModel, ic, q, r, pts)
+		assert.Nil(f)
+		assert.Error(err)
+	}
+
+	// zero [state and output] noise
+	f, err = New(okModel, ic, nil, nil, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+}
+
+func TestQKFGenPoints(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	cov := mat.NewSymDense(2, []float64{0.5, 0.1, 0.1, 0.3})
+	assert.NoError(f.SetCov(cov))
+
+	x := mat.VecDenseCopyOf(ic.State())
+	qp, err := f.GenPoints(x)
+	assert.NotNil(qp)
+	assert.NoError(err)
+
+	rows, cols := qp.X.Dims()
+	assert.Equal(2, rows)
+	assert.Equal(9, cols)
+
+	// quadrature points match the mean and covariance
+	m := sigma.Mean(qp.X, qp.Wm)
+	assert.True(mat.EqualApprox(x, m, 1e-12))
+	assert.True(mat.EqualApprox(cov, sigma.CrossCov(qp.X, m, qp.X, m, qp.Wc), 1e-12))
+
+	// covariance which is not positive definite
+	assert.NoError(f.SetCov(mat.NewSymDense(2, []float64{1, 2, 2, 1})))
+	qp, err = f.GenPoints(x)
+	assert.Nil(qp)
+	assert.Error(err)
+}
+
+func TestQKFPredict(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Predict(x, u)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Predict(x, _u)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestQKFUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	qp := f.Points()
+	_, cols := qp.X.Dims()
+	assert.Equal(9, cols)
+	assert.Len(qp.Wm, 9)
+
+	// missing measurement
+	for _, _z := range []mat.Vector{nil, mat.NewVecDense(1, []float64{math.NaN()})} {
+		xm := mat.VecDenseCopyOf(x)
+		est, err = f.Update(xm, u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.True(mat.Equal(x, est.Val()))
+	}
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestQKFRun(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Run(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestQKFLinear(t *testing.T) {
+	assert := assert.New(t)
+
+	// both cubature and quadrature rules are exact for linear models so QKF matches KF
+	cubature, _ := sigma.Cubature(2)
+	for _, pts := range []*sigma.Points{cubature, unit} {
+		f, err := New(okModel, ic, q, r, pts)
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		lkf, err := kf.New(okModel, ic, q, r)
+		assert.NotNil(lkf)
+		assert.NoError(err)
+
+		x := mat.VecDenseCopyOf(ic.State())
+		xkf := mat.VecDenseCopyOf(ic.State())
+		for i := 0; i < 5; i++ {
+			est, err := f.Predict(x, u)
+			assert.NoError(err)
+			estKF, err := lkf.Predict(xkf, u)
+			assert.NoError(err)
+			assert.True(mat.EqualApprox(estKF.Cov(), est.Cov(), 1e-9))
+
+			x = mat.VecDenseCopyOf(est.Val())
+			xkf = mat.VecDenseCopyOf(estKF.Val())
+
+			est, err = f.Update(x, u, z)
+			assert.NoError(err)
+			estKF, err = lkf.Update(xkf, u, z)
+			assert.NoError(err)
+			assert.True(mat.EqualApprox(estKF.Cov(), est.Cov(), 1e-9))
+			assert.True(mat.EqualApprox(lkf.Gain(), f.Gain(), 1e-9))
+
+			x = mat.VecDenseCopyOf(est.Val())
+			xkf = mat.VecDenseCopyOf(estKF.Val())
+		}
+	}
+}
+
+func TestQKFModel(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	m := f.Model()
+	assert.NotNil(m)
+}
+
+func TestQKFNoise(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	sn := f.StateNoise()
+	assert.NotNil(sn)
+
+	on := f.OutputNoise()
+	assert.NotNil(on)
+}
+
+func TestQKFCov(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	cov := f.Cov()
+	assert.NotNil(cov)
+
+	err = f.SetCov(nil)
+	assert.Error(err)
+
+	err = f.SetCov(mat.NewSymDense(30, nil))
+	assert.Error(err)
+
+	err = f.SetCov(mat.NewSymDense(f.p.SymmetricDim(), nil))
+	assert.NoError(err)
+}
+
+func TestQKFGain(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, unit)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	gain := f.Gain()
+	assert.NotNil(gain)
+}
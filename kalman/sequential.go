@@ -0,0 +1,86 @@
+package kalman
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// SequentialUpdate processes measurement elements one at a time as a sequence of scalar updates.
+// It accepts predicted covariance p, observation matrix h and diagonal measurement noise covariance r.
+// r can be nil if there is no measurement noise.
+// Every scalar update uses the covariance and state corrected by the preceding measurement elements,
+// so no matrix inversion is needed and the result is the same as the batch update for diagonal r.
+// It returns Kalman gain of the batch update and corrected covariance calculated using the update strategy c.
+// State correction is then given by gain*inn where inn is the innovation vector calculated from the predicted system output.
+// It returns error if r is not diagonal, if any innovation covariance is not positive or if covariance update fails.
+func SequentialUpdate(c CovUpdate, p mat.Symmetric, h mat.Matrix, r mat.Symmetric) (*mat.Dense, *mat.SymDense, error) {
+	ny, nx := h.Dims()
+
+	if r != nil && r.SymmetricDim() > 0 {
+		if r.SymmetricDim() != ny {
+			return nil, nil, fmt.Errorf("invalid measurement noise dimension: %d != %d", r.SymmetricDim(), ny)
+		}
+		for i := 0; i < ny; i++ {
+			for j := i + 1; j < ny; j++ {
+				if r.At(i, j) != 0 {
+					return nil, nil, fmt.Errorf("measurement noise covariance is not diagonal")
+				}
+			}
+		}
+	} else {
+		r = nil
+	}
+
+	pCorr := mat.NewSymDense(nx, nil)
+	pCorr.CopySym(p)
+
+	// seqGain stores gains of the individual scalar updates
+	seqGain := mat.NewDense(nx, ny, nil)
+
+	// P*h'
+	ph := mat.NewVecDense(nx, nil)
+
+	hRows := make([]*mat.Dense, ny)
+	for i := 0; i < ny; i++ {
+		hRow := SelectRows(h, []int{i})
+		hVec := hRow.RowView(0)
+		hRows[i] = hRow
+
+		// innovation covariance: h*P*h' + r
+		ph.MulVec(pCorr, hVec)
+		s := mat.Dot(hVec, ph)
+		var rCov mat.Symmetric
+		if r != nil {
+			s += r.At(i, i)
+			rCov = mat.NewSymDense(1, []float64{r.At(i, i)})
+		}
+		if s <= 0 {
+			return nil, nil, fmt.Errorf("invalid innovation covariance of element %d: %v", i, s)
+		}
+
+		k := seqGain.Slice(0, nx, i, i+1).(*mat.Dense)
+		k.Scale(1/s, ph)
+
+		cov, err := UpdateCov(c, pCorr, k, hRow, rCov)
+		if err != nil {
+			return nil, nil, err
+		}
+		pCorr.CopySym(cov)
+	}
+
+	// Innovation of the i-th element given the state corrected by the preceding elements is
+	// inn[i] - h[i]*sum(seqGain[j]*seqInn[j]) for j < i, i.e. inn = L*seqInn where L is unit lower
+	// triangular with L[i][j] = h[i]*seqGain[j]. Batch gain K = seqGain*L^-1 is found by back substitution.
+	gain := mat.NewDense(nx, ny, nil)
+	for i := ny - 1; i >= 0; i-- {
+		k := mat.VecDenseCopyOf(seqGain.ColView(i))
+		for j := i + 1; j < ny; j++ {
+			l := mat.Dot(hRows[j].RowView(0), seqGain.ColView(i))
+			k.AddScaledVec(k, -l, gain.ColView(j))
+		}
+		gain.SetCol(i, k.RawVector().Data)
+	}
+
+	return gain, pCorr, nil
+}
@@ -0,0 +1,65 @@
+package kalman
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestSequentialUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	p := mat.NewSymDense(2, []float64{1.0, 0.5, 0.5, 2.0})
+	h := mat.NewDense(2, 2, []float64{1.0, 0.0, 0.5, 1.0})
+	r := mat.NewSymDense(2, []float64{0.25, 0.0, 0.0, 0.5})
+	inn := mat.NewVecDense(2, []float64{1.0, -0.5})
+
+	// batch update: K = P*H'*(H*P*H' + R)^-1
+	pxy := &mat.Dense{}
+	pxy.Mul(p, h.T())
+	pyy := &mat.Dense{}
+	pyy.Mul(h, pxy)
+	pyy.Add(pyy, r)
+	pyyInv := &mat.Dense{}
+	assert.NoError(pyyInv.Inverse(pyy))
+	k := &mat.Dense{}
+	k.Mul(pxy, pyyInv)
+	corr := &mat.VecDense{}
+	corr.MulVec(k, inn)
+
+	for _, c := range []CovUpdate{Joseph, Simple, Stabilized} {
+		exp, err := UpdateCov(c, p, k, h, r)
+		assert.NoError(err, c.String())
+
+		gain, cov, err := SequentialUpdate(c, p, h, r)
+		assert.NoError(err, c.String())
+		assert.True(mat.EqualApprox(exp, cov, 1e-12), c.String())
+		assert.True(mat.EqualApprox(k, gain, 1e-12), c.String())
+
+		seqCorr := &mat.VecDense{}
+		seqCorr.MulVec(gain, inn)
+		assert.True(mat.EqualApprox(corr, seqCorr, 1e-12), c.String())
+	}
+
+	// no measurement noise
+	gain, cov, err := SequentialUpdate(Joseph, p, h, nil)
+	assert.NotNil(gain)
+	assert.NotNil(cov)
+	assert.NoError(err)
+
+	// non-diagonal measurement noise
+	_r := mat.NewSymDense(2, []float64{0.25, 0.1, 0.1, 0.5})
+	gain, cov, err = SequentialUpdate(Joseph, p, h, _r)
+	assert.Nil(gain)
+	assert.Nil(cov)
+	assert.Error(err)
+
+	// invalid measurement noise dimension
+	_r = mat.NewSymDense(3, nil)
+	_r.SetSym(0, 0, 0.25)
+	gain, cov, err = SequentialUpdate(Joseph, p, h, _r)
+	assert.Nil(gain)
+	assert.Nil(cov)
+	assert.Error(err)
+}
@@ -0,0 +1,277 @@
+package sigma
+
+import (
+	"fmt"
+	"math"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"gonum.org/v1/gonum/mat"
+)
+
+// MaxPoints is the maximum number of tensor product quadrature points GaussHermite generates
+const MaxPoints = 1 << 16
+
+// Points are weighted points which approximate a probability distribution
+type Points struct {
+	// X stores the points in its columns
+	X *mat.Dense
+	// Wm are the weights used to calculate mean of the points
+	Wm []float64
+	// Wc are the weights used to calculate covariance of the points
+	Wc []float64
+}
+
+// Cubature returns third degree spherical-radial cubature points of n dimensional standard normal distribution.
+// There are 2n equally weighted points sqrt(n)*[I -I].
+// It returns error if n is not positive.
+func Cubature(n int) (*Points, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid dimension: %d", n)
+	}
+
+	x := mat.NewDense(n, 2*n, nil)
+	w := make([]float64, 2*n)
+	for i := 0; i < n; i++ {
+		x.Set(i, i, math.Sqrt(float64(n)))
+		x.Set(i, n+i, -math.Sqrt(float64(n)))
+		w[i], w[n+i] = 1/float64(2*n), 1/float64(2*n)
+	}
+
+	return &Points{
+		X:  x,
+		Wm: w,
+		Wc: w,
+	}, nil
+}
+
+// GaussHermite returns tensor product Gauss-Hermite quadrature points of given order
+// of n dimensional standard normal distribution. There are order^n points which integrate exactly
+// polynomials of degree up to 2*order-1 in every dimension.
+// It returns error if either n or order is not positive, order^n exceeds MaxPoints
+// or the one dimensional quadrature fails to be calculated.
+func GaussHermite(n, order int) (*Points, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid dimension: %d", n)
+	}
+
+	if order <= 0 {
+		return nil, fmt.Errorf("invalid quadrature order: %d", order)
+	}
+
+	count := 1
+	for i := 0; i < n; i++ {
+		if count > MaxPoints/order {
+			return nil, fmt.Errorf("too many quadrature points: %d^%d exceeds %d", order, n, MaxPoints)
+		}
+		count *= order
+	}
+
+	nodes, weights, err := gaussHermite1D(order)
+	if err != nil {
+		return nil, err
+	}
+
+	x := mat.NewDense(n, count, nil)
+	w := make([]float64, count)
+
+	// idx is multi-index of 1D nodes of the current point
+	idx := make([]int, n)
+	for c := 0; c < count; c++ {
+		w[c] = 1.0
+		for i := 0; i < n; i++ {
+			x.Set(i, c, nodes[idx[i]])
+			w[c] *= weights[idx[i]]
+		}
+
+		// advance the multi-index
+		for i := 0; i < n; i++ {
+			idx[i]++
+			if idx[i] < order {
+				break
+			}
+			idx[i] = 0
+		}
+	}
+
+	return &Points{
+		X:  x,
+		Wm: w,
+		Wc: w,
+	}, nil
+}
+
+// gaussHermite1D calculates nodes and weights of one dimensional Gauss-Hermite quadrature
+// of standard normal distribution using Golub-Welsch algorithm: the nodes are the eigenvalues
+// of the Jacobi matrix of probabilists' Hermite polynomials and the weights are squared
+// first elements of its normalized eigenvectors.
+func gaussHermite1D(order int) ([]float64, []float64, error) {
+	jacobi := mat.NewSymDense(order, nil)
+	for i := 1; i < order; i++ {
+		jacobi.SetSym(i-1, i, math.Sqrt(float64(i)))
+	}
+
+	var eig mat.EigenSym
+	if ok := eig.Factorize(jacobi, true); !ok {
+		return nil, nil, fmt.Errorf("failed to calculate Gauss-Hermite nodes")
+	}
+
+	nodes := eig.Values(nil)
+	vecs := &mat.Dense{}
+	eig.VectorsTo(vecs)
+
+	weights := make([]float64, order)
+	for i := range weights {
+		weights[i] = vecs.At(0, i) * vecs.At(0, i)
+	}
+
+	return nodes, weights, nil
+}
+
+// Transform transforms points p of standard normal distribution to the points of distribution
+// with mean x and covariance cov: the points are x + S*p where S is the Cholesky factor of cov.
+// It returns error if cov fails to be factorized or its dimension does not match the points.
+func Transform(p *Points, x mat.Vector, cov mat.Symmetric) (*Points, error) {
+	rows, cols := p.X.Dims()
+	if x.Len() != rows || cov.SymmetricDim() != rows {
+		return nil, fmt.Errorf("invalid dimensions: points %d, mean %d, covariance %d", rows, x.Len(), cov.SymmetricDim())
+	}
+
+	sqrt, err := Sqrt(cov)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &mat.Dense{}
+	out.Mul(sqrt, p.X)
+	for c := 0; c < cols; c++ {
+		col := out.Slice(0, rows, c, c+1).(*mat.Dense)
+		col.Add(col, x)
+	}
+
+	return &Points{
+		X:  out,
+		Wm: p.Wm,
+		Wc: p.Wc,
+	}, nil
+}
+
+// Sqrt returns lower triangular Cholesky factor of cov.
+// It returns error if cov is not positive definite.
+func Sqrt(cov mat.Symmetric) (*mat.TriDense, error) {
+	var chol mat.Cholesky
+	if ok := chol.Factorize(cov); !ok {
+		return nil, fmt.Errorf("Cholesky factorization failed")
+	}
+
+	sqrt := &mat.TriDense{}
+	chol.LTo(sqrt)
+
+	return sqrt, nil
+}
+
+// Mean calculates weighted mean of the columns of x with weights w and returns it.
+func Mean(x *mat.Dense, w []float64) *mat.VecDense {
+	rows, cols := x.Dims()
+
+	m := mat.NewVecDense(rows, nil)
+	for c := 0; c < cols; c++ {
+		m.AddScaledVec(m, w[c], x.ColView(c))
+	}
+
+	return m
+}
+
+// CrossCov calculates weighted cross-covariance of the columns of x and y with means xMean and yMean
+// and weights w and returns it. Covariance of x is returned if y is the same as x.
+func CrossCov(x *mat.Dense, xMean mat.Vector, y *mat.Dense, yMean mat.Vector, w []float64) *mat.Dense {
+	rows, cols := x.Dims()
+	yRows, _ := y.Dims()
+
+	cov := mat.NewDense(rows, yRows, nil)
+
+	dx := mat.NewVecDense(rows, nil)
+	dy := mat.NewVecDense(yRows, nil)
+	outer := mat.NewDense(rows, yRows, nil)
+
+	for c := 0; c < cols; c++ {
+		dx.SubVec(x.ColView(c), xMean)
+		dy.SubVec(y.ColView(c), yMean)
+		outer.Outer(w[c], dx, dy)
+		cov.Add(cov, outer)
+	}
+
+	return cov
+}
+
+// Correct calculates Kalman gain from state and output cross-covariance pxy and output covariance pyy
+// and uses it to correct covariance p as P - K*Pyy*K'. It returns the gain and the corrected covariance.
+// It returns error if pyy fails to be inverted.
+func Correct(p mat.Symmetric, pxy, pyy mat.Matrix) (*mat.Dense, *mat.SymDense, error) {
+	pyyInv := &mat.Dense{}
+	if err := pyyInv.Inverse(pyy); err != nil {
+		return nil, nil, fmt.Errorf("failed to calculat Pyy inverse: %v", err)
+	}
+
+	gain := &mat.Dense{}
+	gain.Mul(pxy, pyyInv)
+
+	// K*Pyy*K'
+	kp := &mat.Dense{}
+	kp.Mul(gain, pyy)
+	kpk := &mat.Dense{}
+	kpk.Mul(kp, gain.T())
+
+	n := p.SymmetricDim()
+	pCorr := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			pCorr.SetSym(i, j, p.At(i, j)-0.5*(kpk.At(i, j)+kpk.At(j, i)))
+		}
+	}
+
+	return gain, pCorr, nil
+}
+
+// Propagate propagates the points x through the model m given input u and returns the propagated points.
+// It returns error if any of the points fails to be propagated.
+func Propagate(m filter.Model, x *mat.Dense, u mat.Vector) (*mat.Dense, error) {
+	nx, _, _, _ := m.SystemDims()
+	_, cols := x.Dims()
+
+	out := mat.NewDense(nx, cols, nil)
+	for c := 0; c < cols; c++ {
+		xNext, err := m.Propagate(x.ColView(c), u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to propagate point %d: %v", c, err)
+		}
+
+		if xNext.Len() != nx {
+			return nil, fmt.Errorf("invalid propagated point length: %d", xNext.Len())
+		}
+		out.Slice(0, nx, c, c+1).(*mat.Dense).Copy(xNext)
+	}
+
+	return out, nil
+}
+
+// Observe observes the outputs of the points x through the model m given input u and returns them.
+// It returns error if any of the point outputs fails to be observed.
+func Observe(m filter.Model, x *mat.Dense, u mat.Vector) (*mat.Dense, error) {
+	_, _, ny, _ := m.SystemDims()
+	_, cols := x.Dims()
+
+	out := mat.NewDense(ny, cols, nil)
+	for c := 0; c < cols; c++ {
+		y, err := m.Observe(x.ColView(c), u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to observe point %d output: %v", c, err)
+		}
+
+		if y.Len() != ny {
+			return nil, fmt.Errorf("invalid observed point output length: %d", y.Len())
+		}
+		out.Slice(0, ny, c, c+1).(*mat.Dense).Copy(y)
+	}
+
+	return out, nil
+}
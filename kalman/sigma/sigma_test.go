@@ -0,0 +1,196 @@
+package sigma
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+// errModel fails to propagate and observe any state
+type errModel struct {
+	filter.Model
+}
+
+func (m *errModel) Propagate(x, u, wd mat.Vector) (mat.Vector, error) {
+	return nil, fmt.Errorf("propagation failed")
+}
+
+func (m *errModel) Observe(x, u, wn mat.Vector) (mat.Vector, error) {
+	return nil, fmt.Errorf("observation failed")
+}
+
+func TestCubature(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := Cubature(3)
+	assert.NotNil(p)
+	assert.NoError(err)
+
+	rows, cols := p.X.Dims()
+	assert.Equal(3, rows)
+	assert.Equal(6, cols)
+
+	m := Mean(p.X, p.Wm)
+	assert.True(mat.EqualApprox(mat.NewVecDense(3, nil), m, 1e-12))
+	cov := CrossCov(p.X, m, p.X, m, p.Wc)
+	assert.True(mat.EqualApprox(eye(3), cov, 1e-12))
+
+	p, err = Cubature(0)
+	assert.Nil(p)
+	assert.Error(err)
+}
+
+func TestGaussHermite(t *testing.T) {
+	assert := assert.New(t)
+
+	// three point rule
+	p, err := GaussHermite(1, 3)
+	assert.NotNil(p)
+	assert.NoError(err)
+
+	nodes := mat.Row(nil, 0, p.X)
+	assert.InDelta(-math.Sqrt(3), nodes[0], 1e-12)
+	assert.InDelta(0.0, nodes[1], 1e-12)
+	assert.InDelta(math.Sqrt(3), nodes[2], 1e-12)
+	assert.InDelta(1.0/6, p.Wm[0], 1e-12)
+	assert.InDelta(2.0/3, p.Wm[1], 1e-12)
+	assert.InDelta(1.0/6, p.Wm[2], 1e-12)
+
+	// tensor product rule matches standard normal moments
+	p, err = GaussHermite(2, 4)
+	assert.NotNil(p)
+	assert.NoError(err)
+
+	rows, cols := p.X.Dims()
+	assert.Equal(2, rows)
+	assert.Equal(16, cols)
+
+	m := Mean(p.X, p.Wm)
+	assert.True(mat.EqualApprox(mat.NewVecDense(2, nil), m, 1e-12))
+	cov := CrossCov(p.X, m, p.X, m, p.Wc)
+	assert.True(mat.EqualApprox(eye(2), cov, 1e-12))
+
+	// fourth and sixth moments
+	m4, m6 := 0.0, 0.0
+	for c := 0; c < cols; c++ {
+		m4 += p.Wm[c] * math.Pow(p.X.At(0, c), 4)
+		m6 += p.Wm[c] * math.Pow(p.X.At(1, c), 6)
+	}
+	assert.InDelta(3.0, m4, 1e-10)
+	assert.InDelta(15.0, m6, 1e-10)
+
+	// invalid parameters
+	p, err = GaussHermite(0, 3)
+	assert.Nil(p)
+	assert.Error(err)
+
+	p, err = GaussHermite(2, 0)
+	assert.Nil(p)
+	assert.Error(err)
+
+	// too many points: the largest allowed rule is generated, anything above is rejected
+	p, err = GaussHermite(16, 2)
+	assert.NotNil(p)
+	assert.NoError(err)
+
+	for _, n := range []int{17, 1000} {
+		p, err = GaussHermite(n, 2)
+		assert.Nil(p)
+		assert.Error(err)
+	}
+
+	p, err = GaussHermite(3, 1<<30)
+	assert.Nil(p)
+	assert.Error(err)
+}
+
+func TestTransform(t *testing.T) {
+	assert := assert.New(t)
+
+	unit, err := GaussHermite(2, 3)
+	assert.NoError(err)
+
+	x := mat.NewVecDense(2, []float64{1.0, -2.0})
+	cov := mat.NewSymDense(2, []float64{0.5, 0.2, 0.2, 0.3})
+
+	p, err := Transform(unit, x, cov)
+	assert.NotNil(p)
+	assert.NoError(err)
+
+	m := Mean(p.X, p.Wm)
+	assert.True(mat.EqualApprox(x, m, 1e-12))
+	assert.True(mat.EqualApprox(cov, CrossCov(p.X, m, p.X, m, p.Wc), 1e-12))
+
+	// covariance which is not positive definite
+	p, err = Transform(unit, x, mat.NewSymDense(2, []float64{1, 2, 2, 1}))
+	assert.Nil(p)
+	assert.Error(err)
+
+	// invalid dimensions
+	p, err = Transform(unit, mat.NewVecDense(3, nil), cov)
+	assert.Nil(p)
+	assert.Error(err)
+}
+
+func TestCorrect(t *testing.T) {
+	assert := assert.New(t)
+
+	p := mat.NewSymDense(2, []float64{1.0, 0.5, 0.5, 2.0})
+	pxy := mat.NewDense(2, 1, []float64{1.0, 0.5})
+	pyy := mat.NewDense(1, 1, []float64{2.0})
+
+	gain, pCorr, err := Correct(p, pxy, pyy)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(mat.NewDense(2, 1, []float64{0.5, 0.25}), gain, 1e-12))
+	exp := mat.NewSymDense(2, []float64{0.5, 0.25, 0.25, 1.875})
+	assert.True(mat.EqualApprox(exp, pCorr, 1e-12))
+
+	// singular output covariance
+	gain, pCorr, err = Correct(p, pxy, mat.NewDense(1, 1, nil))
+	assert.Nil(gain)
+	assert.Nil(pCorr)
+	assert.Error(err)
+}
+
+func TestPropagateObserve(t *testing.T) {
+	assert := assert.New(t)
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	C := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	m := &sim.BaseModel{A: A, C: C}
+
+	x := mat.NewDense(2, 3, []float64{
+		1, 2, 3,
+		4, 5, 6,
+	})
+
+	xNext, err := Propagate(m, x, nil)
+	assert.NoError(err)
+	assert.True(mat.Equal(mat.NewDense(2, 3, []float64{5, 7, 9, 4, 5, 6}), xNext))
+
+	y, err := Observe(m, x, nil)
+	assert.NoError(err)
+	assert.True(mat.Equal(mat.NewDense(1, 3, []float64{1, 2, 3}), y))
+
+	xNext, err = Propagate(&errModel{m}, x, nil)
+	assert.Nil(xNext)
+	assert.Error(err)
+
+	y, err = Observe(&errModel{m}, x, nil)
+	assert.Nil(y)
+	assert.Error(err)
+}
+
+func eye(n int) *mat.Dense {
+	e := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		e.Set(i, i, 1.0)
+	}
+
+	return e
+}
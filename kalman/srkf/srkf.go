@@ -0,0 +1,442 @@
+package srkf
+
+import (
+	"fmt"
+	"math"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/noise"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SRKF is Square Root Kalman Filter.
+// Instead of the state covariance matrix P it propagates its square root
+// factor S such that P = S*S' which guarantees P remains positive semi-definite.
+type SRKF struct {
+	// m is SRKF system model
+	m filter.DiscreteModel
+	// q is state noise a.k.a. process noise
+	q filter.Noise
+	// r is output noise a.k.a. measurement noise
+	r filter.Noise
+	// sq is square root of state noise covariance
+	sq *mat.Dense
+	// sr is square root of output noise covariance
+	sr *mat.Dense
+	// s is the SRKF covariance square root factor
+	s *mat.Dense
+	// sNext is the SRKF predicted covariance square root factor
+	sNext *mat.Dense
+	// inn is innovation vector
+	inn *mat.VecDense
+	// k is Kalman gain
+	k *mat.Dense
+}
+
+// New creates new SRKF and returns it.
+// It accepts the following parameters:
+//   - m:      dynamical system model
+//   - init:   initial condition of the filter
+//   - z:      disturbance input a.k.a. process noise corresponding to E disturbance matrix
+//   - wn:     output noise a.k.a. measurement noise
+//
+// It returns error if either of the following conditions is met:
+//   - invalid model is given: model dimensions must be positive integers
+//   - invalid state or output noise is given: noise covariance must either be nil or match the model dimensions
+//   - initial condition or noise covariance fail to be factorized
+func New(m filter.DiscreteModel, init filter.InitCond, z, wn filter.Noise) (*SRKF, error) {
+	// size of the input and output vectors
+	nx, _, ny, _ := m.SystemDims()
+	if nx <= 0 || ny <= 0 {
+		return nil, fmt.Errorf("invalid model dimensions: [%d x %d]", nx, ny)
+	}
+
+	if z != nil {
+		if z.Cov().SymmetricDim() != nx {
+			return nil, fmt.Errorf("invalid state noise dimension: %d", z.Cov().SymmetricDim())
+		}
+	} else {
+		z, _ = noise.NewNone()
+	}
+
+	if wn != nil {
+		if wn.Cov().SymmetricDim() != ny {
+			return nil, fmt.Errorf("invalid output noise dimension: %d", wn.Cov().SymmetricDim())
+		}
+	} else {
+		wn, _ = noise.NewNone()
+	}
+
+	rows, cols := m.SystemMatrix().Dims()
+	if rows != nx || cols != nx {
+		return nil, fmt.Errorf("invalid propagation matrix dimensions: [%d x %d]", rows, cols)
+	}
+
+	rows, cols = m.OutputMatrix().Dims()
+	if rows != ny || cols != nx {
+		return nil, fmt.Errorf("invalid observation matrix dimensions: [%d x %d]", rows, cols)
+	}
+
+	if init.Cov().SymmetricDim() != nx {
+		return nil, fmt.Errorf("invalid initial condition covariance dimension: %d", init.Cov().SymmetricDim())
+	}
+
+	// square root of the initial condition covariance
+	s, err := SqrtCov(init.Cov())
+	if err != nil {
+		return nil, fmt.Errorf("failed to factorize initial covariance: %v", err)
+	}
+
+	// square roots of noise covariances
+	var sq, sr *mat.Dense
+	if _, ok := z.(*noise.None); !ok {
+		if sq, err = SqrtCov(z.Cov()); err != nil {
+			return nil, fmt.Errorf("failed to factorize state noise covariance: %v", err)
+		}
+	}
+
+	if _, ok := wn.(*noise.None); !ok {
+		if sr, err = SqrtCov(wn.Cov()); err != nil {
+			return nil, fmt.Errorf("failed to factorize output noise covariance: %v", err)
+		}
+	}
+
+	// predicted covariance square root
+	sNext := mat.NewDense(nx, nx, nil)
+	sNext.Copy(s)
+
+	// innovation vector
+	inn := mat.NewVecDense(ny, nil)
+
+	// kalman gain
+	k := mat.NewDense(nx, ny, nil)
+
+	return &SRKF{
+		m:     m,
+		q:     z,
+		r:     wn,
+		sq:    sq,
+		sr:    sr,
+		s:     s,
+		sNext: sNext,
+		inn:   inn,
+		k:     k,
+	}, nil
+}
+
+// Predict calculates the next system state given the state x and input u and returns its estimate.
+// It propagates the covariance square root factor by triangularizing the [A*S, Sq] matrix.
+// It returns error if it fails to propagate either x or the covariance square root to the next step.
+func (k *SRKF) Predict(x, u mat.Vector) (filter.Estimate, error) {
+	nx, _, _, _ := k.m.SystemDims()
+
+	// propagate input state to the next step
+	xNext, err := k.m.Propagate(x, u, k.q.Sample())
+	if err != nil {
+		return nil, fmt.Errorf("system state propagation failed: %v", err)
+	}
+
+	nq := 0
+	if k.sq != nil {
+		_, nq = k.sq.Dims()
+	}
+
+	// pre-array: [A*S, Sq]
+	pre := mat.NewDense(nx, nx+nq, nil)
+	pre.Slice(0, nx, 0, nx).(*mat.Dense).Mul(k.m.SystemMatrix(), k.s)
+	if k.sq != nil {
+		pre.Slice(0, nx, nx, nx+nq).(*mat.Dense).Copy(k.sq)
+	}
+
+	sNext, err := LowerTri(pre)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propagate covariance square root: %v", err)
+	}
+
+	// update SRKF predicted covariance square root
+	k.sNext.Copy(sNext)
+
+	cov := mat.NewSymDense(nx, nil)
+	cov.SymOuterK(1.0, sNext)
+
+	return estimate.NewBaseWithCov(xNext, cov)
+}
+
+// Update corrects state x using the measurement ym, given control intput u and returns corrected estimate.
+// It computes Kalman gain and the corrected covariance square root by triangularizing the pre-array:
+//
+//	[ Sr  C*S ]
+//	[ 0   S   ]
+//
+// Missing measurement elements can be marked with NaN: only the observed elements are then used to correct x.
+// If the measurement is missing entirely (nil or all NaN) x is not corrected and the predicted covariance is kept.
+// It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
+func (k *SRKF) Update(x, u, ym mat.Vector) (filter.Estimate, error) {
+	nx, _, ny, _ := k.m.SystemDims()
+
+	if ym != nil && ym.Len() != ny {
+		return nil, fmt.Errorf("invalid measurement supplied: %v", ym)
+	}
+
+	// observe system output in the next step
+	yNext, err := k.m.Observe(x, u, k.r.Sample())
+	if err != nil {
+		return nil, fmt.Errorf("failed to observe system output: %v", err)
+	}
+
+	k.inn.Zero()
+	k.k.Zero()
+
+	// indices of observed measurement elements
+	idx := filter.Observed(ym)
+	if len(idx) == 0 {
+		// no measurement: keep predicted covariance
+		k.s.Copy(k.sNext)
+		return estimate.NewBaseWithCov(x, k.Cov())
+	}
+	no := len(idx)
+
+	// observation matrix rows and output noise covariance square root of observed measurement elements
+	h := kalman.SelectRows(k.m.OutputMatrix(), idx)
+	sr := k.sr
+	if sr != nil && no < ny {
+		if sr, err = SqrtCov(kalman.SelectSym(k.r.Cov(), idx)); err != nil {
+			return nil, fmt.Errorf("failed to factorize output noise covariance: %v", err)
+		}
+	}
+
+	pre := mat.NewDense(no+nx, no+nx, nil)
+	if sr != nil {
+		pre.Slice(0, no, 0, no).(*mat.Dense).Copy(sr)
+	}
+	pre.Slice(0, no, no, no+nx).(*mat.Dense).Mul(h, k.sNext)
+	pre.Slice(no, no+nx, no, no+nx).(*mat.Dense).Copy(k.sNext)
+
+	// post-array:
+	// [ Sy  0 ]
+	// [ Kb  S ]
+	post, err := LowerTri(pre)
+	if err != nil {
+		return nil, fmt.Errorf("failed to triangularize update pre-array: %v", err)
+	}
+
+	sy := mat.NewTriDense(no, mat.Lower, nil)
+	sy.Copy(post.Slice(0, no, 0, no))
+	kb := post.Slice(no, no+nx, 0, no)
+
+	// calculate Kalman gain: K = Kb * Sy^-1 i.e. Sy' * K' = Kb'
+	gainT := &mat.Dense{}
+	if err := sy.SolveTo(gainT, true, kb.T()); err != nil {
+		return nil, fmt.Errorf("failed to calculate Kalman gain: %v", err)
+	}
+	gain := mat.DenseCopyOf(gainT.T())
+
+	// innovation vector
+	inn := &mat.VecDense{}
+	inn.SubVec(kalman.SelectVec(ym, idx), kalman.SelectVec(yNext, idx))
+
+	// update state x
+	corr := &mat.VecDense{}
+	corr.MulVec(gain, inn)
+	x.(*mat.VecDense).AddVec(x, corr)
+
+	// update SRKF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
+	}
+	// update SRKF covariance square root
+	k.s.Copy(post.Slice(no, no+nx, no, no+nx))
+
+	return estimate.NewBaseWithCov(x, k.Cov())
+}
+
+// Run runs one step of SRKF for given state x, input u and measurement z.
+// It corrects system state x using measurement z and returns new system estimate.
+// It returns error if it either fails to propagate or correct state x.
+func (k *SRKF) Run(x, u, z mat.Vector) (filter.Estimate, error) {
+	pred, err := k.Predict(x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	est, err := k.Update(pred.Val(), u, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return est, nil
+}
+
+// Model returns SRKF model
+func (k *SRKF) Model() filter.Model {
+	return k.m
+}
+
+// StateNoise retruns state noise
+func (k *SRKF) StateNoise() filter.Noise {
+	return k.q
+}
+
+// OutputNoise retruns output noise
+func (k *SRKF) OutputNoise() filter.Noise {
+	return k.r
+}
+
+// Cov returns SRKF covariance
+func (k *SRKF) Cov() mat.Symmetric {
+	rows, _ := k.s.Dims()
+	cov := mat.NewSymDense(rows, nil)
+	cov.SymOuterK(1.0, k.s)
+
+	return cov
+}
+
+// SetCov sets SRKF covariance matrix to cov.
+// It returns error if either cov is nil, its dimensions are not the same as SRKF covariance dimensions
+// or if it fails to be factorized.
+func (k *SRKF) SetCov(cov mat.Symmetric) error {
+	if cov == nil {
+		return fmt.Errorf("invalid covariance matrix: %v", cov)
+	}
+
+	rows, _ := k.s.Dims()
+	if cov.SymmetricDim() != rows {
+		return fmt.Errorf("invalid covariance matrix dims: [%d x %d]", cov.SymmetricDim(), cov.SymmetricDim())
+	}
+
+	s, err := SqrtCov(cov)
+	if err != nil {
+		return fmt.Errorf("failed to factorize covariance: %v", err)
+	}
+
+	k.s.Copy(s)
+
+	return nil
+}
+
+// SqrtCov returns SRKF covariance square root factor
+func (k *SRKF) SqrtCov() mat.Matrix {
+	s := &mat.Dense{}
+	s.CloneFrom(k.s)
+
+	return s
+}
+
+// Gain returns Kalman gain
+func (k *SRKF) Gain() mat.Matrix {
+	gain := &mat.Dense{}
+	gain.CloneFrom(k.k)
+
+	return gain
+}
+
+// SqrtCov computes a lower triangular square root S of the covariance matrix cov such that cov = S*S' and returns it.
+// Covariance matrices are often only positive semi-definite so it falls back to SVD if Cholesky factorization fails.
+// It returns error if cov fails to be factorized.
+func SqrtCov(cov mat.Symmetric) (*mat.Dense, error) {
+	var chol mat.Cholesky
+	if ok := chol.Factorize(cov); ok {
+		l := &mat.TriDense{}
+		chol.LTo(l)
+
+		return mat.DenseCopyOf(l), nil
+	}
+
+	var svd mat.SVD
+	if ok := svd.Factorize(cov, mat.SVDFull); !ok {
+		return nil, fmt.Errorf("SVD factorization failed")
+	}
+
+	u := &mat.Dense{}
+	svd.UTo(u)
+	vals := svd.Values(nil)
+	for i := range vals {
+		vals[i] = math.Sqrt(vals[i])
+	}
+	u.Mul(u, mat.NewDiagDense(len(vals), vals))
+
+	// U*sqrt(D) is a valid, but not triangular, square root: triangularize it
+	return LowerTri(u)
+}
+
+// LowerTri computes a lower triangular matrix L such that L*L' = A*A' and returns it.
+// A must have at least as many columns as it has rows. L is computed from QR factorization of A'
+// and its diagonal is made non-negative.
+// It returns error if A has fewer columns than rows.
+func LowerTri(a mat.Matrix) (*mat.Dense, error) {
+	rows, cols := a.Dims()
+	if cols < rows {
+		return nil, fmt.Errorf("invalid matrix dimensions: [%d x %d]", rows, cols)
+	}
+
+	var qr mat.QR
+	qr.Factorize(a.T())
+
+	r := &mat.Dense{}
+	qr.RTo(r)
+
+	l := mat.NewDense(rows, rows, nil)
+	l.Copy(r.Slice(0, rows, 0, rows).T())
+
+	// flip the signs of the columns with negative diagonal elements
+	for j := 0; j < rows; j++ {
+		if l.At(j, j) < 0 {
+			for i := j; i < rows; i++ {
+				l.Set(i, j, -l.At(i, j))
+			}
+		}
+	}
+
+	return l, nil
+}
+
+// CholUpdate computes a lower triangular matrix L1 such that L1*L1' = L*L' + x*x' and returns it.
+// It returns error if L is not a square matrix or x has invalid dimension.
+func CholUpdate(l mat.Matrix, x mat.Vector) (*mat.Dense, error) {
+	rows, cols := l.Dims()
+	if rows != cols || x.Len() != rows {
+		return nil, fmt.Errorf("invalid dimensions: [%d x %d], %d", rows, cols, x.Len())
+	}
+
+	pre := mat.NewDense(rows, cols+1, nil)
+	pre.Slice(0, rows, 0, cols).(*mat.Dense).Copy(l)
+	pre.Slice(0, rows, cols, cols+1).(*mat.Dense).Copy(x)
+
+	return LowerTri(pre)
+}
+
+// CholDowndate computes a lower triangular matrix L1 such that L1*L1' = L*L' - x*x' and returns it.
+// L must be lower triangular. L1 is computed using a sequence of hyperbolic rotations.
+// It returns error if L is not a square matrix, x has invalid dimension or if L*L' - x*x' is not positive definite.
+func CholDowndate(l mat.Matrix, x mat.Vector) (*mat.Dense, error) {
+	rows, cols := l.Dims()
+	if rows != cols || x.Len() != rows {
+		return nil, fmt.Errorf("invalid dimensions: [%d x %d], %d", rows, cols, x.Len())
+	}
+
+	l1 := mat.DenseCopyOf(l)
+	v := mat.Col(nil, 0, x)
+
+	for k := 0; k < rows; k++ {
+		lkk := l1.At(k, k)
+		r2 := lkk*lkk - v[k]*v[k]
+		if r2 <= 0 || lkk == 0 {
+			return nil, fmt.Errorf("downdated matrix is not positive definite")
+		}
+
+		r := math.Sqrt(r2)
+		c, s := r/lkk, v[k]/lkk
+		l1.Set(k, k, r)
+
+		for i := k + 1; i < rows; i++ {
+			lik := (l1.At(i, k) - s*v[i]) / c
+			l1.Set(i, k, lik)
+			v[i] = c*v[i] - s*lik
+		}
+	}
+
+	return l1, nil
+}
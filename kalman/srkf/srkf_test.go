@@ -0,0 +1,393 @@
+package srkf
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman/kf"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+type invalidModel struct {
+	filter.DiscreteModel
+	nx int
+	nu int
+	ny int
+}
+
+func (m *invalidModel) SystemDims() (nx, nu, ny, nz int) {
+	return m.nx, m.nu, m.ny, 0
+}
+
+var (
+	okModel  *sim.BaseModel
+	badModel *invalidModel
+	ic       *sim.InitCond
+	q        filter.Noise
+	r        filter.Noise
+	u        *mat.VecDense
+	z        *mat.VecDense
+)
+
+func setup() {
+	u = mat.NewVecDense(1, []float64{-1.0})
+	z = mat.NewVecDense(1, []float64{-1.5})
+
+	// initial condition
+	initState := mat.NewVecDense(2, []float64{1.0, 3.0})
+	initCov := mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25})
+	ic = sim.NewInitCond(initState, initCov)
+
+	// state and output noise
+	q, _ = noise.NewGaussian([]float64{0, 0}, initCov)
+	r, _ = noise.NewGaussian([]float64{0}, mat.NewSymDense(1, []float64{0.25}))
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	B := mat.NewDense(2, 1, []float64{0.5, 1.0})
+	C := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	D := mat.NewDense(1, 1, []float64{0.0})
+
+	okModel = &sim.BaseModel{A: A, B: B, C: C, D: D}
+	badModel = &invalidModel{DiscreteModel: okModel, nx: 10, ny: 10}
+}
+
+func TestMain(m *testing.M) {
+	// set up tests
+	setup()
+	// run the tests
+	retCode := m.Run()
+	// call with result of m.Run()
+	os.Exit(retCode)
+}
+
+func TestSRKFNew(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NoError(err)
+	assert.NotNil(f)
+
+	// invalid model: negative dimensions
+	badModel.nx, badModel.ny = -10, 20
+	f, err = New(badModel, ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid state noise dimension
+	_q := q
+	q, _ = noise.NewZero(20)
+	f, err = New(okModel, ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+	q = _q
+
+	// invalid output noise dimension
+	_r := r
+	r, _ = noise.NewZero(20)
+	f, err = New(okModel, ic, q, r)
+	assert.Nil(f)
+	assert.Error(err)
+	r = _r
+
+	// zero [state and output] noise
+	f, err = New(okModel, ic, nil, nil)
+	assert.NotNil(f)
+	assert.NoError(err)
+}
+
+func TestSRKFPredict(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Predict(x, u)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Predict(x, _u)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestSRKFUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+// meanNoise is Gaussian noise which always samples its mean
+type meanNoise struct {
+	*noise.Gaussian
+}
+
+func newMeanNoise(cov mat.Symmetric) *meanNoise {
+	g, _ := noise.NewGaussian(make([]float64, cov.SymmetricDim()), cov)
+
+	return &meanNoise{Gaussian: g}
+}
+
+func (n *meanNoise) Sample() mat.Vector {
+	return mat.NewVecDense(n.Cov().SymmetricDim(), n.Mean())
+}
+
+func TestSRKFPartial(t *testing.T) {
+	assert := assert.New(t)
+
+	// model with two outputs and its reduction to the first output
+	C := mat.NewDense(2, 2, []float64{1.0, 0.0, 1.0, 1.0})
+	D := mat.NewDense(2, 1, []float64{0.0, 0.5})
+	m := &sim.BaseModel{A: okModel.A, B: okModel.B, C: C, D: D}
+	rm := &sim.BaseModel{A: okModel.A, B: okModel.B, C: mat.DenseCopyOf(C.Slice(0, 1, 0, 2)), D: mat.DenseCopyOf(D.Slice(0, 1, 0, 1))}
+
+	_r := newMeanNoise(mat.NewSymDense(2, []float64{0.25, 0.1, 0.1, 0.5}))
+	_rr := newMeanNoise(mat.NewSymDense(1, []float64{0.25}))
+	_z := mat.NewVecDense(2, []float64{-1.5, math.NaN()})
+
+	f, err := New(m, ic, q, _r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	fr, err := New(rm, ic, q, _rr)
+	assert.NotNil(fr)
+	assert.NoError(err)
+
+	// partial measurement is equivalent to the measurement of the reduced model
+	est, err := f.Update(mat.VecDenseCopyOf(ic.State()), u, _z)
+	assert.NotNil(est)
+	assert.NoError(err)
+	estR, err := fr.Update(mat.VecDenseCopyOf(ic.State()), u, z)
+	assert.NotNil(estR)
+	assert.NoError(err)
+
+	assert.True(mat.EqualApprox(estR.Val(), est.Val(), 1e-12))
+	assert.True(mat.EqualApprox(estR.Cov(), est.Cov(), 1e-12))
+
+	// gain of the missing measurement element is zero
+	gain := f.Gain()
+	assert.True(mat.EqualApprox(fr.Gain(), gain.(*mat.Dense).Slice(0, 2, 0, 1), 1e-12))
+	assert.Equal([]float64{0, 0}, mat.Col(nil, 1, gain))
+
+	// missing measurement: state is not corrected and predicted covariance is kept
+	pred, err := f.Predict(mat.VecDenseCopyOf(ic.State()), u)
+	assert.NotNil(pred)
+	assert.NoError(err)
+
+	for _, mz := range []mat.Vector{nil, mat.NewVecDense(2, []float64{math.NaN(), math.NaN()})} {
+		x := mat.VecDenseCopyOf(pred.Val())
+		est, err = f.Update(x, u, mz)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.True(mat.Equal(pred.Val(), est.Val()))
+		assert.True(mat.EqualApprox(pred.Cov(), est.Cov(), 1e-12))
+		assert.True(mat.EqualApprox(pred.Cov(), f.Cov(), 1e-12))
+		assert.Equal([]float64{0, 0}, mat.Col(nil, 1, f.Gain()))
+	}
+}
+
+func TestSRKFRun(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Run(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestSRKFMatchesKF(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	k, err := kf.New(okModel, ic, q, r)
+	assert.NotNil(k)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	for i := 0; i < 10; i++ {
+		_, err = f.Run(mat.VecDenseCopyOf(x), u, z)
+		assert.NoError(err)
+		_, err = k.Run(mat.VecDenseCopyOf(x), u, z)
+		assert.NoError(err)
+
+		assert.True(mat.EqualApprox(f.Cov(), k.Cov(), 1e-9))
+		assert.True(mat.EqualApprox(f.Gain(), k.Gain(), 1e-9))
+	}
+}
+
+func TestSRKFModel(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	m := f.Model()
+	assert.NotNil(m)
+}
+
+func TestSRKFNoise(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	sn := f.StateNoise()
+	assert.NotNil(sn)
+
+	on := f.OutputNoise()
+	assert.NotNil(on)
+}
+
+func TestSRKFCov(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	cov := f.Cov()
+	assert.NotNil(cov)
+	assert.True(mat.EqualApprox(cov, ic.Cov(), 1e-12))
+
+	s := f.SqrtCov()
+	assert.NotNil(s)
+
+	err = f.SetCov(nil)
+	assert.Error(err)
+
+	err = f.SetCov(mat.NewSymDense(30, nil))
+	assert.Error(err)
+
+	// singular covariance is factorized via SVD
+	err = f.SetCov(mat.NewSymDense(2, []float64{1, 1, 1, 1}))
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(f.Cov(), mat.NewSymDense(2, []float64{1, 1, 1, 1}), 1e-12))
+}
+
+func TestSRKFGain(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	gain := f.Gain()
+	assert.NotNil(gain)
+}
+
+func TestLowerTri(t *testing.T) {
+	assert := assert.New(t)
+
+	a := mat.NewDense(2, 3, []float64{1, 2, 3, 4, 5, 6})
+	l, err := LowerTri(a)
+	assert.NotNil(l)
+	assert.NoError(err)
+	assert.Equal(0.0, l.At(0, 1))
+
+	aa := &mat.Dense{}
+	aa.Mul(a, a.T())
+	ll := &mat.Dense{}
+	ll.Mul(l, l.T())
+	assert.True(mat.EqualApprox(aa, ll, 1e-12))
+
+	// not enough columns
+	l, err = LowerTri(a.T())
+	assert.Nil(l)
+	assert.Error(err)
+}
+
+func TestCholUpdateDowndate(t *testing.T) {
+	assert := assert.New(t)
+
+	cov := mat.NewSymDense(3, []float64{
+		4.0, 1.0, 0.5,
+		1.0, 3.0, 0.2,
+		0.5, 0.2, 2.0,
+	})
+	l, err := SqrtCov(cov)
+	assert.NoError(err)
+
+	x := mat.NewVecDense(3, []float64{0.5, -1.0, 0.3})
+	xx := &mat.Dense{}
+	xx.Outer(1.0, x, x)
+
+	// update
+	lu, err := CholUpdate(l, x)
+	assert.NotNil(lu)
+	assert.NoError(err)
+	assert.Equal(0.0, lu.At(0, 2))
+
+	exp := &mat.Dense{}
+	exp.Add(cov, xx)
+	ll := &mat.Dense{}
+	ll.Mul(lu, lu.T())
+	assert.True(mat.EqualApprox(exp, ll, 1e-12))
+
+	// downdate reverts the update
+	ld, err := CholDowndate(lu, x)
+	assert.NotNil(ld)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(l, ld, 1e-12))
+
+	// downdated matrix is not positive definite
+	ld, err = CholDowndate(l, mat.NewVecDense(3, []float64{3.0, 0.0, 0.0}))
+	assert.Nil(ld)
+	assert.Error(err)
+
+	// invalid dimensions
+	lu, err = CholUpdate(l, mat.NewVecDense(2, nil))
+	assert.Nil(lu)
+	assert.Error(err)
+
+	ld, err = CholDowndate(l, mat.NewVecDense(2, nil))
+	assert.Nil(ld)
+	assert.Error(err)
+}
@@ -0,0 +1,205 @@
+package ukf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SigmaPointGenerator generates UKF sigma points
+type SigmaPointGenerator interface {
+	// Generate returns sigma points of n dimensional standard normal distribution and their weights.
+	Generate(n int) (*sigma.Points, error)
+}
+
+// Symmetric generates scaled symmetric set of 2n+1 sigma points: the mean and a pair of points on every axis.
+type Symmetric struct {
+	// Alpha is alpha parameter (0,1]
+	Alpha float64
+	// Beta is beta parameter (2 is optimal choice for Gaussian)
+	Beta float64
+	// Kappa is kappa parameter (must be non-negative)
+	Kappa float64
+}
+
+// Generate returns sigma points of n dimensional standard normal distribution and their weights.
+// It returns error if n is not positive or the scaling parameters are invalid.
+func (s *Symmetric) Generate(n int) (*sigma.Points, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid dimension: %d", n)
+	}
+
+	// lambda is one of the unitless UKF parameters calculated using the config ones
+	lambda := s.Alpha*s.Alpha*(float64(n)+s.Kappa) - float64(n)
+	if float64(n)+lambda <= 0 {
+		return nil, fmt.Errorf("invalid sigma point parameters: alpha %f, kappa %f", s.Alpha, s.Kappa)
+	}
+
+	// gamma is the square root Sigma Point covariance scaling factor
+	gamma := math.Sqrt(float64(n) + lambda)
+
+	x := mat.NewDense(n, 2*n+1, nil)
+	wm := make([]float64, 2*n+1)
+	wc := make([]float64, 2*n+1)
+
+	// weight of the mean sigma point and its covariance
+	wm[0] = lambda / (float64(n) + lambda)
+	wc[0] = wm[0] + (1 - s.Alpha*s.Alpha + s.Beta)
+
+	// weight of the rest of sigma points and covariances
+	for i := 0; i < n; i++ {
+		x.Set(i, 1+i, gamma)
+		x.Set(i, 1+n+i, -gamma)
+		wm[1+i], wm[1+n+i] = 1/(2*(float64(n)+lambda)), 1/(2*(float64(n)+lambda))
+		wc[1+i], wc[1+n+i] = wm[1+i], wm[1+n+i]
+	}
+
+	return &sigma.Points{
+		X:  x,
+		Wm: wm,
+		Wc: wc,
+	}, nil
+}
+
+// Simplex generates Julier's minimal skew simplex set of n+2 sigma points.
+type Simplex struct {
+	// W0 is the weight of the mean sigma point [0,1)
+	W0 float64
+}
+
+// Generate returns sigma points of n dimensional standard normal distribution and their weights.
+// It returns error if n is not positive or W0 is not in [0,1).
+func (s *Simplex) Generate(n int) (*sigma.Points, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid dimension: %d", n)
+	}
+
+	if s.W0 < 0 || s.W0 >= 1 {
+		return nil, fmt.Errorf("invalid mean sigma point weight: %f", s.W0)
+	}
+
+	// weights: W1 = W2 = (1-W0)/2^n and Wi = 2^(i-2)*W1
+	w := make([]float64, n+2)
+	w[0] = s.W0
+	w[1] = (1 - s.W0) / math.Pow(2, float64(n))
+	w[2] = w[1]
+	for i := 3; i < n+2; i++ {
+		w[i] = math.Pow(2, float64(i-2)) * w[1]
+	}
+
+	// points are built recursively one dimension at a time
+	x := mat.NewDense(n, n+2, nil)
+	x.Set(0, 1, -1/math.Sqrt(2*w[1]))
+	x.Set(0, 2, 1/math.Sqrt(2*w[1]))
+	for j := 1; j < n; j++ {
+		for i := 1; i <= j+1; i++ {
+			x.Set(j, i, -1/math.Sqrt(2*w[j+2]))
+		}
+		x.Set(j, j+2, 1/math.Sqrt(2*w[j+2]))
+	}
+
+	return &sigma.Points{
+		X:  x,
+		Wm: w,
+		Wc: w,
+	}, nil
+}
+
+// SphericalSimplex generates spherical simplex set of n+2 sigma points
+// which all lie on a hypersphere centered at the mean sigma point.
+type SphericalSimplex struct {
+	// W0 is the weight of the mean sigma point [0,1)
+	W0 float64
+}
+
+// Generate returns sigma points of n dimensional standard normal distribution and their weights.
+// It returns error if n is not positive or W0 is not in [0,1).
+func (s *SphericalSimplex) Generate(n int) (*sigma.Points, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid dimension: %d", n)
+	}
+
+	if s.W0 < 0 || s.W0 >= 1 {
+		return nil, fmt.Errorf("invalid mean sigma point weight: %f", s.W0)
+	}
+
+	// all points but the mean one are equally weighted
+	w := make([]float64, n+2)
+	w[0] = s.W0
+	for i := 1; i < n+2; i++ {
+		w[i] = (1 - s.W0) / float64(n+1)
+	}
+
+	// points are built recursively one dimension at a time
+	x := mat.NewDense(n, n+2, nil)
+	x.Set(0, 1, -1/math.Sqrt(2*w[1]))
+	x.Set(0, 2, 1/math.Sqrt(2*w[1]))
+	for j := 1; j < n; j++ {
+		d := float64(j + 1)
+		for i := 1; i <= j+1; i++ {
+			x.Set(j, i, -1/math.Sqrt(d*(d+1)*w[1]))
+		}
+		x.Set(j, j+2, d/math.Sqrt(d*(d+1)*w[1]))
+	}
+
+	return &sigma.Points{
+		X:  x,
+		Wm: w,
+		Wc: w,
+	}, nil
+}
+
+// FifthOrder generates fifth degree set of 2n^2+1 sigma points: the mean, a pair of points on every axis
+// and four points in every plane spanned by a pair of axes. It integrates polynomials up to fifth degree exactly.
+// Note that the weights of axis points are negative for n > 4.
+type FifthOrder struct{}
+
+// Generate returns sigma points of n dimensional standard normal distribution and their weights.
+// It returns error if n is not positive.
+func (s *FifthOrder) Generate(n int) (*sigma.Points, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid dimension: %d", n)
+	}
+
+	nf := float64(n)
+	cols := 2*n*n + 1
+
+	x := mat.NewDense(n, cols, nil)
+	w := make([]float64, cols)
+
+	// mean sigma point
+	w[0] = 2 / (nf + 2)
+
+	// axis points
+	c := 1
+	for i := 0; i < n; i++ {
+		for _, sgn := range []float64{1, -1} {
+			x.Set(i, c, sgn*math.Sqrt(nf+2))
+			w[c] = (4 - nf) / (2 * (nf + 2) * (nf + 2))
+			c++
+		}
+	}
+
+	// plane points
+	r := math.Sqrt((nf + 2) / 2)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			for _, si := range []float64{1, -1} {
+				for _, sj := range []float64{1, -1} {
+					x.Set(i, c, si*r)
+					x.Set(j, c, sj*r)
+					w[c] = 1 / ((nf + 2) * (nf + 2))
+					c++
+				}
+			}
+		}
+	}
+
+	return &sigma.Points{
+		X:  x,
+		Wm: w,
+		Wc: w,
+	}, nil
+}
@@ -0,0 +1,140 @@
+package ukf
+
+import (
+	"math"
+	"testing"
+
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestSigmaPointGenerators(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		gen   SigmaPointGenerator
+		count func(n int) int
+	}{
+		{&Symmetric{Alpha: 0.75, Beta: 2.0, Kappa: 3.0}, func(n int) int { return 2*n + 1 }},
+		{&Simplex{W0: 0.2}, func(n int) int { return n + 2 }},
+		{&SphericalSimplex{W0: 0.2}, func(n int) int { return n + 2 }},
+		{&FifthOrder{}, func(n int) int { return 2*n*n + 1 }},
+	}
+
+	for _, tc := range testCases {
+		for _, n := range []int{1, 2, 5} {
+			p, err := tc.gen.Generate(n)
+			assert.NotNil(p)
+			assert.NoError(err)
+
+			rows, cols := p.X.Dims()
+			assert.Equal(n, rows)
+			assert.Equal(tc.count(n), cols)
+			assert.Len(p.Wm, cols)
+			assert.Len(p.Wc, cols)
+
+			// sigma points match standard normal mean and covariance
+			m := sigma.Mean(p.X, p.Wm)
+			assert.True(mat.EqualApprox(mat.NewVecDense(n, nil), m, 1e-12))
+			cov := sigma.CrossCov(p.X, m, p.X, m, p.Wm)
+			assert.True(mat.EqualApprox(eye(n), cov, 1e-12))
+		}
+
+		p, err := tc.gen.Generate(0)
+		assert.Nil(p)
+		assert.Error(err)
+	}
+
+	// fifth order set matches fourth moments
+	p, err := (&FifthOrder{}).Generate(3)
+	assert.NoError(err)
+	_, cols := p.X.Dims()
+	m4, m22 := 0.0, 0.0
+	for c := 0; c < cols; c++ {
+		m4 += p.Wm[c] * math.Pow(p.X.At(0, c), 4)
+		m22 += p.Wm[c] * p.X.At(0, c) * p.X.At(0, c) * p.X.At(1, c) * p.X.At(1, c)
+	}
+	assert.InDelta(3.0, m4, 1e-12)
+	assert.InDelta(1.0, m22, 1e-12)
+
+	// spherical simplex points lie on a hypersphere
+	p, err = (&SphericalSimplex{W0: 0.5}).Generate(4)
+	assert.NoError(err)
+	_, cols = p.X.Dims()
+	norm := mat.Norm(p.X.ColView(1), 2)
+	for c := 2; c < cols; c++ {
+		assert.InDelta(norm, mat.Norm(p.X.ColView(c), 2), 1e-12)
+	}
+
+	// invalid parameters
+	p, err = (&Symmetric{Alpha: 0.0, Kappa: 0.0}).Generate(2)
+	assert.Nil(p)
+	assert.Error(err)
+
+	p, err = (&Simplex{W0: 1.0}).Generate(2)
+	assert.Nil(p)
+	assert.Error(err)
+
+	p, err = (&SphericalSimplex{W0: -0.1}).Generate(2)
+	assert.Nil(p)
+	assert.Error(err)
+}
+
+func TestUKFSigmaPointGenerators(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, c)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	exp, err := f.Predict(x, u)
+	assert.NoError(err)
+
+	// all sigma point sets are exact for linear models
+	for _, gen := range []SigmaPointGenerator{&Simplex{W0: 0.1}, &SphericalSimplex{W0: 0.1}, &FifthOrder{}} {
+		f, err := New(okModel, ic, q, r, &Config{Points: gen})
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		est, err := f.Predict(x, u)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(exp.Cov(), est.Cov(), 1e-9))
+
+		est, err = f.Update(mat.VecDenseCopyOf(est.Val()), u, z)
+		assert.NotNil(est)
+		assert.NoError(err)
+	}
+
+	// invalid sigma point parameters
+	f, err = New(okModel, ic, q, r, &Config{Points: &Simplex{W0: 2.0}})
+	assert.Nil(f)
+	assert.Error(err)
+}
+
+func eye(n int) *mat.Dense {
+	e := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		e.Set(i, i, 1.0)
+	}
+
+	return e
+}
+
+func TestSqrtSym(t *testing.T) {
+	assert := assert.New(t)
+
+	cov := mat.NewSymDense(3, []float64{
+		2.0, 0.5, 0.0,
+		0.5, 1.0, 0.0,
+		0.0, 0.0, 0.0,
+	})
+
+	sqrtCov, err := sqrtSym(cov)
+	assert.NoError(err)
+
+	sq := &mat.Dense{}
+	sq.Mul(sqrtCov, sqrtCov)
+	assert.True(mat.EqualApprox(cov, sq, 1e-12))
+}
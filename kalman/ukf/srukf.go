@@ -0,0 +1,489 @@
+package ukf
+
+import (
+	"fmt"
+	"math"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+	"github.com/milosgajdos/go-estimate/kalman/srkf"
+	"github.com/milosgajdos/go-estimate/noise"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SRUKF is Square Root Unscented Kalman Filter.
+// Instead of the state covariance matrix P it propagates its lower triangular square root
+// factor S such that P = S*S'. The factor is updated by QR triangularization and Cholesky
+// rank one downdates of sigma points with negative weights, so P is never factorized and it remains symmetric.
+// The corrected factor is triangularized without downdates so the filter keeps running when the corrected
+// covariance is only positive semi-definite e.g. when the model has no noise.
+// State and output noise are assumed to be additive: sigma points span only the state.
+type SRUKF struct {
+	// m is SRUKF system model
+	m filter.Model
+	// q is state noise a.k.a. process noise
+	q filter.Noise
+	// r is output noise a.k.a. measurement noise
+	r filter.Noise
+	// unit stores sigma points of standard normal distribution and their weights
+	unit *sigma.Points
+	// sq is square root of state noise covariance
+	sq *mat.Dense
+	// sr is square root of output noise covariance
+	sr *mat.Dense
+	// s is the SRUKF covariance square root factor
+	s *mat.Dense
+	// sNext is the SRUKF predicted covariance square root factor
+	sNext *mat.Dense
+	// inn is innovation vector
+	inn *mat.VecDense
+	// k is Kalman gain
+	k *mat.Dense
+}
+
+// NewSquareRoot creates new SRUKF and returns it.
+// It accepts the following parameters:
+// - m:      dynamical system model
+// - init:   initial condition of the filter
+// - q:      state a.k.a. process noise
+// - r:      output a.k.a. measurement noise
+// - c:      filter configuration; Additive is ignored as the noise is always assumed to be additive
+// It returns error if either of the following conditions is met:
+// - invalid model is given: model dimensions must be positive integers
+// - invalid state or output noise is given: noise covariance must either be nil or match the model dimensions
+// - invalid sigma points parameters are supplied
+// - initial condition or noise covariance fail to be factorized
+func NewSquareRoot(m filter.Model, init filter.InitCond, q, r filter.Noise, c *Config) (*SRUKF, error) {
+	// size of the input and output vectors
+	nx, _, ny, _ := m.SystemDims()
+	if nx <= 0 || ny <= 0 {
+		return nil, fmt.Errorf("invalid model dimensions: [%d x %d]", nx, ny)
+	}
+
+	var err error
+	var sq, sr *mat.Dense
+
+	if q != nil {
+		qCov, err := filter.StateNoiseCov(m, q.Cov())
+		if err != nil {
+			return nil, fmt.Errorf("invalid state noise: %v", err)
+		}
+		if sq, err = srkf.SqrtCov(qCov); err != nil {
+			return nil, fmt.Errorf("failed to factorize state noise covariance: %v", err)
+		}
+	} else {
+		q, _ = noise.NewNone()
+	}
+
+	if r != nil {
+		if r.Cov().SymmetricDim() != ny {
+			return nil, fmt.Errorf("invalid output noise dimension: %d", r.Cov().SymmetricDim())
+		}
+		if sr, err = srkf.SqrtCov(r.Cov()); err != nil {
+			return nil, fmt.Errorf("failed to factorize output noise covariance: %v", err)
+		}
+	} else {
+		r, _ = noise.NewNone()
+	}
+
+	if init.Cov().SymmetricDim() != nx {
+		return nil, fmt.Errorf("invalid initial condition covariance dimension: %d", init.Cov().SymmetricDim())
+	}
+
+	gen := c.Points
+	if gen == nil {
+		gen = &Symmetric{Alpha: c.Alpha, Beta: c.Beta, Kappa: c.Kappa}
+	}
+
+	// sigma points of standard normal distribution
+	unit, err := gen.Generate(nx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate sigma points: %v", err)
+	}
+
+	// square root of the initial condition covariance
+	s, err := srkf.SqrtCov(init.Cov())
+	if err != nil {
+		return nil, fmt.Errorf("failed to factorize initial covariance: %v", err)
+	}
+
+	// predicted covariance square root
+	sNext := mat.NewDense(nx, nx, nil)
+	sNext.Copy(s)
+
+	// innovation vector
+	inn := mat.NewVecDense(ny, nil)
+
+	// kalman gain
+	k := mat.NewDense(nx, ny, nil)
+
+	return &SRUKF{
+		m:     m,
+		q:     q,
+		r:     r,
+		unit:  unit,
+		sq:    sq,
+		sr:    sr,
+		s:     s,
+		sNext: sNext,
+		inn:   inn,
+		k:     k,
+	}, nil
+}
+
+// genSigmaPoints generates sigma points x + S*X around x where X are the sigma points of standard normal distribution
+func (k *SRUKF) genSigmaPoints(x mat.Vector, s *mat.Dense) *mat.Dense {
+	sp := &mat.Dense{}
+	sp.Mul(s, k.unit.X)
+
+	_, cols := sp.Dims()
+	for j := 0; j < cols; j++ {
+		col := sp.Slice(0, x.Len(), j, j+1).(*mat.Dense)
+		col.Add(col, x)
+	}
+
+	return sp
+}
+
+// sqrtCov calculates square root factor of weighted covariance of sigma points x around their mean xMean
+// with the noise square root factor sn added to it; sn can be nil. Points with positive weights are
+// triangularized together with sn using QR, points with negative weights are removed by Cholesky downdates.
+// It returns error if the square root factor fails to be calculated.
+func sqrtCov(x *mat.Dense, xMean *mat.VecDense, w []float64, sn *mat.Dense) (*mat.Dense, error) {
+	rows, cols := x.Dims()
+
+	var pos, neg []int
+	for j := 0; j < cols; j++ {
+		switch {
+		case w[j] > 0:
+			pos = append(pos, j)
+		case w[j] < 0:
+			neg = append(neg, j)
+		}
+	}
+
+	nn := 0
+	if sn != nil {
+		_, nn = sn.Dims()
+	}
+
+	// pre-array: [sqrt(Wi)*(Xi - xMean), Sn]
+	pre := mat.NewDense(rows, len(pos)+nn, nil)
+	for c, j := range pos {
+		col := pre.Slice(0, rows, c, c+1).(*mat.Dense)
+		col.Sub(x.Slice(0, rows, j, j+1), xMean)
+		col.Scale(math.Sqrt(w[j]), col)
+	}
+	if sn != nil {
+		pre.Slice(0, rows, len(pos), len(pos)+nn).(*mat.Dense).Copy(sn)
+	}
+
+	s, err := srkf.LowerTri(pre)
+	if err != nil {
+		return nil, err
+	}
+
+	dev := mat.NewVecDense(rows, nil)
+	for _, j := range neg {
+		dev.SubVec(x.ColView(j), xMean)
+		dev.ScaleVec(math.Sqrt(-w[j]), dev)
+		if s, err = srkf.CholDowndate(s, dev); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// sqrtGain calculates Kalman gain K = Pxy * (Sy*Sy')^-1 from cross-covariance pxy and output covariance
+// square root factor sy by solving Sy*Sy'*K' = Pxy'. If Sy is singular, which happens when the output
+// is known exactly e.g. without noise, pseudo-inverse of Sy*Sy' is used instead of its inverse.
+// It returns error if Sy fails to be factorized.
+func sqrtGain(pxy, sy *mat.Dense) (*mat.Dense, error) {
+	ny, _ := sy.Dims()
+
+	syTri := mat.NewTriDense(ny, mat.Lower, nil)
+	syTri.Copy(sy)
+	a := &mat.Dense{}
+	if err := syTri.SolveTo(a, false, pxy.T()); err == nil {
+		gainT := &mat.Dense{}
+		if err := syTri.SolveTo(gainT, true, a); err == nil {
+			return mat.DenseCopyOf(gainT.T()), nil
+		}
+	}
+
+	// (Sy*Sy')^+ = U*D^-2*U' where Sy = U*D*V'
+	var svd mat.SVD
+	if ok := svd.Factorize(sy, mat.SVDThin); !ok {
+		return nil, fmt.Errorf("SVD factorization failed")
+	}
+
+	u := &mat.Dense{}
+	svd.UTo(u)
+	vals := svd.Values(nil)
+
+	// singular values below tolerance are treated as zero
+	tol := float64(ny) * vals[0] * 1e-15
+	for i := range vals {
+		if vals[i] > tol {
+			vals[i] = 1 / (vals[i] * vals[i])
+		} else {
+			vals[i] = 0
+		}
+	}
+
+	gain := &mat.Dense{}
+	gain.Mul(pxy, u)
+	gain.Mul(gain, mat.NewDiagDense(len(vals), vals))
+	gain.Mul(gain, u.T())
+
+	return gain, nil
+}
+
+// correctSqrtCov calculates square root factor of the predicted covariance Sx*Sx' corrected by sigma point
+// outputs y with mean yMean using Kalman gain K and output noise square root factor sr; sr can be nil.
+// Output deviations are split into the part G'*Xi linear in the unit sigma points Xi and residuals Ei such that
+// Pxy = Sx*G and Pyy = G'*G + Pe where Pe = Sum(Wi*Ei*Ei') + R. The corrected covariance Sx*Sx' - K*Pyy*K'
+// is then equal to (Sx - K*G')*(Sx - K*G')' + K*Pe*K' which is triangularized using QR, so unlike Cholesky
+// downdates it remains valid when the corrected covariance is only positive semi-definite e.g. without noise.
+// It returns error if the residual covariance fails to be factorized.
+func (k *SRUKF) correctSqrtCov(y *mat.Dense, yMean *mat.VecDense, gain, sr *mat.Dense) (*mat.Dense, error) {
+	nx, _ := k.sNext.Dims()
+	ny, cols := y.Dims()
+
+	// G' = Sum(Wi*(Yi - yMean)*Xi')
+	gT := sigma.CrossCov(y, yMean, k.unit.X, mat.NewVecDense(nx, nil), k.unit.Wc)
+
+	// residuals Ei = Yi - yMean - G'*Xi
+	e := &mat.Dense{}
+	e.Mul(gT, k.unit.X)
+	e.Sub(y, e)
+	for j := 0; j < cols; j++ {
+		col := e.Slice(0, ny, j, j+1).(*mat.Dense)
+		col.Sub(col, yMean)
+	}
+
+	// Pe = Sum(Wi*Ei*Ei') + Sr*Sr'
+	zero := mat.NewVecDense(ny, nil)
+	ec := sigma.CrossCov(e, zero, e, zero, k.unit.Wc)
+	pe := mat.NewSymDense(ny, nil)
+	for i := 0; i < ny; i++ {
+		for j := i; j < ny; j++ {
+			pe.SetSym(i, j, 0.5*(ec.At(i, j)+ec.At(j, i)))
+		}
+	}
+	if sr != nil {
+		rCov := mat.NewSymDense(ny, nil)
+		rCov.SymOuterK(1.0, sr)
+		pe.AddSym(pe, rCov)
+	}
+
+	se, err := srkf.SqrtCov(pe)
+	if err != nil {
+		return nil, err
+	}
+
+	// pre-array: [Sx - K*G', K*Se]
+	pre := mat.NewDense(nx, nx+ny, nil)
+	sx := pre.Slice(0, nx, 0, nx).(*mat.Dense)
+	sx.Mul(gain, gT)
+	sx.Sub(k.sNext, sx)
+	pre.Slice(0, nx, nx, nx+ny).(*mat.Dense).Mul(gain, se)
+
+	return srkf.LowerTri(pre)
+}
+
+// Predict calculates the next system state given the state x and input u and returns its estimate.
+// It first generates new sigma points around x and then attempts to propagate them to the next step.
+// Predicted state is the mean of the propagated sigma points.
+// It returns error if it either fails to propagate the sigma points or their covariance square root to the next step.
+func (k *SRUKF) Predict(x, u mat.Vector) (filter.Estimate, error) {
+	nx, _, _, _ := k.m.SystemDims()
+
+	sp := k.genSigmaPoints(x, k.s)
+
+	// propagated sigma points
+	xPts, err := sigma.Propagate(k.m, sp, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propagate sigma points: %v", err)
+	}
+
+	xMean := sigma.Mean(xPts, k.unit.Wm)
+
+	sNext, err := sqrtCov(xPts, xMean, k.unit.Wc, k.sq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propagate covariance square root: %v", err)
+	}
+
+	// update SRUKF predicted covariance square root
+	k.sNext.Copy(sNext)
+
+	cov := mat.NewSymDense(nx, nil)
+	cov.SymOuterK(1.0, sNext)
+
+	return estimate.NewBaseWithCov(xMean, cov)
+}
+
+// Update corrects state x using the measurement z, given control intput u and returns corrected estimate.
+// x is expected to be the predicted state: new sigma points are generated around it using the predicted covariance.
+// Missing measurement elements can be marked with NaN: only the observed elements are then used to correct x.
+// If the measurement is missing entirely (nil or all NaN) x is not corrected and the predicted covariance is kept.
+// It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
+func (k *SRUKF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
+	nx, _, ny, _ := k.m.SystemDims()
+
+	if z != nil && z.Len() != ny {
+		return nil, fmt.Errorf("invalid measurement supplied: %v", z)
+	}
+
+	k.inn.Zero()
+	k.k.Zero()
+
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: keep predicted covariance
+		k.s.Copy(k.sNext)
+		return estimate.NewBaseWithCov(x, k.Cov())
+	}
+
+	sp := k.genSigmaPoints(x, k.sNext)
+
+	// observed sigma points
+	yPts, err := sigma.Observe(k.m, sp, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to observe sigma points: %v", err)
+	}
+
+	// keep only the outputs of observed measurement elements
+	sr := k.sr
+	if len(idx) < ny {
+		yPts = kalman.SelectRows(yPts, idx)
+		if sr != nil {
+			if sr, err = srkf.SqrtCov(kalman.SelectSym(k.r.Cov(), idx)); err != nil {
+				return nil, fmt.Errorf("failed to factorize output noise covariance: %v", err)
+			}
+		}
+	}
+
+	xMean := sigma.Mean(sp, k.unit.Wm)
+	yMean := sigma.Mean(yPts, k.unit.Wm)
+
+	sy, err := sqrtCov(yPts, yMean, k.unit.Wc, sr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate output covariance square root: %v", err)
+	}
+
+	pxy := sigma.CrossCov(sp, xMean, yPts, yMean, k.unit.Wc)
+
+	gain, err := sqrtGain(pxy, sy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate Kalman gain: %v", err)
+	}
+
+	s, err := k.correctSqrtCov(yPts, yMean, gain, sr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to correct covariance square root: %v", err)
+	}
+
+	// innovation vector
+	inn := &mat.VecDense{}
+	inn.SubVec(kalman.SelectVec(z, idx), yMean)
+
+	// update state x
+	corr := &mat.VecDense{}
+	corr.MulVec(gain, inn)
+	x.(*mat.VecDense).AddVec(x, corr)
+
+	// update SRUKF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
+	}
+	// update SRUKF covariance square root
+	k.s.Copy(s)
+
+	return estimate.NewBaseWithCov(x, k.Cov())
+}
+
+// Run runs one step of SRUKF for given state x, input u and measurement z.
+// It corrects system state x using measurement z and returns new system estimate.
+// It returns error if it either fails to propagate or correct state x.
+func (k *SRUKF) Run(x, u, z mat.Vector) (filter.Estimate, error) {
+	pred, err := k.Predict(x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	est, err := k.Update(pred.Val(), u, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return est, nil
+}
+
+// Model returns SRUKF model
+func (k *SRUKF) Model() filter.Model {
+	return k.m
+}
+
+// StateNoise retruns state noise
+func (k *SRUKF) StateNoise() filter.Noise {
+	return k.q
+}
+
+// OutputNoise retruns output noise
+func (k *SRUKF) OutputNoise() filter.Noise {
+	return k.r
+}
+
+// Cov returns SRUKF covariance
+func (k *SRUKF) Cov() mat.Symmetric {
+	rows, _ := k.s.Dims()
+	cov := mat.NewSymDense(rows, nil)
+	cov.SymOuterK(1.0, k.s)
+
+	return cov
+}
+
+// SetCov sets SRUKF covariance matrix to cov.
+// It returns error if either cov is nil, its dimensions are not the same as SRUKF covariance dimensions
+// or if it fails to be factorized.
+func (k *SRUKF) SetCov(cov mat.Symmetric) error {
+	if cov == nil {
+		return fmt.Errorf("invalid covariance matrix: %v", cov)
+	}
+
+	rows, _ := k.s.Dims()
+	if cov.SymmetricDim() != rows {
+		return fmt.Errorf("invalid covariance matrix dims: [%d x %d]", cov.SymmetricDim(), cov.SymmetricDim())
+	}
+
+	s, err := srkf.SqrtCov(cov)
+	if err != nil {
+		return fmt.Errorf("failed to factorize covariance: %v", err)
+	}
+
+	k.s.Copy(s)
+
+	return nil
+}
+
+// SqrtCov returns SRUKF covariance square root factor
+func (k *SRUKF) SqrtCov() mat.Matrix {
+	s := &mat.Dense{}
+	s.CloneFrom(k.s)
+
+	return s
+}
+
+// Gain returns Kalman gain
+func (k *SRUKF) Gain() mat.Matrix {
+	gain := &mat.Dense{}
+	gain.CloneFrom(k.k)
+
+	return gain
+}
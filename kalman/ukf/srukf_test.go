@@ -0,0 +1,314 @@
+package ukf
+
+import (
+	"math"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SRUKF can replace UKF
+var _ kalman.Kalman = (*SRUKF)(nil)
+
+func TestNewSquareRoot(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := NewSquareRoot(okModel, ic, q, r, c)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// invalid model: incorrect dimensions
+	f, err = NewSquareRoot(badModel, ic, q, r, c)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid output noise
+	f, err = NewSquareRoot(okModel, ic, q, q, c)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid sigma point parameters
+	f, err = NewSquareRoot(okModel, ic, q, r, &Config{Points: &Simplex{W0: 2.0}})
+	assert.Nil(f)
+	assert.Error(err)
+
+	// no noise
+	f, err = NewSquareRoot(okModel, ic, nil, nil, c)
+	assert.NotNil(f)
+	assert.NoError(err)
+}
+
+func TestSRUKF(t *testing.T) {
+	assert := assert.New(t)
+
+	configs := []*Config{
+		c,
+		// negative mean sigma point covariance weight
+		{Alpha: 0.5, Beta: 2.0, Kappa: 0.0},
+		{Points: &SphericalSimplex{W0: 0.2}},
+		{Points: &FifthOrder{}},
+	}
+
+	zs := []mat.Vector{z, mat.NewVecDense(1, []float64{math.NaN()}), mat.NewVecDense(1, []float64{0.5})}
+
+	for _, conf := range configs {
+		f, err := NewSquareRoot(okModel, ic, q, r, conf)
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		uc := *conf
+		uc.Additive = true
+		exp, err := New(okModel, ic, q, r, &uc)
+		assert.NotNil(exp)
+		assert.NoError(err)
+
+		// SRUKF matches additive noise UKF
+		x := mat.VecDenseCopyOf(ic.State())
+		for _, _z := range zs {
+			expPred, err := exp.Predict(x, u)
+			assert.NoError(err)
+			pred, err := f.Predict(x, u)
+			assert.NoError(err)
+			assert.True(mat.EqualApprox(expPred.Cov(), pred.Cov(), 1e-9))
+
+			expEst, err := exp.Update(mat.VecDenseCopyOf(pred.Val()), u, _z)
+			assert.NoError(err)
+			est, err := f.Update(mat.VecDenseCopyOf(pred.Val()), u, _z)
+			assert.NoError(err)
+			assert.True(mat.EqualApprox(expEst.Val(), est.Val(), 1e-9))
+			assert.True(mat.EqualApprox(expEst.Cov(), est.Cov(), 1e-9))
+			assert.True(mat.EqualApprox(exp.Gain(), f.Gain(), 1e-9))
+
+			x = mat.VecDenseCopyOf(est.Val())
+		}
+
+		// covariance square root factor is lower triangular
+		s := f.SqrtCov()
+		assert.Equal(0.0, s.At(0, 1))
+		ss := &mat.Dense{}
+		ss.Mul(s, s.T())
+		assert.True(mat.EqualApprox(f.Cov(), ss, 1e-12))
+	}
+}
+
+// rangeModel observes the distance of the state from the origin
+type rangeModel struct {
+	*sim.BaseModel
+}
+
+func (m *rangeModel) Observe(x, u, wn mat.Vector) (mat.Vector, error) {
+	y := math.Hypot(x.AtVec(0), x.AtVec(1))
+	if wn != nil {
+		y += wn.AtVec(0)
+	}
+
+	return mat.NewVecDense(1, []float64{y}), nil
+}
+
+// redrawnUpdate is a reference UKF measurement update: it draws sigma points unit around predicted state x
+// along the columns of the Cholesky factor of predicted covariance p and returns corrected state, covariance and gain.
+func redrawnUpdate(m filter.Model, unit *sigma.Points, x *mat.VecDense, p, rCov mat.Symmetric, u, z mat.Vector) (*mat.VecDense, *mat.SymDense, *mat.Dense) {
+	nx := x.Len()
+	_, cols := unit.X.Dims()
+
+	var chol mat.Cholesky
+	chol.Factorize(p)
+	l := &mat.TriDense{}
+	chol.LTo(l)
+
+	xs := make([]*mat.VecDense, cols)
+	ys := make([]float64, cols)
+	yMean := 0.0
+	for j := range xs {
+		xs[j] = &mat.VecDense{}
+		xs[j].MulVec(l, unit.X.ColView(j))
+		xs[j].AddVec(xs[j], x)
+
+		y, _ := m.Observe(xs[j], u, nil)
+		ys[j] = y.AtVec(0)
+		yMean += unit.Wm[j] * ys[j]
+	}
+
+	pyy := rCov.At(0, 0)
+	pxy := mat.NewVecDense(nx, nil)
+	dx := &mat.VecDense{}
+	for j := range xs {
+		dy := ys[j] - yMean
+		pyy += unit.Wc[j] * dy * dy
+		dx.SubVec(xs[j], x)
+		pxy.AddScaledVec(pxy, unit.Wc[j]*dy, dx)
+	}
+
+	gain := mat.NewDense(nx, 1, nil)
+	gain.Scale(1/pyy, pxy)
+
+	xCorr := &mat.VecDense{}
+	xCorr.AddScaledVec(x, z.AtVec(0)-yMean, gain.ColView(0))
+
+	pCorr := mat.NewSymDense(nx, nil)
+	pCorr.CopySym(p)
+	pCorr.SymRankOne(pCorr, -pyy, gain.ColView(0))
+
+	return xCorr, pCorr, gain
+}
+
+func TestSRUKFNonlinear(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &rangeModel{okModel}
+	zs := []mat.Vector{mat.NewVecDense(1, []float64{2.5}), mat.NewVecDense(1, []float64{3.5}), mat.NewVecDense(1, []float64{4.0})}
+
+	for _, gen := range []SigmaPointGenerator{
+		&Symmetric{Alpha: c.Alpha, Beta: c.Beta, Kappa: c.Kappa},
+		// negative mean sigma point covariance weight
+		&Symmetric{Alpha: 0.5, Beta: 2.0, Kappa: 0.0},
+		&SphericalSimplex{W0: 0.2},
+		&FifthOrder{},
+	} {
+		unit, err := gen.Generate(2)
+		assert.NoError(err)
+
+		f, err := NewSquareRoot(m, ic, q, r, &Config{Points: gen})
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		exp, err := New(m, ic, q, r, &Config{Points: gen, Additive: true})
+		assert.NotNil(exp)
+		assert.NoError(err)
+
+		x := mat.VecDenseCopyOf(ic.State())
+		for i, _z := range zs {
+			pred, err := f.Predict(x, u)
+			assert.NoError(err)
+
+			// SRUKF matches the update with sigma points redrawn around the prediction
+			xRef, pRef, gainRef := redrawnUpdate(m, unit, mat.VecDenseCopyOf(pred.Val()), pred.Cov(), r.Cov(), u, _z)
+
+			est, err := f.Update(mat.VecDenseCopyOf(pred.Val()), u, _z)
+			assert.NoError(err)
+			assert.True(mat.EqualApprox(xRef, est.Val(), 1e-10))
+			assert.True(mat.EqualApprox(pRef, est.Cov(), 1e-10))
+			assert.True(mat.EqualApprox(gainRef, f.Gain(), 1e-10))
+
+			// UKF reuses the propagated sigma points so it differs on nonlinear models
+			if i == 0 {
+				expEst, err := exp.Run(mat.VecDenseCopyOf(x), u, _z)
+				assert.NoError(err)
+				assert.False(mat.EqualApprox(expEst.Cov(), est.Cov(), 1e-6))
+			}
+
+			x = mat.VecDenseCopyOf(est.Val())
+		}
+	}
+}
+
+func TestSRUKFRun(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := NewSquareRoot(okModel, ic, q, r, c)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.VecDenseCopyOf(ic.State())
+	est, err := f.Run(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid measurement vector
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	est, err = f.Update(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestSRUKFNoNoise(t *testing.T) {
+	assert := assert.New(t)
+
+	configs := []*Config{
+		c,
+		{Points: &SphericalSimplex{W0: 0.2}},
+		{Points: &FifthOrder{}},
+	}
+
+	for _, conf := range configs {
+		f, err := NewSquareRoot(okModel, ic, nil, nil, conf)
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		uc := *conf
+		uc.Additive = true
+		exp, err := New(okModel, ic, nil, nil, &uc)
+		assert.NotNil(exp)
+		assert.NoError(err)
+
+		// exact measurement makes the corrected covariance singular
+		x := mat.VecDenseCopyOf(ic.State())
+		expEst, err := exp.Run(mat.VecDenseCopyOf(x), u, z)
+		assert.NoError(err)
+		est, err := f.Run(x, u, z)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(expEst.Val(), est.Val(), 1e-9))
+		assert.True(mat.EqualApprox(expEst.Cov(), est.Cov(), 1e-9))
+
+		// filter keeps running with positive semi-definite covariance
+		for i := 0; i < 10; i++ {
+			x = mat.VecDenseCopyOf(est.Val())
+			est, err = f.Run(x, u, z)
+			assert.NotNil(est)
+			assert.NoError(err)
+			if err != nil {
+				break
+			}
+
+			s := f.SqrtCov()
+			assert.Equal(0.0, s.At(0, 1))
+			ss := &mat.Dense{}
+			ss.Mul(s, s.T())
+			assert.True(mat.EqualApprox(est.Cov(), ss, 1e-12))
+			assert.False(math.IsNaN(est.Val().AtVec(0)) || math.IsNaN(est.Val().AtVec(1)))
+		}
+	}
+}
+
+func TestSRUKFAccessors(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := NewSquareRoot(okModel, ic, q, r, c)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	assert.Equal(okModel, f.Model())
+	assert.NotNil(f.StateNoise())
+	assert.NotNil(f.OutputNoise())
+	assert.NotNil(f.Gain())
+
+	cov := f.Cov()
+	assert.True(mat.EqualApprox(cov, ic.Cov(), 1e-12))
+
+	newCov := mat.NewSymDense(2, []float64{1.0, 0.5, 0.5, 2.0})
+	err = f.SetCov(newCov)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(f.Cov(), newCov, 1e-12))
+
+	// invalid covariance
+	err = f.SetCov(nil)
+	assert.Error(err)
+
+	err = f.SetCov(mat.NewSymDense(3, nil))
+	assert.Error(err)
+}
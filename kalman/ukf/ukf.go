@@ -6,6 +6,8 @@ import (
 
 	filter "github.com/milosgajdos/go-estimate"
 	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
 	"github.com/milosgajdos/go-estimate/noise"
 	"github.com/milosgajdos/matrix"
 	"gonum.org/v1/gonum/mat"
@@ -15,7 +17,7 @@ import (
 type SigmaPoints struct {
 	// X stores sigma points in its columns
 	X *mat.Dense
-	// Cov is sigma points covariance
+	// Cov is square root of sigma points covariance
 	Cov *mat.SymDense
 }
 
@@ -23,6 +25,8 @@ type SigmaPoints struct {
 type sigmaPointsNext struct {
 	x     *mat.Dense
 	xMean *mat.VecDense
+	// wn stores output noise sigma points; it's nil when the noise is additive
+	wn *mat.Dense
 }
 
 // Config contains UKF [unitless] configuration parameters
@@ -33,6 +37,11 @@ type Config struct {
 	Beta float64
 	// Kappa is kappa parameter (must be non-negative)
 	Kappa float64
+	// Points generates sigma points; if nil, scaled symmetric set with Alpha, Beta and Kappa is used
+	Points SigmaPointGenerator
+	// Additive assumes state and output noise are additive: sigma points span only the state
+	// and the noise covariances are added to the predicted covariances instead of augmenting the state
+	Additive bool
 }
 
 // UKF is Unscented (a.k.a. Sigma Point) Kalman Filter
@@ -43,14 +52,22 @@ type UKF struct {
 	q filter.Noise
 	// r is output noise a.k.a. measurement noise
 	r filter.Noise
-	// gamma is a unitless UKF parameter
-	gamma float64
 	// Wm0 is mean sigma point weight
+	//
+	// Deprecated: sigma point generators may use any weights; use Weights instead.
 	Wm0 float64
 	// Wc0 is mean sigma point covariance weight
+	//
+	// Deprecated: sigma point generators may use any weights; use Weights instead.
 	Wc0 float64
-	// Wsp is weight for regular sigma points and covariances
+	// W is weight of the first of the rest of sigma points and covariances
+	//
+	// Deprecated: sigma point generators may use any weights; use Weights instead.
 	W float64
+	// unit stores sigma points of standard normal distribution and their weights
+	unit *sigma.Points
+	// additive is true if noise is additive i.e. sigma points are not augmented
+	additive bool
 	// sp stores UKF sigma points
 	sp *SigmaPoints
 	// spNext stores sigma points predictions
@@ -88,10 +105,12 @@ func New(m filter.Model, init filter.InitCond, q, r filter.Noise, c *Config) (*U
 	spDim := init.State().Len()
 
 	if q != nil {
-		if q.Cov().SymmetricDim() != nx {
-			return nil, fmt.Errorf("invalid state noise dimension: %d", q.Cov().SymmetricDim())
+		if _, err := filter.StateNoiseCov(m, q.Cov()); err != nil {
+			return nil, fmt.Errorf("invalid state noise: %v", err)
+		}
+		if !c.Additive {
+			spDim += q.Cov().SymmetricDim()
 		}
-		spDim += q.Cov().SymmetricDim()
 	} else {
 		q, _ = noise.NewNone()
 	}
@@ -100,46 +119,39 @@ func New(m filter.Model, init filter.InitCond, q, r filter.Noise, c *Config) (*U
 		if r.Cov().SymmetricDim() != ny {
 			return nil, fmt.Errorf("invalid output noise dimension: %d", r.Cov().SymmetricDim())
 		}
-		spDim += r.Cov().SymmetricDim()
+		if !c.Additive {
+			spDim += r.Cov().SymmetricDim()
+		}
 	} else {
 		r, _ = noise.NewNone()
 	}
 
-	// lambda is one of the unitless UKF parameters calculated using the config ones
-	lambda := c.Alpha*c.Alpha*(float64(spDim)+c.Kappa) - float64(spDim)
-
-	// gamma is the square root Sigma Point covariance scaling factor
-	gamma := math.Sqrt(float64(spDim) + lambda)
-
-	// weight of the mean sigma point
-	Wm0 := lambda / (float64(spDim) + lambda)
-
-	// weight of the mean sigma point covariance
-	Wc0 := Wm0 + (1 - c.Alpha*c.Alpha + c.Beta)
+	gen := c.Points
+	if gen == nil {
+		gen = &Symmetric{Alpha: c.Alpha, Beta: c.Beta, Kappa: c.Kappa}
+	}
 
-	// weight of the rest of sigma points and covariance
-	W := 1 / (2 * (float64(spDim) + lambda))
+	// sigma points of standard normal distribution
+	unit, err := gen.Generate(spDim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate sigma points: %v", err)
+	}
+	_, cols := unit.X.Dims()
 
 	// sigma points matrix: stores sigma points in its columns
-	x := mat.NewDense(spDim, 2*spDim+1, nil)
+	x := mat.NewDense(spDim, cols, nil)
 
 	// sigma points covariance matrix: this is a block diagonal matrix
-	cov := matrix.BlockSymDiag([]mat.Symmetric{init.Cov(), q.Cov(), r.Cov()})
+	cov := init.Cov()
+	if !c.Additive {
+		cov = matrix.BlockSymDiag([]mat.Symmetric{init.Cov(), q.Cov(), r.Cov()})
+	}
 
 	sp := &SigmaPoints{
 		X:   x,
-		Cov: cov,
-	}
-
-	// sigma points predicted states
-	xPred := mat.NewDense(nx, 2*spDim+1, nil)
-	// expected predicted sigma point state a.k.a. mean sigma point predicted state
-	xMean := mat.NewVecDense(nx, nil)
-
-	spNext := &sigmaPointsNext{
-		x:     xPred,
-		xMean: xMean,
+		Cov: mat.NewSymDense(spDim, nil),
 	}
+	sp.Cov.CopySym(cov)
 
 	// predicted covariance; this covariance is corrected using new measurement
 	pNext := mat.NewSymDense(init.Cov().SymmetricDim(), nil)
@@ -155,69 +167,110 @@ func New(m filter.Model, init filter.InitCond, q, r filter.Noise, c *Config) (*U
 	// kalman gain
 	k := mat.NewDense(nx, ny, nil)
 
-	return &UKF{
-		m:      m,
-		q:      q,
-		r:      r,
-		gamma:  gamma,
-		Wm0:    Wm0,
-		Wc0:    Wc0,
-		W:      W,
-		sp:     sp,
-		spNext: spNext,
-		p:      p,
-		pNext:  pNext,
-		inn:    inn,
-		k:      k,
-	}, nil
+	f := &UKF{
+		m:        m,
+		q:        q,
+		r:        r,
+		Wm0:      unit.Wm[0],
+		Wc0:      unit.Wc[0],
+		unit:     unit,
+		additive: c.Additive,
+		sp:       sp,
+		p:        p,
+		pNext:    pNext,
+		inn:      inn,
+		k:        k,
+	}
+
+	if len(unit.Wm) > 1 {
+		f.W = unit.Wm[1]
+	}
+
+	// initial condition is used as predicted state until the first prediction
+	initPoints, err := f.GenSigmaPoints(init.State())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate sigma points: %v", err)
+	}
+
+	f.spNext = &sigmaPointsNext{
+		x:     mat.DenseCopyOf(initPoints.X.Slice(0, nx, 0, cols)),
+		xMean: mat.VecDenseCopyOf(init.State()),
+		wn:    f.outputNoisePoints(initPoints),
+	}
+
+	return f, nil
 }
 
 // GenSigmaPoints generates UKF sigma points around x and returns them.
-// It returns error if it fails to generate new sigma points due to covariance SVD facrtorization failure.
+// Unless the noise is additive, sigma points are augmented with state and output noise.
+// Sigma points are spread along the columns of the symmetric square root of their covariance which is returned in Cov.
+// It returns error if it fails to generate new sigma points due to covariance eigen decomposition failure.
 func (k *UKF) GenSigmaPoints(x mat.Vector) (*SigmaPoints, error) {
-	rows, cols := k.sp.X.Dims()
-	sp := mat.NewDense(rows, cols, nil)
-	cov := matrix.BlockSymDiag([]mat.Symmetric{k.p, k.q.Cov(), k.r.Cov()})
-
-	var svd mat.SVD
-	ok := svd.Factorize(cov, mat.SVDFull)
-	if !ok {
-		return nil, fmt.Errorf("SVD factorization failed")
-	}
-	SqrtCov := new(mat.Dense)
-	svd.UTo(SqrtCov)
-	vals := svd.Values(nil)
-	for i := range vals {
-		vals[i] = math.Sqrt(vals[i])
+	if k.additive {
+		return k.genSigmaPoints(x, k.p)
 	}
-	diagSqrt := mat.NewDiagDense(len(vals), vals)
 
-	r, c := SqrtCov.Dims()
-	// copy SqrtCov values into sigma points covariance matrix
-	for i := 0; i < r; i++ {
-		for j := 0; j < c; j++ {
-			cov.SetSym(i, j, diagSqrt.At(i, j))
-		}
+	return k.genSigmaPoints(x, matrix.BlockSymDiag([]mat.Symmetric{k.p, k.q.Cov(), k.r.Cov()}))
+}
+
+// genSigmaPoints generates sigma points around x using covariance cov and returns them.
+// It returns error if cov eigen decomposition fails.
+func (k *UKF) genSigmaPoints(x mat.Vector, cov mat.Symmetric) (*SigmaPoints, error) {
+	sqrtCov, err := sqrtSym(cov)
+	if err != nil {
+		return nil, err
 	}
-	cov.ScaleSym(k.gamma, cov)
 
-	// we center the sigma points around the mean sigma point
+	sp := &mat.Dense{}
+	sp.Mul(sqrtCov, k.unit.X)
+
+	// we center the sigma points around the mean sigma point: noise has zero mean
+	_, cols := sp.Dims()
 	for j := 0; j < cols; j++ {
-		sp.Slice(0, rows, j, j+1).(*mat.Dense).Copy(x)
+		col := sp.Slice(0, x.Len(), j, j+1).(*mat.Dense)
+		col.Add(col, x)
 	}
-	// positive sigma points
-	sx := sp.Slice(0, rows, 1, 1+((cols-1)/2)).(*mat.Dense)
-	sx.Add(sx, cov)
-	// negative sigma points
-	sx = sp.Slice(0, rows, 1+((cols-1)/2), cols).(*mat.Dense)
-	sx.Sub(sx, cov)
 
 	return &SigmaPoints{
 		X:   sp,
-		Cov: cov,
+		Cov: sqrtCov,
 	}, nil
 }
 
+// sqrtSym calculates symmetric square root of positive semi-definite matrix cov and returns it.
+// Negative eigenvalues caused by rounding errors are treated as zero.
+// It returns error if cov eigen decomposition fails.
+func sqrtSym(cov mat.Symmetric) (*mat.SymDense, error) {
+	var eig mat.EigenSym
+	if ok := eig.Factorize(cov, true); !ok {
+		return nil, fmt.Errorf("eigen decomposition failed")
+	}
+
+	vals := eig.Values(nil)
+	for i := range vals {
+		vals[i] = math.Sqrt(math.Max(vals[i], 0))
+	}
+
+	vecs := &mat.Dense{}
+	eig.VectorsTo(vecs)
+
+	// V*sqrt(D)*V'
+	vd := &mat.Dense{}
+	vd.Mul(vecs, mat.NewDiagDense(len(vals), vals))
+	sq := &mat.Dense{}
+	sq.Mul(vd, vecs.T())
+
+	n := len(vals)
+	sqrtCov := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			sqrtCov.SetSym(i, j, 0.5*(sq.At(i, j)+sq.At(j, i)))
+		}
+	}
+
+	return sqrtCov, nil
+}
+
 // propagateSigmaPoints propagates sigma points to the next step and observes their output.
 // It calculates mean predicted sigma point state and returns it with predicted sigma points states.
 // It returns error if it fails to propagate the sigma points or observe their outputs.
@@ -228,16 +281,13 @@ func (k *UKF) propagateSigmaPoints(sp *SigmaPoints, u mat.Vector) (*sigmaPointsN
 	// x stores predicted sigma point states
 	x := mat.NewDense(nx, cols, nil)
 
-	// xMean stores predicted mean sigma point state
-	xMean := mat.NewVecDense(nx, nil)
-
 	var spNext mat.Vector
 	var err error
 	qLen := k.q.Cov().SymmetricDim()
 
 	// propagate all sigma points and observe their output
 	for c := 0; c < cols; c++ {
-		if qLen == 0 {
+		if qLen == 0 || k.additive {
 			spNext, err = k.m.Propagate(sp.X.ColView(c).(*mat.VecDense).SliceVec(0, nx), u, nil)
 		} else {
 			spNext, err = k.m.Propagate(sp.X.ColView(c).(*mat.VecDense).SliceVec(0, nx), u,
@@ -247,47 +297,41 @@ func (k *UKF) propagateSigmaPoints(sp *SigmaPoints, u mat.Vector) (*sigmaPointsN
 			return nil, fmt.Errorf("failed to propagate sigma point: %v", err)
 		}
 		x.Slice(0, spNext.Len(), c, c+1).(*mat.Dense).Copy(spNext)
-
-		if c == 0 {
-			xMean.AddScaledVec(xMean, k.Wm0, spNext)
-		} else {
-			xMean.AddScaledVec(xMean, k.W, spNext)
-		}
 	}
 
 	return &sigmaPointsNext{
 		x:     x,
-		xMean: xMean,
+		xMean: sigma.Mean(x, k.unit.Wm),
+		wn:    k.outputNoisePoints(sp),
 	}, nil
 }
 
-// predictCovariance estimates new UKF covariance based on sigma points x and their mean xMean and returns it.
-// It returns error if it fails to calculate new covariance from predicted sigma points.
-func (k *UKF) predictCovariance(x *mat.Dense, xMean *mat.VecDense) (*mat.SymDense, error) {
-	rows, cols := x.Dims()
-
-	predCov := mat.NewSymDense(rows, nil)
+// outputNoisePoints returns output noise part of augmented sigma points sp.
+// It returns nil if the noise is additive or there is no output noise.
+func (k *UKF) outputNoisePoints(sp *SigmaPoints) *mat.Dense {
+	rLen := k.r.Cov().SymmetricDim()
+	if k.additive || rLen == 0 {
+		return nil
+	}
 
-	// cov is an accumulator matrix that stores added covariances
-	cov := mat.NewDense(rows, rows, nil)
+	nx, _, _, _ := k.m.SystemDims()
+	qLen := k.q.Cov().SymmetricDim()
+	_, cols := sp.X.Dims()
 
-	// helper vector used when calculating predicted covariance
-	sigmaPoint := mat.NewVecDense(rows, nil)
+	return mat.DenseCopyOf(sp.X.Slice(nx+qLen, nx+qLen+rLen, 0, cols))
+}
 
-	for c := 0; c < cols; c++ {
-		sigmaPoint.SubVec(x.ColView(c), xMean)
-		cov.Mul(sigmaPoint, sigmaPoint.T())
+// predictCovariance estimates new UKF covariance based on sigma points x and their mean xMean and returns it.
+// It returns error if it fails to calculate new covariance from predicted sigma points.
+func (k *UKF) predictCovariance(x *mat.Dense, xMean *mat.VecDense) (*mat.SymDense, error) {
+	rows, _ := x.Dims()
 
-		if c == 0 {
-			cov.Scale(k.Wc0, cov)
-		} else {
-			cov.Scale(k.W, cov)
-		}
+	cov := sigma.CrossCov(x, xMean, x, xMean, k.unit.Wc)
 
-		for i := 0; i < rows; i++ {
-			for j := i; j < rows; j++ {
-				predCov.SetSym(i, j, predCov.At(i, j)+cov.At(i, j))
-			}
+	predCov := mat.NewSymDense(rows, nil)
+	for i := 0; i < rows; i++ {
+		for j := i; j < rows; j++ {
+			predCov.SetSym(i, j, cov.At(i, j))
 		}
 	}
 
@@ -320,120 +364,112 @@ func (k *UKF) Predict(x, u mat.Vector) (filter.Estimate, error) {
 		return nil, fmt.Errorf("failed to predict covariance: %v", err)
 	}
 
+	if k.additive {
+		if _, ok := k.q.(*noise.None); !ok {
+			qCov, err := filter.StateNoiseCov(k.m, k.q.Cov())
+			if err != nil {
+				return nil, fmt.Errorf("failed to map state noise: %v", err)
+			}
+			cov.AddSym(cov, qCov)
+		}
+
+		// propagated sigma points do not capture state noise so they are redrawn around the predicted state
+		sp, err := k.genSigmaPoints(sigmaPointsNext.xMean, cov)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate sigma points: %v", err)
+		}
+		sigmaPointsNext.x = sp.X
+	}
+
 	// it's now safe to update the internal state of the filter
 	k.spNext.x.Copy(sigmaPointsNext.x)
 	k.spNext.xMean.CopyVec(sigmaPointsNext.xMean)
+	k.spNext.wn = sigmaPointsNext.wn
 	k.pNext.CopySym(cov)
 
 	return estimate.NewBaseWithCov(xNext, cov)
 }
 
 // Update corrects state x using the measurement z, given control intput u and returns corrected estimate.
+// Missing measurement elements can be marked with NaN: only the observed elements are then used to correct x.
+// If the measurement is missing entirely (nil or all NaN) x is not corrected and the predicted covariance is kept.
 // It returns error if either invalid state was supplied or if it fails to calculate system output estimate.
 func (k *UKF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
 	nx, _, ny, _ := k.m.SystemDims()
 	_, cols := k.spNext.x.Dims()
 
-	if z.Len() != ny {
+	if z != nil && z.Len() != ny {
 		return nil, fmt.Errorf("invalid measurement supplied: %v", z)
 	}
 
+	k.inn.Zero()
+	k.k.Zero()
+
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: keep predicted covariance
+		k.p.CopySym(k.pNext)
+		return estimate.NewBaseWithCov(x, k.p)
+	}
+
 	// y stores predicted sigma point outputs
 	y := mat.NewDense(ny, cols, nil)
 
-	// yMean stores predicted mean sigma point output
-	yMean := mat.NewVecDense(ny, nil)
-
 	var spOut mat.Vector
 	var err error
-	rLen := k.r.Cov().SymmetricDim()
 
 	// observe sigma points outputs
 	for c := 0; c < cols; c++ {
-		if rLen == 0 {
+		if k.spNext.wn == nil {
 			spOut, err = k.m.Observe(k.spNext.x.ColView(c), u, nil)
 		} else {
-			spOut, err = k.m.Observe(k.spNext.x.ColView(c), u, k.r.Sample())
+			spOut, err = k.m.Observe(k.spNext.x.ColView(c), u, k.spNext.wn.ColView(c))
 		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to observe sigma point output: %v", err)
 		}
 		y.Slice(0, spOut.Len(), c, c+1).(*mat.Dense).Copy(spOut)
+	}
 
-		if c == 0 {
-			yMean.AddScaledVec(yMean, k.Wm0, spOut)
-		} else {
-			yMean.AddScaledVec(yMean, k.W, spOut)
-		}
+	// keep only the outputs of observed measurement elements
+	if len(idx) < ny {
+		y = kalman.SelectRows(y, idx)
 	}
 
+	yMean := sigma.Mean(y, k.unit.Wm)
+
 	// covariance of x and y; y is predicted sigma point output
-	pxy := mat.NewDense(nx, ny, nil)
+	pxy := sigma.CrossCov(k.spNext.x, k.spNext.xMean, y, yMean, k.unit.Wc)
 
 	// predicted sigma points output covariance
-	pyy := mat.NewDense(ny, ny, nil)
-
-	// helper vectors used in calculating covariances
-	sigmaPoint := mat.NewVecDense(nx, nil)
-	sigmaPointOut := mat.NewVecDense(ny, nil)
-
-	// helper matrices which hold intermediary covariances
-	covxy := mat.NewDense(nx, ny, nil)
-	covyy := mat.NewDense(ny, ny, nil)
-
-	for c := 0; c < cols; c++ {
-		sigmaPoint.SubVec(k.spNext.x.ColView(c), k.spNext.xMean)
-		sigmaPointOut.SubVec(y.ColView(c), yMean)
-
-		covxy.Mul(sigmaPoint, sigmaPointOut.T())
-		covyy.Mul(sigmaPointOut, sigmaPointOut.T())
-
-		if c == 0 {
-			covxy.Scale(k.Wc0, covxy)
-			covyy.Scale(k.Wc0, covyy)
-		} else {
-			covxy.Scale(k.W, covxy)
-			covyy.Scale(k.W, covyy)
-		}
-
-		pxy.Add(pxy, covxy)
-		pyy.Add(pyy, covyy)
+	pyy := sigma.CrossCov(y, yMean, y, yMean, k.unit.Wc)
+	if _, ok := k.r.(*noise.None); !ok && k.additive {
+		pyy.Add(pyy, kalman.SelectSym(k.r.Cov(), idx))
 	}
 
-	// calculate Kalman gain
-	pyyInv := &mat.Dense{}
-	if err := pyyInv.Inverse(pyy); err != nil {
-		return nil, fmt.Errorf("failed to calculat Pyy inverse: %v", err)
+	// calculate Kalman gain and correct UKF covariance
+	gain, pCorr, err := sigma.Correct(k.pNext, pxy, pyy)
+	if err != nil {
+		return nil, err
 	}
 
-	gain := &mat.Dense{}
-	gain.Mul(pxy, pyyInv)
-
 	// innovation vector
 	inn := &mat.VecDense{}
-	inn.SubVec(z, yMean)
+	inn.SubVec(kalman.SelectVec(z, idx), yMean)
 
 	// update state x
 	corr := &mat.Dense{}
 	corr.Mul(gain, inn)
 	x.(*mat.VecDense).AddVec(k.spNext.xMean, corr.ColView(0))
 
-	// correct UKF covariance
-	kp := &mat.Dense{}
-	kp.Mul(gain, pyy)
-	pCorr := &mat.Dense{}
-	pCorr.Mul(kp, gain.T())
-	pCorr.Sub(k.pNext, pCorr)
-
-	// update UKF innovation vector
-	k.inn.CopyVec(inn)
-	k.k.Copy(gain)
-	// update UKF covariance matrix
-	for i := 0; i < nx; i++ {
-		for j := i; j < nx; j++ {
-			k.p.SetSym(i, j, pCorr.At(i, j))
-		}
+	// update UKF innovation vector and gain: missing measurement elements are left zero
+	for i, r := range idx {
+		k.inn.SetVec(r, inn.AtVec(i))
+		k.k.Slice(0, nx, r, r+1).(*mat.Dense).Copy(gain.Slice(0, nx, i, i+1))
 	}
+	// update UKF covariance matrix
+	k.p.CopySym(pCorr)
 
 	return estimate.NewBaseWithCov(x, k.p)
 }
@@ -494,6 +530,16 @@ func (k *UKF) SetCov(cov mat.Symmetric) error {
 	return nil
 }
 
+// Weights returns mean and covariance weights of UKF sigma points
+func (k *UKF) Weights() ([]float64, []float64) {
+	wm := make([]float64, len(k.unit.Wm))
+	copy(wm, k.unit.Wm)
+	wc := make([]float64, len(k.unit.Wc))
+	copy(wc, k.unit.Wc)
+
+	return wm, wc
+}
+
 // Gain returns Kalman gain
 func (k *UKF) Gain() mat.Matrix {
 	gain := &mat.Dense{}
@@ -1,12 +1,15 @@
 package ukf
 
 import (
+	"math"
 	"os"
 	"testing"
 
 	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
 	"github.com/milosgajdos/go-estimate/noise"
 	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/milosgajdos/matrix"
 	"github.com/stretchr/testify/assert"
 	"gonum.org/v1/gonum/mat"
 )
@@ -105,7 +108,6 @@ func TestUKFGenSigmaPoints(t *testing.T) {
 	assert := assert.New(t)
 
 	f, err := New(okModel, ic, q, r, c)
-	//f, err := New(okModel, ic, nil, r, c)
 	assert.NotNil(f)
 	assert.NoError(err)
 
@@ -113,6 +115,39 @@ func TestUKFGenSigmaPoints(t *testing.T) {
 	sp, err := f.GenSigmaPoints(x)
 	assert.NotNil(sp)
 	assert.NoError(err)
+
+	// deprecated weights match sigma point weights
+	wm, wc := f.Weights()
+	assert.Equal(wm[0], f.Wm0)
+	assert.Equal(wc[0], f.Wc0)
+	assert.Equal(wm[1], f.W)
+
+	// sigma points match non-diagonal covariance and Cov is its symmetric square root
+	cov := mat.NewSymDense(2, []float64{0.5, 0.2, 0.2, 0.3})
+	for _, additive := range []bool{false, true} {
+		f, err = New(okModel, ic, q, r, &Config{Alpha: c.Alpha, Beta: c.Beta, Kappa: c.Kappa, Additive: additive})
+		assert.NotNil(f)
+		assert.NoError(err)
+		assert.NoError(f.SetCov(cov))
+
+		sp, err = f.GenSigmaPoints(x)
+		assert.NotNil(sp)
+		assert.NoError(err)
+
+		var spCov mat.Symmetric = cov
+		if !additive {
+			spCov = matrix.BlockSymDiag([]mat.Symmetric{cov, q.Cov(), r.Cov()})
+		}
+
+		sq := &mat.Dense{}
+		sq.Mul(sp.Cov, sp.Cov)
+		assert.True(mat.EqualApprox(spCov, sq, 1e-12))
+
+		wm, wc = f.Weights()
+		m := sigma.Mean(sp.X, wm)
+		assert.True(mat.EqualApprox(x, m.SliceVec(0, 2), 1e-12))
+		assert.True(mat.EqualApprox(spCov, sigma.CrossCov(sp.X, m, sp.X, m, wc), 1e-12))
+	}
 }
 
 func TestUKFPredict(t *testing.T) {
@@ -153,6 +188,15 @@ func TestUKFUpdate(t *testing.T) {
 	assert.NotNil(est)
 	assert.NoError(err)
 
+	// missing measurement
+	for _, _z := range []mat.Vector{nil, mat.NewVecDense(1, []float64{math.NaN()})} {
+		xm := mat.VecDenseCopyOf(x)
+		est, err = f.Update(xm, u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.True(mat.Equal(x, est.Val()))
+	}
+
 	// invalid input vector
 	_u := mat.NewVecDense(3, nil)
 	est, err = f.Update(x, _u, z)
@@ -166,6 +210,47 @@ func TestUKFUpdate(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestUKFPartial(t *testing.T) {
+	assert := assert.New(t)
+
+	// model with two outputs and its reduction to the first output
+	C := mat.NewDense(2, 2, []float64{1.0, 0.0, 1.0, 1.0})
+	D := mat.NewDense(2, 1, []float64{0.0, 0.5})
+	m := &sim.BaseModel{A: okModel.A, B: okModel.B, C: C, D: D}
+	rm := &sim.BaseModel{A: okModel.A, B: okModel.B, C: mat.DenseCopyOf(C.Slice(0, 1, 0, 2)), D: mat.DenseCopyOf(D.Slice(0, 1, 0, 1))}
+
+	_r, _ := noise.NewGaussian([]float64{0, 0}, mat.NewSymDense(2, []float64{0.25, 0, 0, 0.5}))
+	_z := mat.NewVecDense(2, []float64{-1.5, math.NaN()})
+
+	for _, additive := range []bool{false, true} {
+		conf := &Config{Alpha: c.Alpha, Beta: c.Beta, Kappa: c.Kappa, Additive: additive}
+
+		f, err := New(m, ic, q, _r, conf)
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		fr, err := New(rm, ic, q, r, conf)
+		assert.NotNil(fr)
+		assert.NoError(err)
+
+		// partial measurement is equivalent to the measurement of the reduced model
+		est, err := f.Update(mat.VecDenseCopyOf(ic.State()), u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		estR, err := fr.Update(mat.VecDenseCopyOf(ic.State()), u, z)
+		assert.NotNil(estR)
+		assert.NoError(err)
+
+		assert.True(mat.EqualApprox(estR.Val(), est.Val(), 1e-9))
+		assert.True(mat.EqualApprox(estR.Cov(), est.Cov(), 1e-9))
+
+		// gain of the missing measurement element is zero
+		gain := f.Gain()
+		assert.True(mat.EqualApprox(fr.Gain(), gain.(*mat.Dense).Slice(0, 2, 0, 1), 1e-9))
+		assert.Equal([]float64{0, 0}, mat.Col(nil, 1, gain))
+	}
+}
+
 func TestUKFRun(t *testing.T) {
 	assert := assert.New(t)
 
@@ -191,6 +276,55 @@ func TestUKFRun(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestUKFAdditive(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, c)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	fa, err := New(okModel, ic, q, r, &Config{Alpha: c.Alpha, Beta: c.Beta, Kappa: c.Kappa, Additive: true})
+	assert.NotNil(fa)
+	assert.NoError(err)
+
+	// sigma points span only the state
+	x := mat.VecDenseCopyOf(ic.State())
+	sp, err := fa.GenSigmaPoints(x)
+	assert.NoError(err)
+	rows, cols := sp.X.Dims()
+	assert.Equal(2, rows)
+	assert.Equal(5, cols)
+
+	// additive and augmented modes are equivalent for linear models
+	zs := []mat.Vector{z, mat.NewVecDense(1, []float64{math.NaN()}), mat.NewVecDense(1, []float64{0.5})}
+	for _, _z := range zs {
+		pred, err := f.Predict(x, u)
+		assert.NoError(err)
+		predA, err := fa.Predict(x, u)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(pred.Cov(), predA.Cov(), 1e-9))
+
+		est, err := f.Update(mat.VecDenseCopyOf(x), u, _z)
+		assert.NoError(err)
+		estA, err := fa.Update(mat.VecDenseCopyOf(x), u, _z)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(est.Val(), estA.Val(), 1e-9))
+		assert.True(mat.EqualApprox(est.Cov(), estA.Cov(), 1e-9))
+		assert.True(mat.EqualApprox(f.Gain(), fa.Gain(), 1e-9))
+
+		x = mat.VecDenseCopyOf(est.Val())
+	}
+
+	// no noise
+	fa, err = New(okModel, ic, nil, nil, &Config{Alpha: c.Alpha, Beta: c.Beta, Kappa: c.Kappa, Additive: true})
+	assert.NotNil(fa)
+	assert.NoError(err)
+
+	est, err := fa.Run(mat.VecDenseCopyOf(ic.State()), u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+}
+
 func TestUKFModel(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,42 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Observed returns indices of the observed elements of measurement z.
+// Missing measurement elements are marked with NaN; nil z is treated as an entirely missing measurement.
+func Observed(z mat.Vector) []int {
+	if z == nil {
+		return nil
+	}
+
+	idx := make([]int, 0, z.Len())
+	for i := 0; i < z.Len(); i++ {
+		if !math.IsNaN(z.AtVec(i)) {
+			idx = append(idx, i)
+		}
+	}
+
+	return idx
+}
+
+// Mask returns a copy of measurement z with the elements whose mask value is false marked as missing.
+// It returns error if the length of mask is not the same as the length of z.
+func Mask(z mat.Vector, mask []bool) (*mat.VecDense, error) {
+	if z.Len() != len(mask) {
+		return nil, fmt.Errorf("invalid mask length: %d != %d", len(mask), z.Len())
+	}
+
+	m := mat.VecDenseCopyOf(z)
+	for i := range mask {
+		if !mask[i] {
+			m.SetVec(i, math.NaN())
+		}
+	}
+
+	return m, nil
+}
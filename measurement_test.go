@@ -0,0 +1,39 @@
+package filter
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestObserved(t *testing.T) {
+	assert := assert.New(t)
+
+	idx := Observed(nil)
+	assert.Empty(idx)
+
+	z := mat.NewVecDense(3, []float64{1.0, math.NaN(), 3.0})
+	idx = Observed(z)
+	assert.Equal([]int{0, 2}, idx)
+
+	z = mat.NewVecDense(2, []float64{math.NaN(), math.NaN()})
+	idx = Observed(z)
+	assert.Empty(idx)
+}
+
+func TestMask(t *testing.T) {
+	assert := assert.New(t)
+
+	z := mat.NewVecDense(3, []float64{1.0, 2.0, 3.0})
+	m, err := Mask(z, []bool{true, false, true})
+	assert.NoError(err)
+	assert.Equal([]int{0, 2}, Observed(m))
+	// original measurement is not modified
+	assert.Equal(2.0, z.AtVec(1))
+
+	m, err = Mask(z, []bool{true})
+	assert.Nil(m)
+	assert.Error(err)
+}
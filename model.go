@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// stepModel is a time-varying model frozen at a particular time step
+type stepModel struct {
+	// m is time-varying model
+	m TimeVaryingModel
+	// k is time step
+	k int
+}
+
+// AtStep returns discrete model which represents time-varying model m at step k.
+func AtStep(m TimeVaryingModel, k int) DiscreteModel {
+	return &stepModel{m: m, k: k}
+}
+
+// Propagate propagates internal state x of the system from step k to the next step.
+func (s *stepModel) Propagate(x, u, z mat.Vector) (mat.Vector, error) {
+	return s.m.PropagateAt(s.k, x, u, z)
+}
+
+// Observe observes external state of the system at step k.
+func (s *stepModel) Observe(x, u, wn mat.Vector) (mat.Vector, error) {
+	return s.m.ObserveAt(s.k, x, u, wn)
+}
+
+// SystemDims returns dimensions of the time-varying model.
+func (s *stepModel) SystemDims() (nx, nu, ny, nz int) {
+	return s.m.SystemDims()
+}
+
+// SystemMatrix returns state propagation matrix at step k
+func (s *stepModel) SystemMatrix() mat.Matrix {
+	return s.m.SystemMatrixAt(s.k)
+}
+
+// ControlMatrix returns state propagation control matrix at step k
+func (s *stepModel) ControlMatrix() mat.Matrix {
+	return s.m.ControlMatrixAt(s.k)
+}
+
+// OutputMatrix returns observation matrix at step k
+func (s *stepModel) OutputMatrix() mat.Matrix {
+	return s.m.OutputMatrixAt(s.k)
+}
+
+// FeedForwardMatrix returns observation control matrix at step k
+func (s *stepModel) FeedForwardMatrix() mat.Matrix {
+	return s.m.FeedForwardMatrixAt(s.k)
+}
+
+// DisturbanceMatrix returns state disturbance matrix at step k
+func (s *stepModel) DisturbanceMatrix() mat.Matrix {
+	return s.m.DisturbanceMatrixAt(s.k)
+}
+
+// StateNoiseCov maps covariance q of the disturbance input onto the state of model m and returns it.
+// If m provides non-empty disturbance matrix E whose column count matches the dimension of q,
+// the returned covariance is E*Q*E'. Otherwise q must have the same dimension as the model state and it's returned unchanged.
+// It returns error if q can not be mapped onto the model state.
+func StateNoiseCov(m Model, q mat.Symmetric) (mat.Symmetric, error) {
+	nx, _, _, _ := m.SystemDims()
+	nq := q.SymmetricDim()
+
+	if d, ok := m.(Disturber); ok {
+		if e := d.DisturbanceMatrix(); e != nil {
+			rows, cols := e.Dims()
+			if rows > 0 && rows != nx {
+				return nil, fmt.Errorf("invalid disturbance matrix dimensions: [%d x %d]", rows, cols)
+			}
+
+			if cols > 0 && cols == nq {
+				eq := &mat.Dense{}
+				eq.Mul(e, q)
+				eqe := &mat.Dense{}
+				eqe.Mul(eq, e.T())
+
+				cov := mat.NewSymDense(nx, nil)
+				for i := 0; i < nx; i++ {
+					for j := i; j < nx; j++ {
+						cov.SetSym(i, j, 0.5*(eqe.At(i, j)+eqe.At(j, i)))
+					}
+				}
+
+				return cov, nil
+			}
+		}
+	}
+
+	if nq != nx {
+		return nil, fmt.Errorf("dimension %d matches neither state nor disturbance input", nq)
+	}
+
+	return q, nil
+}
@@ -0,0 +1,54 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+// testModel is a model with disturbance matrix
+type testModel struct {
+	Model
+	nx int
+	e  mat.Matrix
+}
+
+func (m *testModel) SystemDims() (nx, nu, ny, nz int) {
+	_, nz = m.e.Dims()
+	return m.nx, 0, 1, nz
+}
+
+func (m *testModel) DisturbanceMatrix() mat.Matrix {
+	return m.e
+}
+
+func TestStateNoiseCov(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &testModel{nx: 2, e: mat.NewDense(2, 1, []float64{1.0, 0.5})}
+
+	// disturbance covariance is mapped through E
+	q := mat.NewSymDense(1, []float64{2.0})
+	cov, err := StateNoiseCov(m, q)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(mat.NewSymDense(2, []float64{2.0, 1.0, 1.0, 0.5}), cov, 1e-12))
+
+	// state covariance is returned unchanged
+	q = mat.NewSymDense(2, []float64{1.0, 0.0, 0.0, 1.0})
+	cov, err = StateNoiseCov(m, q)
+	assert.NoError(err)
+	assert.True(mat.Equal(q, cov))
+
+	// invalid noise dimension
+	q = mat.NewSymDense(3, nil)
+	cov, err = StateNoiseCov(m, q)
+	assert.Nil(cov)
+	assert.Error(err)
+
+	// invalid disturbance matrix
+	m = &testModel{nx: 2, e: mat.NewDense(3, 1, nil)}
+	cov, err = StateNoiseCov(m, mat.NewSymDense(1, nil))
+	assert.Nil(cov)
+	assert.Error(err)
+}
@@ -14,22 +14,33 @@ import (
 type Gaussian struct {
 	// dist is a multivariate normal distribution
 	dist *distmv.Normal
+	// src is a source of randomness
+	src rand.Source
 }
 
 // NewGaussian creates new Gaussian noise with given mean and covariance.
+// The noise is seeded with current time.
 // It returns error if it fails to create Gaussian distribution handle.
 func NewGaussian(mean []float64, cov mat.Symmetric) (*Gaussian, error) {
+	return NewGaussianWithSource(mean, cov, nil)
+}
+
+// NewGaussianWithSource creates new Gaussian noise with given mean and covariance
+// which draws its samples from source of randomness src. If src is nil, the noise is seeded with current time.
+// It returns error if it fails to create Gaussian distribution handle.
+func NewGaussianWithSource(mean []float64, cov mat.Symmetric, src rand.Source) (*Gaussian, error) {
 	if len(mean) != cov.SymmetricDim() {
 		return nil, fmt.Errorf("Incorrect dimensions. Mean: %d, Cov [%d x %d]", len(mean), cov.SymmetricDim(), cov.SymmetricDim())
 	}
 
-	dist, ok := newGaussianDist(mean, cov)
+	dist, ok := newGaussianDist(mean, cov, src)
 	if !ok {
 		return nil, fmt.Errorf("Failed to create new Gaussian noise")
 	}
 
 	return &Gaussian{
 		dist: dist,
+		src:  src,
 	}, nil
 }
 
@@ -53,9 +64,10 @@ func (g *Gaussian) Mean() []float64 {
 }
 
 // Reset resets Gaussian noise: it resets the noise seed.
+// If the noise was created with a source of randomness, it keeps drawing samples from it.
 // It returns error if it fails to reset the noise.
 func (g *Gaussian) Reset() {
-	dist, ok := newGaussianDist(g.Mean(), g.Cov())
+	dist, ok := newGaussianDist(g.Mean(), g.Cov(), g.src)
 	if !ok {
 		panic("Failed to reset Gaussian noise")
 	}
@@ -67,8 +79,10 @@ func (g *Gaussian) String() string {
 	return fmt.Sprintf("Gaussian{\nMean=%v\nCov=%v\n}", g.Mean(), mat.Formatted(g.Cov(), mat.Prefix("    "), mat.Squeeze()))
 }
 
-func newGaussianDist(mean []float64, cov mat.Symmetric) (*distmv.Normal, bool) {
-	seed := rand.New(rand.NewSource(uint64(time.Now().UnixNano())))
+func newGaussianDist(mean []float64, cov mat.Symmetric, src rand.Source) (*distmv.Normal, bool) {
+	if src == nil {
+		src = rand.NewSource(uint64(time.Now().UnixNano()))
+	}
 
-	return distmv.NewNormal(mean, cov, seed)
+	return distmv.NewNormal(mean, cov, src)
 }
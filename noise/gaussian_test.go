@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -97,6 +98,36 @@ func TestGaussianSample(t *testing.T) {
 	}
 }
 
+func TestGaussianSource(t *testing.T) {
+	assert := assert.New(t)
+
+	mean := []float64{2, 3}
+	cov := mat.NewSymDense(2, []float64{1, 0.1, 0.1, 1})
+
+	// noise with the same seed generates the same samples
+	g1, err := NewGaussianWithSource(mean, cov, rand.NewSource(1))
+	assert.NotNil(g1)
+	assert.NoError(err)
+
+	g2, err := NewGaussianWithSource(mean, cov, rand.NewSource(1))
+	assert.NotNil(g2)
+	assert.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		assert.True(mat.Equal(g1.Sample(), g2.Sample()))
+	}
+
+	// reset keeps drawing from the source
+	g1.Reset()
+	g2.Reset()
+	assert.True(mat.Equal(g1.Sample(), g2.Sample()))
+
+	// invalid mean
+	g, err := NewGaussianWithSource([]float64{2}, cov, rand.NewSource(1))
+	assert.Nil(g)
+	assert.Error(err)
+}
+
 func TestGaussianReset(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,368 @@
+package apf
+
+import (
+	"fmt"
+	"math"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/particle"
+	"github.com/milosgajdos/go-estimate/particle/bf"
+	"github.com/milosgajdos/go-estimate/rand"
+	xrand "golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distmv"
+)
+
+// APF is an Auxiliary Particle Filter.
+// Before propagating the particles it pre-weights them using the likelihood of the measurement
+// at their look-ahead points, i.e. the particles propagated without state noise, and resamples them.
+// Only the particles which are likely to explain the measurement are then propagated,
+// which greatly reduces weight degeneracy for peaked likelihoods.
+// For more information about Auxiliary Particle Filter see:
+// https://en.wikipedia.org/wiki/Auxiliary_particle_filter
+type APF struct {
+	// Resampling is first stage resampling scheme; Multinomial by default
+	Resampling bf.Resampling
+	// ess is effective sample size calculated in the last Update
+	ess float64
+	// model is auxiliary particle filter model
+	model filter.Model
+	// w stores particle weights
+	w []float64
+	// lw stores logarithms of particle weights
+	lw []float64
+	// x stores filter particles as column vectors
+	x *mat.Dense
+	// mu stores look-ahead points calculated in Predict
+	mu *mat.Dense
+	// q is state noise a.k.a. process noise
+	q filter.Noise
+	// inn stores a diff between measurement vector and particular particle output
+	inn []float64
+	// errPDF is PDF (Probability Density Function) of filter output error
+	errPDF distmv.LogProber
+	// src is source of randomness used to generate, resample and propagate particles
+	src xrand.Source
+}
+
+// New creates new Auxiliary Particle Filter (APF) with the following parameters and returns it:
+// - m:     system model
+// - init:  initial condition of the filter
+// - q:     state  noise a.k.a. process noise
+// - r:     output  noise a.k.a. measurement noise
+// - p:     number of filter particles
+// - pdf:   Probability Density Function (PDF) of filter output error
+// Output noise is only validated: particles are weighted using pdf which accounts for it.
+// New returns error if non-positive number of particles is given or if the particles fail to be generated.
+func New(m filter.Model, ic filter.InitCond, q, r filter.Noise, p int, pdf distmv.LogProber) (*APF, error) {
+	return NewWithSource(m, ic, q, r, p, pdf, nil)
+}
+
+// NewWithSource creates new Auxiliary Particle Filter (APF) which generates, resamples and propagates its particles
+// using source of randomness src and returns it. If src is nil, the global source is used and state noise is sampled from q.
+// Otherwise state noise samples are drawn from src using the mean and covariance of q, so two filters created with
+// sources seeded with the same seed produce the same results.
+// See New for the description of the other parameters.
+// NewWithSource returns error if non-positive number of particles is given or if the particles fail to be generated.
+func NewWithSource(m filter.Model, ic filter.InitCond, q, r filter.Noise, p int, pdf distmv.LogProber, src xrand.Source) (*APF, error) {
+	q, _, err := particle.ValidateNoise(m, q, r)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := particle.Draw(ic, p, src)
+	if err != nil {
+		return nil, err
+	}
+
+	w, lw := particle.Weights(p)
+
+	_, _, ny, _ := m.SystemDims()
+	inn := make([]float64, ny)
+
+	return &APF{
+		ess:    float64(p),
+		model:  m,
+		w:      w,
+		lw:     lw,
+		x:      x,
+		q:      q,
+		inn:    inn,
+		errPDF: pdf,
+		src:    src,
+	}, nil
+}
+
+// Predict calculates look-ahead points of filter particles given input u and returns their estimate.
+// Look-ahead points are the particles propagated to the next step without state noise.
+// The particles themselves are propagated in Update as their propagation depends on the measurement.
+// The returned estimate is the weighted mean of look-ahead points; its covariance is their weighted
+// covariance increased by the state noise covariance. The state x is not used.
+// It returns error if it fails to calculate the look-ahead points.
+func (a *APF) Predict(x, u mat.Vector) (filter.Estimate, error) {
+	mu, err := a.lookAhead(u)
+	if err != nil {
+		return nil, err
+	}
+
+	qCov, err := filter.StateNoiseCov(a.model, a.q.Cov())
+	if err != nil {
+		return nil, fmt.Errorf("failed to map state noise: %v", err)
+	}
+
+	muMean := particle.Mean(mu, a.w)
+	cov := particle.Cov(mu, muMean, a.w)
+	cov.AddSym(cov, qCov)
+
+	a.mu = mu
+
+	return estimate.NewBaseWithCov(muMean, cov)
+}
+
+// Update propagates filter particles and corrects them using the measurement z given control intput u.
+// It returns the weighted mean of the particles with their weighted covariance.
+// Particles are first resampled using the likelihood of z at their look-ahead points calculated in Predict;
+// if Predict has not been called, the look-ahead points are calculated using u. The resampled particles
+// are then propagated and weighted by the ratio of the likelihood of z at their new and look-ahead points.
+// Missing measurement elements can be marked with NaN: particle weights are then updated using
+// the marginal PDF of the observed elements which requires the filter output error PDF to be *distmv.Normal.
+// If the measurement is missing entirely (nil or all NaN) particles are propagated and their weights are not updated.
+// The state x is not used.
+// It returns error if it fails to propagate or observe particles or if the size of z is invalid.
+// It returns *bf.DegeneracyError if all particle weights vanish; filter particles are left unchanged in that case.
+func (a *APF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
+	if z != nil && z.Len() != len(a.inn) {
+		return nil, fmt.Errorf("invalid measurement size: %d", z.Len())
+	}
+
+	mu := a.mu
+	if mu == nil {
+		var err error
+		if mu, err = a.lookAhead(u); err != nil {
+			return nil, err
+		}
+	}
+
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: particles are propagated and their weights remain unchanged
+		indices := make([]int, len(a.w))
+		for i := range indices {
+			indices[i] = i
+		}
+
+		xNext, err := a.propagate(indices, u)
+		if err != nil {
+			return nil, err
+		}
+
+		a.x.Copy(xNext)
+		a.mu = nil
+
+		return a.estimate()
+	}
+
+	// PDF of the observed measurement elements error
+	errPDF, err := particle.MarginalPDF(a.errPDF, idx, len(a.inn))
+	if err != nil {
+		return nil, err
+	}
+
+	// first stage weights: likelihood of the measurement at look-ahead points
+	muLogLik, err := a.logLik(mu, u, z, idx, errPDF)
+	if err != nil {
+		return nil, err
+	}
+
+	lambda := make([]float64, len(a.lw))
+	floats.AddTo(lambda, a.lw, muLogLik)
+
+	logSum := floats.LogSumExp(lambda)
+	if math.IsInf(logSum, 0) || math.IsNaN(logSum) {
+		return nil, &bf.DegeneracyError{LogSum: logSum}
+	}
+
+	for i := range lambda {
+		lambda[i] = math.Exp(lambda[i] - logSum)
+	}
+
+	// resample particles which are likely to explain the measurement
+	indices, err := a.Resampling.DrawN(lambda, len(lambda), a.src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample filter particles: %v", err)
+	}
+
+	xNext, err := a.propagate(indices, u)
+	if err != nil {
+		return nil, err
+	}
+
+	// second stage weights: likelihood ratio of the propagated particles and their look-ahead points
+	logLik, err := a.logLik(xNext, u, z, idx, errPDF)
+	if err != nil {
+		return nil, err
+	}
+
+	lw := make([]float64, len(logLik))
+	for i, k := range indices {
+		lw[i] = logLik[i] - muLogLik[k]
+	}
+
+	logSum = floats.LogSumExp(lw)
+	if math.IsInf(logSum, 0) || math.IsNaN(logSum) {
+		return nil, &bf.DegeneracyError{LogSum: logSum}
+	}
+
+	// it's now safe to update the internal state of the filter
+	for i := range lw {
+		a.lw[i] = lw[i] - logSum
+		a.w[i] = math.Exp(a.lw[i])
+	}
+	a.x.Copy(xNext)
+	a.mu = nil
+	a.ess = particle.ESS(a.w)
+
+	return a.estimate()
+}
+
+// lookAhead propagates filter particles without state noise given input u and returns them.
+// It returns error if any particle fails to be propagated.
+func (a *APF) lookAhead(u mat.Vector) (*mat.Dense, error) {
+	rows, cols := a.x.Dims()
+	mu := mat.NewDense(rows, cols, nil)
+
+	for c := 0; c < cols; c++ {
+		muNext, err := a.model.Propagate(a.x.ColView(c), u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("particle look-ahead propagation failed: %v", err)
+		}
+		mu.Slice(0, muNext.Len(), c, c+1).(*mat.Dense).Copy(muNext)
+	}
+
+	return mu, nil
+}
+
+// propagate propagates filter particles with given indices to the next step given input u and returns them.
+// It returns error if any particle fails to be propagated.
+func (a *APF) propagate(indices []int, u mat.Vector) (*mat.Dense, error) {
+	rows, _ := a.x.Dims()
+	x := mat.NewDense(rows, len(indices), nil)
+
+	wq, err := a.stateNoise(len(indices))
+	if err != nil {
+		return nil, err
+	}
+
+	for c, k := range indices {
+		xNext, err := a.model.Propagate(a.x.ColView(k), u, wq[c])
+		if err != nil {
+			return nil, fmt.Errorf("particle state propagation failed: %v", err)
+		}
+		x.Slice(0, xNext.Len(), c, c+1).(*mat.Dense).Copy(xNext)
+	}
+
+	return x, nil
+}
+
+// stateNoise draws n state noise samples and returns them.
+// If the filter has a source of randomness, the samples are drawn from it using the mean and covariance of the state noise.
+// It returns error if the samples fail to be drawn.
+func (a *APF) stateNoise(n int) ([]mat.Vector, error) {
+	wq := make([]mat.Vector, n)
+
+	mean := a.q.Mean()
+	if a.src == nil || len(mean) == 0 {
+		for i := range wq {
+			wq[i] = a.q.Sample()
+		}
+
+		return wq, nil
+	}
+
+	samples, err := rand.WithCovNSource(a.q.Cov(), n, a.src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample state noise: %v", err)
+	}
+
+	for i := range wq {
+		w := mat.VecDenseCopyOf(samples.ColView(i))
+		w.AddVec(w, mat.NewVecDense(len(mean), mean))
+		wq[i] = w
+	}
+
+	return wq, nil
+}
+
+// logLik calculates log likelihood of the observed elements idx of measurement z
+// for every state stored in columns of x given input u and returns them.
+// It returns error if any of the states fails to be observed.
+func (a *APF) logLik(x *mat.Dense, u, z mat.Vector, idx []int, errPDF distmv.LogProber) ([]float64, error) {
+	_, cols := x.Dims()
+	ll := make([]float64, cols)
+
+	// innovation vector of observed measurement elements
+	inn := a.inn[:len(idx)]
+
+	for c := 0; c < cols; c++ {
+		y, err := a.model.Observe(x.ColView(c), u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("particle state observation failed: %v", err)
+		}
+
+		for i, r := range idx {
+			inn[i] = z.AtVec(r) - y.AtVec(r)
+		}
+		ll[c] = errPDF.LogProb(inn)
+	}
+
+	return ll, nil
+}
+
+// estimate returns weighted mean of filter particles with their weighted covariance
+func (a *APF) estimate() (filter.Estimate, error) {
+	xMean := particle.Mean(a.x, a.w)
+
+	return estimate.NewBaseWithCov(xMean, particle.Cov(a.x, xMean, a.w))
+}
+
+// Run runs one step of Auxiliary Particle Filter for given state x, input u and measurement z.
+// It corrects system state estimate x using measurement z and returns a new state estimate.
+// It returns error if it either fails to propagate particles or update the state x.
+func (a *APF) Run(x, u, z mat.Vector) (filter.Estimate, error) {
+	pred, err := a.Predict(x, u)
+	if err != nil {
+		return nil, err
+	}
+
+	est, err := a.Update(pred.Val(), u, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return est, nil
+}
+
+// ESS returns effective sample size of filter particles calculated in the last Update.
+// Effective sample size 1/sum(w^2) ranges from 1 for degenerate weights to particle count for equal weights.
+func (a *APF) ESS() float64 {
+	return a.ess
+}
+
+// Particles returns APF particles
+func (a *APF) Particles() mat.Matrix {
+	p := &mat.Dense{}
+	p.CloneFrom(a.x)
+
+	return p
+}
+
+// Weights returns a vector containing APF particle weights
+func (a *APF) Weights() mat.Vector {
+	data := make([]float64, len(a.w))
+	copy(data, a.w)
+
+	return mat.NewVecDense(len(data), data)
+}
@@ -0,0 +1,381 @@
+package apf
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/particle"
+	"github.com/milosgajdos/go-estimate/particle/bf"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distmv"
+)
+
+type invalidModel struct {
+	filter.Model
+}
+
+func (m *invalidModel) SystemDims() (nx, nu, ny, nz int) {
+	return -10, 0, 8, 0
+}
+
+// constPDF returns the same log probability for any error
+type constPDF struct {
+	logProb float64
+}
+
+func (c *constPDF) LogProb(x []float64) float64 {
+	return c.logProb
+}
+
+// APF is a particle filter
+var _ particle.Particle = (*APF)(nil)
+
+var (
+	okModel  *sim.BaseModel
+	badModel *invalidModel
+	ic       *sim.InitCond
+	p        int
+	u        *mat.VecDense
+	z        *mat.VecDense
+	q        filter.Noise
+	r        filter.Noise
+	errPDF   distmv.LogProber
+)
+
+func setup() {
+	// PF parameters
+	p = 10
+	outCov := mat.NewSymDense(1, []float64{0.25})
+	errPDF, _ = distmv.NewNormal([]float64{0}, outCov, nil)
+
+	u = mat.NewVecDense(1, []float64{-1.0})
+	z = mat.NewVecDense(1, []float64{-1.5})
+
+	// initial condition
+	initState := mat.NewVecDense(2, []float64{1.0, 3.0})
+	initCov := mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25})
+	ic = sim.NewInitCond(initState, initCov)
+
+	// state and output noise
+	q, _ = noise.NewGaussian([]float64{0, 0}, initCov)
+	r, _ = noise.NewGaussian([]float64{0}, mat.NewSymDense(1, []float64{0.25}))
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	B := mat.NewDense(2, 1, []float64{0.5, 1.0})
+	C := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	D := mat.NewDense(1, 1, []float64{0.0})
+
+	okModel = &sim.BaseModel{A: A, B: B, C: C, D: D}
+	badModel = &invalidModel{okModel}
+}
+
+func TestMain(m *testing.M) {
+	// set up tests
+	setup()
+	// run the tests
+	retCode := m.Run()
+	// call with result of m.Run()
+	os.Exit(retCode)
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+	assert.Equal(float64(p), f.ESS())
+
+	// nil noise
+	f, err = New(okModel, ic, nil, nil, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// invalid particle count
+	f, err = New(okModel, ic, q, r, -10, errPDF)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid model
+	f, err = New(badModel, ic, q, r, p, errPDF)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid state noise
+	_q, _ := noise.NewZero(3)
+	f, err = New(okModel, ic, _q, r, p, errPDF)
+	assert.Nil(f)
+	assert.Error(err)
+
+	// invalid output noise
+	f, err = New(okModel, ic, q, q, p, errPDF)
+	assert.Nil(f)
+	assert.Error(err)
+}
+
+func TestPredict(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, nil, r, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := f.Particles()
+
+	est, err := f.Predict(nil, u)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// particles are not propagated until Update
+	assert.True(mat.Equal(x, f.Particles()))
+
+	// prediction is the mean of particles propagated without state noise
+	mu := &mat.Dense{}
+	mu.Mul(okModel.SystemMatrix(), x)
+	xMean := particle.Mean(mu, f.w)
+	xMean.AddVec(xMean, mat.NewVecDense(2, []float64{-0.5, -1.0}))
+	assert.True(mat.EqualApprox(xMean, est.Val(), 1e-12))
+	assert.Greater(est.Cov().At(0, 0), 0.0)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Predict(nil, _u)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// update without prediction
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+	est, err := f.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+	assert.InDelta(1.0, floats.Sum(f.w), 1e-12)
+	assert.Greater(est.Cov().At(0, 0), 0.0)
+
+	// missing measurement: particles are propagated and weights are kept
+	for _, _z := range []mat.Vector{nil, mat.NewVecDense(1, []float64{math.NaN()})} {
+		px := f.Particles()
+		w := f.Weights()
+
+		_, err = f.Predict(x, u)
+		assert.NoError(err)
+		est, err = f.Update(x, u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+		assert.False(mat.Equal(px, f.Particles()))
+		assert.True(mat.Equal(w, f.Weights()))
+	}
+
+	// invalid measurement
+	est, err = f.Update(x, u, mat.NewVecDense(3, nil))
+	assert.Nil(est)
+	assert.Error(err)
+
+	// invalid input vector
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Update(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	// degenerate weights
+	for _, logProb := range []float64{math.Inf(-1), math.NaN()} {
+		f, err = New(okModel, ic, q, r, p, &constPDF{logProb: logProb})
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		px := f.Particles()
+		est, err = f.Update(x, u, z)
+		assert.Nil(est)
+		assert.Error(err)
+
+		var degErr *bf.DegeneracyError
+		assert.True(errors.As(err, &degErr))
+		assert.True(mat.Equal(px, f.Particles()))
+	}
+}
+
+func TestUpdatePartial(t *testing.T) {
+	assert := assert.New(t)
+
+	C := mat.NewDense(2, 2, []float64{1.0, 0.0, 0.0, 1.0})
+	D := mat.NewDense(2, 1, []float64{0.0, 0.0})
+	m := &sim.BaseModel{A: okModel.A, B: okModel.B, C: C, D: D}
+
+	outCov := mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25})
+	pdf, _ := distmv.NewNormal([]float64{0, 0}, outCov, nil)
+	_r, _ := noise.NewGaussian([]float64{0, 0}, outCov)
+
+	f, err := New(m, ic, q, _r, p, pdf)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	_z := mat.NewVecDense(2, []float64{-1.5, math.NaN()})
+	est, err := f.Run(nil, u, _z)
+	assert.NotNil(est)
+	assert.NoError(err)
+
+	// partial measurement requires Gaussian PDF
+	f, err = New(m, ic, q, _r, p, &constPDF{logProb: 0.0})
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	est, err = f.Run(nil, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+
+	f, err := New(okModel, ic, q, r, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	for _, rs := range []bf.Resampling{bf.Multinomial, bf.Systematic, bf.Stratified, bf.Residual} {
+		f.Resampling = rs
+		est, err := f.Run(x, u, z)
+		assert.NotNil(est)
+		assert.NoError(err)
+	}
+
+	// invalid resampling scheme
+	f.Resampling = bf.Resampling(10)
+	est, err := f.Run(x, u, z)
+	assert.Nil(est)
+	assert.Error(err)
+	f.Resampling = bf.Multinomial
+
+	// Predict error
+	_u := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+
+	_z := mat.NewVecDense(3, nil)
+	est, err = f.Run(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestSource(t *testing.T) {
+	assert := assert.New(t)
+
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+
+	// filters seeded with the same seed produce the same particles
+	for _, rs := range []bf.Resampling{bf.Multinomial, bf.Systematic, bf.Stratified, bf.Residual} {
+		s, err := NewWithSource(okModel, ic, q, r, 101, errPDF, rand.NewSource(3))
+		assert.NoError(err)
+		f, err := NewWithSource(okModel, ic, q, r, 101, errPDF, rand.NewSource(3))
+		assert.NoError(err)
+		assert.True(mat.Equal(s.Particles(), f.Particles()))
+
+		s.Resampling = rs
+		f.Resampling = rs
+
+		for i := 0; i < 3; i++ {
+			estS, err := s.Run(x, u, z)
+			assert.NoError(err)
+			est, err := f.Run(x, u, z)
+			assert.NoError(err)
+			assert.True(mat.Equal(estS.Val(), est.Val()), rs)
+			assert.True(mat.Equal(estS.Cov(), est.Cov()), rs)
+		}
+		assert.True(mat.Equal(s.Particles(), f.Particles()), rs)
+		assert.True(mat.Equal(s.Weights(), f.Weights()), rs)
+
+		// missing measurement propagates particles using the source
+		particles := s.Particles()
+		_, err = s.Update(x, u, nil)
+		assert.NoError(err)
+		_, err = f.Update(x, u, nil)
+		assert.NoError(err)
+		assert.False(mat.Equal(particles, s.Particles()), rs)
+		assert.True(mat.Equal(s.Particles(), f.Particles()), rs)
+	}
+
+	// different seeds generate different particles
+	s, err := NewWithSource(okModel, ic, q, r, 101, errPDF, rand.NewSource(3))
+	assert.NoError(err)
+	f, err := NewWithSource(okModel, ic, q, r, 101, errPDF, rand.NewSource(4))
+	assert.NoError(err)
+	assert.False(mat.Equal(s.Particles(), f.Particles()))
+
+	// zero state noise
+	f, err = NewWithSource(okModel, ic, nil, r, 101, errPDF, rand.NewSource(3))
+	assert.NoError(err)
+	est, err := f.Run(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+}
+
+func TestPeakedLikelihood(t *testing.T) {
+	assert := assert.New(t)
+
+	// wide prior, peaked likelihood and state noise much smaller than output noise
+	initCov := mat.NewSymDense(2, []float64{25.0, 0, 0, 25.0})
+	_ic := sim.NewInitCond(ic.State(), initCov)
+	_q, _ := noise.NewGaussian([]float64{0, 0}, mat.NewSymDense(2, []float64{1e-4, 0, 0, 1e-4}))
+	_r, _ := noise.NewGaussian([]float64{0}, mat.NewSymDense(1, []float64{0.01}))
+	pdf, _ := distmv.NewNormal([]float64{0}, mat.NewSymDense(1, []float64{0.01}), nil)
+
+	n := 1000
+	a, err := New(okModel, _ic, _q, _r, n, pdf)
+	assert.NotNil(a)
+	assert.NoError(err)
+
+	b, err := bf.New(okModel, _ic, _q, _r, n, pdf)
+	assert.NotNil(b)
+	assert.NoError(err)
+
+	_, err = a.Run(nil, u, z)
+	assert.NoError(err)
+	_, err = b.Run(ic.State(), u, z)
+	assert.NoError(err)
+
+	// look-ahead pre-weighting reduces weight degeneracy
+	assert.Greater(a.ESS(), 2*b.ESS())
+}
+
+func TestParticles(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	rows, cols := f.Particles().Dims()
+	assert.Equal(2, rows)
+	assert.Equal(p, cols)
+}
+
+func TestWeights(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	weights := f.Weights()
+	assert.Equal(p, weights.Len())
+	for i := range f.w {
+		assert.InDelta(f.w[i], weights.AtVec(i), 1e-12)
+	}
+}
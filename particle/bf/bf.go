@@ -3,25 +3,132 @@ package bf
 import (
 	"fmt"
 	"math"
+	"sync"
 
 	filter "github.com/milosgajdos/go-estimate"
 	"github.com/milosgajdos/go-estimate/estimate"
-	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/particle"
 	"github.com/milosgajdos/go-estimate/rand"
 	"github.com/milosgajdos/matrix"
+	xrand "golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/mat"
 	"gonum.org/v1/gonum/stat/distmv"
 )
 
+// Resampling is particle resampling scheme
+type Resampling int
+
+const (
+	// Multinomial draws every particle independently from the weights PMF
+	Multinomial Resampling = iota
+	// Systematic draws particles using a single random offset into evenly spaced CDF positions
+	Systematic
+	// Stratified draws a particle from every one of evenly sized CDF strata
+	Stratified
+	// Residual copies particles deterministically according to their weights
+	// and draws the remaining particles from the residual weights
+	Residual
+)
+
+// String implements fmt.Stringer interface
+func (r Resampling) String() string {
+	switch r {
+	case Multinomial:
+		return "Multinomial"
+	case Systematic:
+		return "Systematic"
+	case Stratified:
+		return "Stratified"
+	case Residual:
+		return "Residual"
+	}
+
+	return fmt.Sprintf("Resampling(%d)", int(r))
+}
+
+// DrawN draws n particle indices from weights w using resampling scheme r and source of randomness src
+// and returns them. If src is nil, the global source is used.
+// It returns error if w is empty or if the resampling scheme is invalid.
+func (r Resampling) DrawN(w []float64, n int, src xrand.Source) ([]int, error) {
+	switch r {
+	case Multinomial:
+		return rand.RouletteDrawNSource(w, n, src)
+	case Systematic:
+		return rand.SystematicDrawNSource(w, n, src)
+	case Stratified:
+		return rand.StratifiedDrawNSource(w, n, src)
+	case Residual:
+		return rand.ResidualDrawNSource(w, n, src)
+	}
+
+	return nil, fmt.Errorf("invalid resampling scheme: %v", r)
+}
+
+// PointEstimate is particle filter point estimate
+type PointEstimate int
+
+const (
+	// Mean is weighted mean of particles
+	Mean PointEstimate = iota
+	// MAP is maximum a posteriori estimate approximated by the particle with the largest weight
+	MAP
+	// Median is component-wise weighted median of particles
+	Median
+)
+
+// String implements fmt.Stringer interface
+func (p PointEstimate) String() string {
+	switch p {
+	case Mean:
+		return "Mean"
+	case MAP:
+		return "MAP"
+	case Median:
+		return "Median"
+	}
+
+	return fmt.Sprintf("PointEstimate(%d)", int(p))
+}
+
+// DegeneracyError is returned when particle weights degenerate i.e. when none of the filter particles
+// explains the measurement and all their weights vanish.
+type DegeneracyError struct {
+	// LogSum is logarithm of the sum of unnormalized particle weights
+	LogSum float64
+}
+
+// Error implements error interface
+func (e *DegeneracyError) Error() string {
+	return fmt.Sprintf("degenerate particle weights: log of weights sum: %f", e.LogSum)
+}
+
 // BF is a Bootstrap Filter a.k.a. SIR Particle Filter.
 // For more information about Bootstrap Filter see:
 // https://en.wikipedia.org/wiki/Particle_filter#The_bootstrap_filter
 type BF struct {
+	// Resampling is particle resampling scheme; Multinomial by default
+	Resampling Resampling
+	// ESSThreshold is a fraction of particle count in [0,1]: particles are resampled automatically
+	// after Update whenever the effective sample size drops below ESSThreshold times particle count.
+	// Zero disables automatic resampling.
+	ESSThreshold float64
+	// Alpha is regularization parameter used by automatic resampling; see Resample
+	Alpha float64
+	// Point is point estimate returned by Update; Mean by default
+	Point PointEstimate
+	// Workers is the number of goroutines which propagate and observe filter particles.
+	// Particles are split into contiguous blocks of columns, one per worker, so the model
+	// must be safe for concurrent use. Values less than 2 process the particles serially.
+	Workers int
+	// ess is effective sample size calculated in the last Update
+	ess float64
 	// model is bootstrap filter model
 	model filter.Model
 	// w stores particle weights
 	w []float64
+	// lw stores logarithms of particle weights
+	lw []float64
 	// x stores filter particles as column vectors
 	x *mat.Dense
 	// y stores particle outputs
@@ -37,6 +144,8 @@ type BF struct {
 	inn []float64
 	// errPDF is PDF (Probability Density Function) of filter output error
 	errPDF distmv.LogProber
+	// src is source of randomness used to generate and resample particles
+	src xrand.Source
 }
 
 // New creates new Particle Filter (PF) with the following parameters and returns it:
@@ -48,71 +157,49 @@ type BF struct {
 // - pdf:   Probability Density Function (PDF) of filter output error
 // New returns error if non-positive number of particles is given or if the particles fail to be generated.
 func New(m filter.Model, ic filter.InitCond, q, r filter.Noise, p int, pdf distmv.LogProber) (*BF, error) {
-	// must have at least one particle; can't be negative
-	if p <= 0 {
-		return nil, fmt.Errorf("invalid particle count: %d", p)
-	}
-
-	// size of input and output vectors
-	nx, _, ny, _ := m.SystemDims()
-	if nx <= 0 || ny <= 0 {
-		return nil, fmt.Errorf("invalid model dimensions: [%d x %d]", nx, ny)
-	}
-
-	if q != nil {
-		if q.Cov().SymmetricDim() != nx {
-			return nil, fmt.Errorf("invalid state noise dimension: %d", q.Cov().SymmetricDim())
-		}
-	} else {
-		q, _ = noise.NewZero(nx)
-	}
-
-	if r != nil {
-		if r.Cov().SymmetricDim() != ny {
-			return nil, fmt.Errorf("invalid output noise dimension: %d", r.Cov().SymmetricDim())
-		}
-	} else {
-		r, _ = noise.NewZero(ny)
-	}
+	return NewWithSource(m, ic, q, r, p, pdf, nil)
+}
 
-	// Initialize particle weights to equal probabilities:
-	// particle weights must sum up to 1 to represent probability
-	w := make([]float64, p)
-	for i := range w {
-		w[i] = 1 / float64(p)
+// NewWithSource creates new Particle Filter (PF) which generates and resamples its particles using source
+// of randomness src and returns it. If src is nil, the global source is used. Two filters created with
+// sources seeded with the same seed produce the same results as long as their noise samples are the same.
+// See New for the description of the other parameters.
+// NewWithSource returns error if non-positive number of particles is given or if the particles fail to be generated.
+func NewWithSource(m filter.Model, ic filter.InitCond, q, r filter.Noise, p int, pdf distmv.LogProber, src xrand.Source) (*BF, error) {
+	q, r, err := particle.ValidateNoise(m, q, r)
+	if err != nil {
+		return nil, err
 	}
 
-	// draw particles from distribution with covariance InitCond.Cov()
-	x, err := rand.WithCovN(ic.Cov(), p)
+	x, err := particle.Draw(ic, p, src)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate filter particles: %v", err)
+		return nil, err
 	}
 
-	rows, cols := x.Dims()
-	// center particles around initial state condition init.State()
-	for c := 0; c < cols; c++ {
-		for r := 0; r < rows; r++ {
-			x.Set(r, c, x.At(r, c)+ic.State().AtVec(r))
-		}
-	}
+	w, lw := particle.Weights(p)
 
+	_, _, ny, _ := m.SystemDims()
 	y := mat.NewDense(ny, p, nil)
 	inn := make([]float64, ny)
 
 	return &BF{
+		ess:    float64(p),
 		model:  m,
 		w:      w,
+		lw:     lw,
 		x:      x,
 		y:      y,
 		q:      q,
 		r:      r,
 		inn:    inn,
 		errPDF: pdf,
+		src:    src,
 	}, nil
 }
 
 // Predict estimates the next system state and its output given the state x and input u and returns it.
 // Predict modifies internal state of the filter: it updates its particle with their predicted values.
+// Covariance of the returned estimate is the weighted covariance of the predicted particles.
 // It returns error if it fails to propagate either the filter particles or x to the next state.
 func (b *BF) Predict(x, u mat.Vector) (filter.Estimate, error) {
 	// propagate input state to the next step
@@ -124,74 +211,220 @@ func (b *BF) Predict(x, u mat.Vector) (filter.Estimate, error) {
 	r, c := b.x.Dims()
 	xPred := mat.NewDense(r, c, nil)
 
+	// noise is sampled serially so the results don't depend on the number of workers
+	wd := make([]mat.Vector, c)
+	for c := range wd {
+		wd[c] = b.q.Sample()
+	}
+
 	// propagate filter particles to the next step
-	for c := range b.w {
-		xPartNext, err := b.model.Propagate(b.x.ColView(c), u, b.q.Sample())
+	err = b.forEach(c, func(c int) error {
+		xPartNext, err := b.model.Propagate(b.x.ColView(c), u, wd[c])
 		if err != nil {
-			return nil, fmt.Errorf("particle state propagation failed: %v", err)
+			return fmt.Errorf("particle state propagation failed: %v", err)
 		}
 		xPred.Slice(0, xPartNext.Len(), c, c+1).(*mat.Dense).Copy(xPartNext)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// update filter particles and their observed outputs
 	b.x.Copy(xPred)
 
-	return estimate.NewBase(xNext)
+	return estimate.NewBaseWithCov(xNext, particle.Cov(b.x, particle.Mean(b.x, b.w), b.w))
 }
 
 // Update corrects state x using the measurement z given control intput u and returns the corrected estimate.
-// It returns error if it fails to calculate system output estimate or if the size of z is invalid.
+// Particle weights are updated in log space so they don't underflow even for very informative measurements.
+// The returned estimate is the point estimate selected by Point with the weighted covariance of the particles.
+// Missing measurement elements can be marked with NaN: particle weights are then updated using
+// the marginal PDF of the observed elements which requires the filter output error PDF to be *distmv.Normal.
+// If the measurement is missing entirely (nil or all NaN) particle weights are not updated.
+// It returns error if it fails to calculate system output estimate, if the size of z is invalid
+// or if ESSThreshold is outside [0,1].
+// It returns *DegeneracyError if all particle weights vanish; particle weights are left unchanged in that case.
 func (b *BF) Update(x, u, z mat.Vector) (filter.Estimate, error) {
-	if z.Len() != len(b.inn) {
+	if !(b.ESSThreshold >= 0 && b.ESSThreshold <= 1) {
+		return nil, fmt.Errorf("invalid ESS threshold: %v", b.ESSThreshold)
+	}
+
+	if z != nil && z.Len() != len(b.inn) {
 		return nil, fmt.Errorf("invalid measurement size: %d", z.Len())
 	}
 
+	// indices of observed measurement elements
+	idx := filter.Observed(z)
+	if len(idx) == 0 {
+		// no measurement: particles estimate remains unchanged
+		return b.estimate()
+	}
+
+	// PDF of the observed measurement elements error
+	errPDF, err := particle.MarginalPDF(b.errPDF, idx, len(b.inn))
+	if err != nil {
+		return nil, err
+	}
+
 	r, c := b.y.Dims()
 	yPred := mat.NewDense(r, c, nil)
 
+	// noise is sampled serially so the results don't depend on the number of workers
+	wn := make([]mat.Vector, c)
+	for c := range wn {
+		wn[c] = b.r.Sample()
+	}
+
 	// observe system output for each particle
-	for c := range b.w {
-		yPart, err := b.model.Observe(b.x.ColView(c), u, b.r.Sample())
+	err = b.forEach(c, func(c int) error {
+		yPart, err := b.model.Observe(b.x.ColView(c), u, wn[c])
 		if err != nil {
-			return nil, fmt.Errorf("particle state observation failed: %v", err)
+			return fmt.Errorf("particle state observation failed: %v", err)
 		}
 		yPred.Slice(0, yPart.Len(), c, c+1).(*mat.Dense).Copy(yPart)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Update particle weights:
+	// innovation vector of observed measurement elements
+	inn := b.inn[:len(idx)]
+
+	// Update particle weights in log space to avoid their underflow:
 	// - calculate observation error for each particle output
-	// - multiply the resulting error with particle weight
-	//inn := new(mat.Dense)
-	for c := range b.w {
-		for r := 0; r < z.Len(); r++ {
-			b.inn[r] = z.At(r, 0) - yPred.ColView(c).AtVec(r)
+	// - add log probability of the resulting error to particle log weight
+	lw := make([]float64, len(b.lw))
+	for c := range b.lw {
+		for i, r := range idx {
+			inn[i] = z.AtVec(r) - yPred.At(r, c)
 		}
-		// turn the innovation vector i.e. measurement error into probability
-		// Note: this isn't actually probability but that's ok because we normalize weights
-		diff := math.Exp(b.errPDF.LogProb(b.inn))
-		b.w[c] = b.w[c] * diff
+		lw[c] = b.lw[c] + errPDF.LogProb(inn)
 	}
 
-	// normalize the particle weights so they express probability
-	floats.Scale(1/floats.Sum(b.w), b.w)
+	// normalize the particle weights so they express probability:
+	// log of the weights sum is calculated using log-sum-exp trick
+	logSum := floats.LogSumExp(lw)
+	if math.IsInf(logSum, 0) || math.IsNaN(logSum) {
+		return nil, &DegeneracyError{LogSum: logSum}
+	}
 
-	rows, _ := b.x.Dims()
-	wavg := 0.0
-	// FIXME: probably no need to allocate a new vector here - just modify b.x
-	xEst := mat.NewVecDense(rows, nil)
-	// update (correct) particles estimates to weighted average
-	for r := 0; r < rows; r++ {
-		for c := range b.w {
-			wavg += b.w[c] * b.x.At(r, c)
-		}
-		xEst.SetVec(r, wavg)
-		wavg = 0.0
+	for c := range lw {
+		b.lw[c] = lw[c] - logSum
+		b.w[c] = math.Exp(b.lw[c])
 	}
 
 	// update filter particle outputs
 	b.y.Copy(yPred)
 
-	return estimate.NewBase(xEst)
+	// estimate is calculated before resampling which adds random perturbations to particles
+	est, err := b.estimate()
+	if err != nil {
+		return nil, err
+	}
+
+	b.ess = particle.ESS(b.w)
+	if b.ess < b.ESSThreshold*float64(len(b.w)) {
+		if err := b.Resample(b.Alpha); err != nil {
+			return nil, fmt.Errorf("failed to resample filter particles: %v", err)
+		}
+	}
+
+	return est, nil
+}
+
+// ESS returns effective sample size of filter particles calculated in the last Update before any resampling.
+// Effective sample size 1/sum(w^2) ranges from 1 for degenerate weights to particle count for equal weights.
+func (b *BF) ESS() float64 {
+	return b.ess
+}
+
+// forEach calls fn for every particle column in [0,n) using Workers goroutines.
+// Every goroutine processes a contiguous block of columns and stops at the first error it encounters.
+// It returns the error of the lowest block which failed, so the returned error doesn't depend on scheduling.
+func (b *BF) forEach(n int, fn func(c int) error) error {
+	workers := min(b.Workers, n)
+	if workers < 2 {
+		for c := 0; c < n; c++ {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, workers)
+	block := (n + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for c := w * block; c < min((w+1)*block, n); c++ {
+				if err := fn(c); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// estimate returns the point estimate selected by Point along with the weighted covariance of filter particles.
+// It returns error if invalid point estimate is selected.
+func (b *BF) estimate() (filter.Estimate, error) {
+	xMean := particle.Mean(b.x, b.w)
+
+	var xEst *mat.VecDense
+	switch b.Point {
+	case Mean:
+		xEst = xMean
+	case MAP:
+		xEst = mat.VecDenseCopyOf(b.x.ColView(floats.MaxIdx(b.w)))
+	case Median:
+		xEst = b.median()
+	default:
+		return nil, fmt.Errorf("invalid point estimate: %v", b.Point)
+	}
+
+	return estimate.NewBaseWithCov(xEst, particle.Cov(b.x, xMean, b.w))
+}
+
+// median calculates component-wise weighted median of filter particles and returns it.
+func (b *BF) median() *mat.VecDense {
+	rows, cols := b.x.Dims()
+
+	xMed := mat.NewVecDense(rows, nil)
+	vals := make([]float64, cols)
+	inds := make([]int, cols)
+	for r := 0; r < rows; r++ {
+		mat.Row(vals, r, b.x)
+		floats.Argsort(vals, inds)
+		// the smallest value whose cumulative weight reaches one half
+		sum := 0.0
+		for i, c := range inds {
+			sum += b.w[c]
+			if sum >= 0.5 || i == cols-1 {
+				xMed.SetVec(r, vals[i])
+				break
+			}
+		}
+	}
+
+	return xMed
 }
 
 // Run runs one step of Bootstrap Filter for given state x, input u and measurement z.
@@ -212,13 +445,13 @@ func (b *BF) Run(x, u, z mat.Vector) (filter.Estimate, error) {
 }
 
 // Resample allows to resample filter particles with regularization parameter alpha.
-// It generates new filter particles and replaces the existing ones with them.
+// It generates new filter particles using the BF resampling scheme and replaces the existing ones with them.
 // If invalid (non-positive) alpha is provided we use optimal alpha for gaussian kernel.
 // It returns error if it fails to generate new filter particles.
 func (b *BF) Resample(alpha float64) error {
 	// randomly pick new particles based on their weights
-	// rand.RouletteDrawN returns a slice of column indices to b.x
-	indices, err := rand.RouletteDrawN(b.w, len(b.w))
+	// DrawN returns a slice of column indices to b.x
+	indices, err := b.Resampling.DrawN(b.w, len(b.w), b.src)
 	if err != nil {
 		return fmt.Errorf("failed to sample filter particles: %v", err)
 	}
@@ -237,6 +470,7 @@ func (b *BF) Resample(alpha float64) error {
 	// weights will have the same probability: 1/len(b.w): they must sum up to 1
 	for i := 0; i < len(b.w); i++ {
 		b.w[i] = 1 / float64(len(b.w))
+		b.lw[i] = -math.Log(float64(len(b.w)))
 	}
 
 	// We need to calculate covariance matrix of particles
@@ -246,7 +480,7 @@ func (b *BF) Resample(alpha float64) error {
 	}
 
 	// randomly draw values with given particle covariance
-	m, err := rand.WithCovN(cov, cols)
+	m, err := rand.WithCovNSource(cov, cols, b.src)
 	if err != nil {
 		return fmt.Errorf("failed to draw random particle pertrubations: %v", err)
 	}
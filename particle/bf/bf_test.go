@@ -1,13 +1,19 @@
 package bf
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"os"
 	"testing"
 
 	filter "github.com/milosgajdos/go-estimate"
 	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/particle"
 	"github.com/milosgajdos/go-estimate/sim"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/mat"
 	"gonum.org/v1/gonum/stat/distmv"
 )
@@ -151,6 +157,174 @@ func TestUpdate(t *testing.T) {
 	est, err := f.Update(x, u, z)
 	assert.NotNil(est)
 	assert.NoError(err)
+
+	// missing measurement
+	for _, _z := range []mat.Vector{nil, mat.NewVecDense(1, []float64{math.NaN()})} {
+		xm := mat.VecDenseCopyOf(x)
+		est, err = f.Update(xm, u, _z)
+		assert.NotNil(est)
+		assert.NoError(err)
+	}
+}
+
+func TestUpdatePartial(t *testing.T) {
+	assert := assert.New(t)
+
+	// model with two outputs and its reduction to the first output
+	C := mat.NewDense(2, 2, []float64{1.0, 0.0, 1.0, 1.0})
+	D := mat.NewDense(2, 1, []float64{0.0, 0.5})
+	m := &sim.BaseModel{A: okModel.A, B: okModel.B, C: C, D: D}
+	rm := &sim.BaseModel{A: okModel.A, B: okModel.B, C: mat.DenseCopyOf(C.Slice(0, 1, 0, 2)), D: mat.DenseCopyOf(D.Slice(0, 1, 0, 1))}
+
+	pdf, _ := distmv.NewNormal([]float64{0, 0}, mat.NewSymDense(2, []float64{0.25, 0, 0, 0.5}), nil)
+	_z := mat.NewVecDense(2, []float64{-1.5, math.NaN()})
+
+	f, err := New(m, ic, q, nil, p, pdf)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	fr, err := New(rm, ic, q, nil, p, errPDF)
+	assert.NotNil(fr)
+	assert.NoError(err)
+	fr.x.Copy(f.x)
+
+	// partial measurement is equivalent to the measurement of the reduced model
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+	est, err := f.Update(x, u, _z)
+	assert.NotNil(est)
+	assert.NoError(err)
+	estR, err := fr.Update(x, u, z)
+	assert.NotNil(estR)
+	assert.NoError(err)
+
+	assert.True(mat.EqualApprox(estR.Val(), est.Val(), 1e-12))
+	assert.True(mat.EqualApprox(estR.Cov(), est.Cov(), 1e-12))
+	assert.True(mat.EqualApprox(fr.Weights(), f.Weights(), 1e-12))
+
+	// partial measurement requires Gaussian PDF
+	f, err = New(m, ic, q, nil, p, &constPDF{logProb: 0.0})
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	est, err = f.Update(x, u, _z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+// constPDF returns the same log probability for any error
+type constPDF struct {
+	logProb float64
+}
+
+func (c *constPDF) LogProb(x []float64) float64 {
+	return c.logProb
+}
+
+func TestUpdateLogWeights(t *testing.T) {
+	assert := assert.New(t)
+
+	// very informative measurement: all weights underflow outside of log space
+	pdf, _ := distmv.NewNormal([]float64{0}, mat.NewSymDense(1, []float64{1e-6}), nil)
+	f, err := New(okModel, ic, q, r, p, pdf)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+	est, err := f.Update(x, u, mat.NewVecDense(1, []float64{100.0}))
+	assert.NotNil(est)
+	assert.NoError(err)
+	assert.False(floats.HasNaN(mat.Col(nil, 0, est.Val())))
+	assert.InDelta(1.0, floats.Sum(f.w), 1e-12)
+	assert.GreaterOrEqual(f.ESS(), 1.0)
+
+	// degenerate weights
+	for _, logProb := range []float64{math.Inf(-1), math.NaN()} {
+		f, err = New(okModel, ic, q, r, p, &constPDF{logProb: logProb})
+		assert.NotNil(f)
+		assert.NoError(err)
+
+		weights := f.Weights()
+		est, err = f.Update(x, u, z)
+		assert.Nil(est)
+		assert.Error(err)
+
+		var degErr *DegeneracyError
+		assert.True(errors.As(err, &degErr))
+		assert.True(mat.Equal(weights, f.Weights()))
+	}
+}
+
+func TestPointEstimates(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, 4, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	f.x = mat.NewDense(2, 4, []float64{
+		1.0, 2.0, 3.0, 10.0,
+		4.0, 3.0, 2.0, 1.0,
+	})
+	f.w = []float64{0.1, 0.2, 0.3, 0.4}
+
+	// weighted covariance of particles around their mean
+	xMean := mat.NewVecDense(2, []float64{5.4, 2.0})
+	expCov := mat.NewSymDense(2, nil)
+	for c := range f.w {
+		d := &mat.VecDense{}
+		d.SubVec(f.x.ColView(c), xMean)
+		expCov.SymRankOne(expCov, f.w[c], d)
+	}
+
+	exp := map[PointEstimate]*mat.VecDense{
+		Mean:   xMean,
+		MAP:    mat.NewVecDense(2, []float64{10.0, 1.0}),
+		Median: mat.NewVecDense(2, []float64{3.0, 2.0}),
+	}
+
+	for pe, xExp := range exp {
+		f.Point = pe
+		est, err := f.estimate()
+		assert.NotNil(est, pe.String())
+		assert.NoError(err, pe.String())
+		assert.True(mat.EqualApprox(xExp, est.Val(), 1e-12), pe.String())
+		assert.True(mat.EqualApprox(expCov, est.Cov(), 1e-12), pe.String())
+	}
+
+	// missing measurement returns the selected point estimate
+	f.Point = MAP
+	est, err := f.Update(nil, u, nil)
+	assert.NotNil(est)
+	assert.NoError(err)
+	assert.True(mat.Equal(exp[MAP], est.Val()))
+
+	// invalid point estimate
+	f.Point = PointEstimate(10)
+	est, err = f.Update(nil, u, nil)
+	assert.Nil(est)
+	assert.Error(err)
+	assert.Equal("PointEstimate(10)", f.Point.String())
+}
+
+func TestEstimateCov(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+	pred, err := f.Predict(x, u)
+	assert.NotNil(pred)
+	assert.NoError(err)
+	assert.Greater(pred.Cov().At(0, 0), 0.0)
+	assert.Greater(pred.Cov().At(1, 1), 0.0)
+
+	est, err := f.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+	assert.Greater(est.Cov().At(0, 0), 0.0)
+	assert.Greater(est.Cov().At(1, 1), 0.0)
 }
 
 func TestRun(t *testing.T) {
@@ -201,6 +375,75 @@ func TestResample(t *testing.T) {
 	assert.NoError(err)
 }
 
+func TestResampling(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	for _, rs := range []Resampling{Multinomial, Systematic, Stratified, Residual} {
+		f.Resampling = rs
+		f.w[0], f.w[1] = f.w[0]+f.w[1], 0.0
+
+		err = f.Resample(0.0)
+		assert.NoError(err, rs.String())
+		for i := range f.w {
+			assert.InDelta(1/float64(p), f.w[i], 1e-12)
+		}
+	}
+
+	// invalid resampling scheme
+	f.Resampling = Resampling(10)
+	err = f.Resample(0.0)
+	assert.Error(err)
+	assert.Equal("Resampling(10)", f.Resampling.String())
+}
+
+func TestESS(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := New(okModel, ic, q, r, p, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+	assert.Equal(float64(p), f.ESS())
+
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+
+	// no automatic resampling: weights are not equal after update
+	est, err := f.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+	assert.InDelta(particle.ESS(f.w), f.ESS(), 1e-12)
+	assert.Less(f.ESS(), float64(p))
+
+	// automatic resampling resets the weights
+	f.ESSThreshold = 1.0
+	f.Resampling = Systematic
+	est, err = f.Update(x, u, z)
+	assert.NotNil(est)
+	assert.NoError(err)
+	assert.Less(f.ESS(), float64(p))
+	for i := range f.w {
+		assert.InDelta(1/float64(p), f.w[i], 1e-12)
+	}
+
+	// automatic resampling errors are returned
+	f.Resampling = Resampling(10)
+	est, err = f.Update(x, u, z)
+	assert.Nil(est)
+	assert.Error(err)
+	f.Resampling = Systematic
+
+	// invalid ESS threshold
+	for _, threshold := range []float64{-0.5, 1.5, math.NaN()} {
+		f.ESSThreshold = threshold
+		est, err = f.Update(x, u, z)
+		assert.Nil(est)
+		assert.Error(err)
+	}
+}
+
 func TestParticles(t *testing.T) {
 	assert := assert.New(t)
 
@@ -238,3 +481,147 @@ func TestAlphaGauss(t *testing.T) {
 	alpha := AlphaGauss(1, 2)
 	assert.True(alpha > 0.0)
 }
+
+// seededNoise returns Gaussian noise with the mean and covariance of n seeded with seed
+func seededNoise(n filter.Noise, seed uint64) filter.Noise {
+	g, _ := noise.NewGaussianWithSource(n.Mean(), n.Cov(), rand.NewSource(seed))
+
+	return g
+}
+
+// slowModel is an expensive model
+type slowModel struct {
+	*sim.BaseModel
+	n int
+}
+
+func (m *slowModel) Propagate(x, u, wd mat.Vector) (mat.Vector, error) {
+	s := 0.0
+	for i := 0; i < m.n; i++ {
+		s += math.Sin(float64(i))
+	}
+	if math.IsNaN(s) {
+		return nil, fmt.Errorf("invalid state")
+	}
+
+	return m.BaseModel.Propagate(x, u, wd)
+}
+
+func (m *slowModel) Observe(x, u, wn mat.Vector) (mat.Vector, error) {
+	s := 0.0
+	for i := 0; i < m.n; i++ {
+		s += math.Cos(float64(i))
+	}
+	if math.IsNaN(s) {
+		return nil, fmt.Errorf("invalid state")
+	}
+
+	return m.BaseModel.Observe(x, u, wn)
+}
+
+func TestWorkers(t *testing.T) {
+	assert := assert.New(t)
+
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+
+	// results don't depend on the number of workers
+	for _, workers := range []int{2, 3, 8, 200} {
+		s, err := NewWithSource(okModel, ic, seededNoise(q, 1), seededNoise(r, 2), 101, errPDF, rand.NewSource(3))
+		assert.NoError(err)
+		f, err := NewWithSource(okModel, ic, seededNoise(q, 1), seededNoise(r, 2), 101, errPDF, rand.NewSource(3))
+		assert.NoError(err)
+		f.Workers = workers
+
+		for i := 0; i < 3; i++ {
+			estS, err := s.Run(x, u, z)
+			assert.NoError(err)
+			est, err := f.Run(x, u, z)
+			assert.NoError(err)
+			assert.True(mat.Equal(estS.Val(), est.Val()))
+		}
+		assert.True(mat.Equal(s.Particles(), f.Particles()))
+		assert.True(mat.Equal(s.Weights(), f.Weights()))
+	}
+
+	// errors are returned from workers
+	f, err := New(okModel, ic, q, r, 101, errPDF)
+	assert.NotNil(f)
+	assert.NoError(err)
+	f.Workers = 4
+
+	_u := mat.NewVecDense(3, nil)
+	est, err := f.Predict(x, _u)
+	assert.Nil(est)
+	assert.Error(err)
+
+	est, err = f.Update(x, _u, z)
+	assert.Nil(est)
+	assert.Error(err)
+}
+
+func TestSource(t *testing.T) {
+	assert := assert.New(t)
+
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+
+	// filters seeded with the same seed produce the same particles even when they are resampled
+	for _, rs := range []Resampling{Multinomial, Systematic, Stratified, Residual} {
+		s, err := NewWithSource(okModel, ic, seededNoise(q, 1), seededNoise(r, 2), 101, errPDF, rand.NewSource(3))
+		assert.NoError(err)
+		f, err := NewWithSource(okModel, ic, seededNoise(q, 1), seededNoise(r, 2), 101, errPDF, rand.NewSource(3))
+		assert.NoError(err)
+		assert.True(mat.Equal(s.Particles(), f.Particles()))
+
+		for _, b := range []*BF{s, f} {
+			b.Resampling = rs
+			b.ESSThreshold = 1.0
+		}
+
+		for i := 0; i < 3; i++ {
+			estS, err := s.Run(x, u, z)
+			assert.NoError(err)
+			est, err := f.Run(x, u, z)
+			assert.NoError(err)
+			assert.True(mat.Equal(estS.Val(), est.Val()), rs)
+			assert.True(mat.Equal(estS.Cov(), est.Cov()), rs)
+		}
+		assert.True(mat.Equal(s.Particles(), f.Particles()), rs)
+		assert.True(mat.Equal(s.Weights(), f.Weights()), rs)
+
+		// resampling draws from the source
+		particles := s.Particles()
+		assert.NoError(s.Resample(0))
+		assert.NoError(f.Resample(0))
+		assert.False(mat.Equal(particles, s.Particles()), rs)
+		assert.True(mat.Equal(s.Particles(), f.Particles()), rs)
+	}
+
+	// different seeds generate different particles
+	s, err := NewWithSource(okModel, ic, q, r, 101, errPDF, rand.NewSource(3))
+	assert.NoError(err)
+	f, err := NewWithSource(okModel, ic, q, r, 101, errPDF, rand.NewSource(4))
+	assert.NoError(err)
+	assert.False(mat.Equal(s.Particles(), f.Particles()))
+}
+
+func BenchmarkBFRun(b *testing.B) {
+	m := &slowModel{BaseModel: okModel, n: 1000}
+	x := mat.NewVecDense(2, []float64{1.0, 1.0})
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			f, err := New(m, ic, q, r, 10000, errPDF)
+			if err != nil {
+				b.Fatalf("failed to create BF: %v", err)
+			}
+			f.Workers = workers
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := f.Run(x, u, z); err != nil {
+					b.Fatalf("failed to run BF: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,16 @@
 package particle
 
 import (
+	"fmt"
+	"math"
+
 	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/rand"
+	xrand "golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distmv"
 )
 
 // Particle is Particle Filter
@@ -12,3 +20,128 @@ type Particle interface {
 	// Weights returns particle weights
 	Weights() mat.Vector
 }
+
+// ValidateNoise validates model m and its state and output noise q and r and returns the noise.
+// Nil noise is replaced by zero noise of the matching dimension.
+// It returns error if either the model dimensions are not positive or the noise dimensions don't match the model.
+func ValidateNoise(m filter.Model, q, r filter.Noise) (filter.Noise, filter.Noise, error) {
+	// size of input and output vectors
+	nx, _, ny, _ := m.SystemDims()
+	if nx <= 0 || ny <= 0 {
+		return nil, nil, fmt.Errorf("invalid model dimensions: [%d x %d]", nx, ny)
+	}
+
+	if q != nil {
+		if _, err := filter.StateNoiseCov(m, q.Cov()); err != nil {
+			return nil, nil, fmt.Errorf("invalid state noise: %v", err)
+		}
+	} else {
+		q, _ = noise.NewZero(nx)
+	}
+
+	if r != nil {
+		if r.Cov().SymmetricDim() != ny {
+			return nil, nil, fmt.Errorf("invalid output noise dimension: %d", r.Cov().SymmetricDim())
+		}
+	} else {
+		r, _ = noise.NewZero(ny)
+	}
+
+	return q, r, nil
+}
+
+// Draw draws p particles from Normal distribution with the mean and covariance of initial condition ic
+// using source of randomness src and returns them stored in matrix columns. If src is nil, the global source is used.
+// It returns error if non-positive number of particles is given or if the particles fail to be generated.
+func Draw(ic filter.InitCond, p int, src xrand.Source) (*mat.Dense, error) {
+	// must have at least one particle; can't be negative
+	if p <= 0 {
+		return nil, fmt.Errorf("invalid particle count: %d", p)
+	}
+
+	// draw particles from distribution with covariance InitCond.Cov()
+	x, err := rand.WithCovNSource(ic.Cov(), p, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate filter particles: %v", err)
+	}
+
+	rows, cols := x.Dims()
+	// center particles around initial state condition init.State()
+	for c := 0; c < cols; c++ {
+		for r := 0; r < rows; r++ {
+			x.Set(r, c, x.At(r, c)+ic.State().AtVec(r))
+		}
+	}
+
+	return x, nil
+}
+
+// Weights returns p equal particle weights and their logarithms.
+// Particle weights sum up to 1 to represent probability.
+func Weights(p int) ([]float64, []float64) {
+	w := make([]float64, p)
+	lw := make([]float64, p)
+	for i := range w {
+		w[i] = 1 / float64(p)
+		lw[i] = -math.Log(float64(p))
+	}
+
+	return w, lw
+}
+
+// Mean calculates weighted average of particles stored in columns of x with weights w and returns it.
+func Mean(x *mat.Dense, w []float64) *mat.VecDense {
+	rows, _ := x.Dims()
+
+	xMean := mat.NewVecDense(rows, nil)
+	for c := range w {
+		xMean.AddScaledVec(xMean, w[c], x.ColView(c))
+	}
+
+	return xMean
+}
+
+// Cov calculates weighted covariance of particles stored in columns of x around their mean xMean
+// with weights w and returns it.
+func Cov(x *mat.Dense, xMean mat.Vector, w []float64) *mat.SymDense {
+	rows, cols := x.Dims()
+
+	// weighted deviations of particles from their mean
+	dev := mat.NewDense(rows, cols, nil)
+	for c := range w {
+		col := dev.ColView(c).(*mat.VecDense)
+		col.SubVec(x.ColView(c), xMean)
+		col.ScaleVec(math.Sqrt(w[c]), col)
+	}
+
+	cov := mat.NewSymDense(rows, nil)
+	cov.SymOuterK(1.0, dev)
+
+	return cov
+}
+
+// MarginalPDF returns PDF of the output error elements idx given PDF pdf of all ny output error elements.
+// If all the elements are observed pdf is returned, otherwise pdf must be *distmv.Normal.
+// It returns error if pdf is not *distmv.Normal or if it fails to be marginalized.
+func MarginalPDF(pdf distmv.LogProber, idx []int, ny int) (distmv.LogProber, error) {
+	if len(idx) == ny {
+		return pdf, nil
+	}
+
+	normal, ok := pdf.(*distmv.Normal)
+	if !ok {
+		return nil, fmt.Errorf("partial measurement requires Gaussian output error PDF")
+	}
+
+	marginal, ok := normal.MarginalNormal(idx, nil)
+	if !ok {
+		return nil, fmt.Errorf("failed to marginalize output error PDF")
+	}
+
+	return marginal, nil
+}
+
+// ESS calculates effective sample size 1/sum(w^2) of normalized particle weights w and returns it.
+func ESS(w []float64) float64 {
+	return 1 / floats.Dot(w, w)
+}
@@ -0,0 +1,128 @@
+package particle
+
+import (
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distmv"
+)
+
+type invalidModel struct {
+	filter.Model
+}
+
+func (m *invalidModel) SystemDims() (nx, nu, ny, nz int) {
+	return -10, 0, 8, 0
+}
+
+// constPDF returns the same log probability for any error
+type constPDF struct{}
+
+func (c *constPDF) LogProb(x []float64) float64 {
+	return 0.0
+}
+
+func TestValidateNoise(t *testing.T) {
+	assert := assert.New(t)
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	C := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	m := &sim.BaseModel{A: A, C: C}
+
+	q, _ := noise.NewGaussian([]float64{0, 0}, mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25}))
+	r, _ := noise.NewGaussian([]float64{0}, mat.NewSymDense(1, []float64{0.25}))
+
+	_q, _r, err := ValidateNoise(m, q, r)
+	assert.NoError(err)
+	assert.Equal(q, _q)
+	assert.Equal(r, _r)
+
+	// nil noise is replaced by zero noise
+	_q, _r, err = ValidateNoise(m, nil, nil)
+	assert.NoError(err)
+	assert.Equal(2, _q.Cov().SymmetricDim())
+	assert.Equal(1, _r.Cov().SymmetricDim())
+
+	// invalid model
+	_, _, err = ValidateNoise(&invalidModel{m}, q, r)
+	assert.Error(err)
+
+	// invalid state and output noise
+	_, _, err = ValidateNoise(m, r, r)
+	assert.Error(err)
+	_, _, err = ValidateNoise(m, q, q)
+	assert.Error(err)
+}
+
+func TestDraw(t *testing.T) {
+	assert := assert.New(t)
+
+	ic := sim.NewInitCond(mat.NewVecDense(2, []float64{1.0, 3.0}), mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25}))
+
+	x, err := Draw(ic, 10, rand.NewSource(1))
+	assert.NoError(err)
+	rows, cols := x.Dims()
+	assert.Equal(2, rows)
+	assert.Equal(10, cols)
+
+	// the same seed draws the same particles
+	_x, err := Draw(ic, 10, rand.NewSource(1))
+	assert.NoError(err)
+	assert.True(mat.Equal(x, _x))
+
+	// particles are centered around initial state
+	x, err = Draw(ic, 10000, rand.NewSource(1))
+	assert.NoError(err)
+	w, _ := Weights(10000)
+	assert.True(mat.EqualApprox(ic.State(), Mean(x, w), 0.05))
+
+	// invalid particle count
+	x, err = Draw(ic, 0, nil)
+	assert.Nil(x)
+	assert.Error(err)
+}
+
+func TestMoments(t *testing.T) {
+	assert := assert.New(t)
+
+	w, lw := Weights(4)
+	assert.Equal([]float64{0.25, 0.25, 0.25, 0.25}, w)
+	assert.InDelta(0.0, lw[0]-lw[3], 1e-12)
+	assert.InDelta(4.0, ESS(w), 1e-12)
+	assert.InDelta(1.0, ESS([]float64{1.0, 0.0, 0.0}), 1e-12)
+
+	x := mat.NewDense(2, 2, []float64{1.0, 3.0, 2.0, 2.0})
+	w = []float64{0.5, 0.5}
+
+	xMean := Mean(x, w)
+	assert.True(mat.EqualApprox(mat.NewVecDense(2, []float64{2.0, 2.0}), xMean, 1e-12))
+
+	cov := Cov(x, xMean, w)
+	assert.True(mat.EqualApprox(mat.NewSymDense(2, []float64{1.0, 0.0, 0.0, 0.0}), cov, 1e-12))
+}
+
+func TestMarginalPDF(t *testing.T) {
+	assert := assert.New(t)
+
+	pdf, _ := distmv.NewNormal([]float64{0, 0}, mat.NewSymDense(2, []float64{0.25, 0, 0, 0.5}), nil)
+
+	// all elements observed
+	m, err := MarginalPDF(pdf, []int{0, 1}, 2)
+	assert.NoError(err)
+	assert.Equal(pdf, m)
+
+	m, err = MarginalPDF(pdf, []int{1}, 2)
+	assert.NoError(err)
+	exp, _ := distmv.NewNormal([]float64{0}, mat.NewSymDense(1, []float64{0.5}), nil)
+	assert.InDelta(exp.LogProb([]float64{0.3}), m.LogProb([]float64{0.3}), 1e-12)
+
+	// partial measurement requires Gaussian PDF
+	m, err = MarginalPDF(&constPDF{}, []int{1}, 2)
+	assert.Nil(m)
+	assert.Error(err)
+}
@@ -6,15 +6,44 @@ import (
 	rnd "math/rand"
 	"sort"
 
+	xrand "golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/mat"
 	"gonum.org/v1/gonum/stat/distuv"
 )
 
+// normFloat64 returns function which draws standard normal samples from src.
+// If src is nil, the global source is used.
+func normFloat64(src xrand.Source) func() float64 {
+	if src == nil {
+		return rnd.NormFloat64
+	}
+
+	return xrand.New(src).NormFloat64
+}
+
+// unitUniform returns function which draws uniform samples in [0,1) from src.
+// If src is nil, the global source is used.
+func unitUniform(src xrand.Source) func() float64 {
+	if src == nil {
+		return distuv.UnitUniform.Rand
+	}
+
+	return xrand.New(src).Float64
+}
+
 // WithCovN draws n random samples from a zero-mean Normal (aka Gaussian) distribution with covariance cov.
 // It returns matrix which contains the randomly generated samples stored in its columns.
 // It fails with error if n is non-positive and/or smaller than 1 or if SVD factorization of cov fails.
 func WithCovN(cov mat.Symmetric, n int) (*mat.Dense, error) {
+	return WithCovNSource(cov, n, nil)
+}
+
+// WithCovNSource draws n random samples from a zero-mean Normal (aka Gaussian) distribution with covariance cov
+// using source of randomness src. If src is nil, the global source is used.
+// It returns matrix which contains the randomly generated samples stored in its columns.
+// It fails with error if n is non-positive and/or smaller than 1 or if SVD factorization of cov fails.
+func WithCovNSource(cov mat.Symmetric, n int, src xrand.Source) (*mat.Dense, error) {
 	if n <= 0 {
 		return nil, fmt.Errorf("Invalid number of samples requested: %d", n)
 	}
@@ -35,10 +64,11 @@ func WithCovN(cov mat.Symmetric, n int) (*mat.Dense, error) {
 	diag := mat.NewDiagDense(len(vals), vals)
 	U.Mul(U, diag)
 
+	norm := normFloat64(src)
 	rows, _ := cov.Dims()
 	data := make([]float64, rows*n)
 	for i := range data {
-		data[i] = rnd.NormFloat64()
+		data[i] = norm()
 	}
 	samples := mat.NewDense(rows, n, data)
 	samples.Mul(U, samples)
@@ -53,6 +83,12 @@ func WithCovN(cov mat.Symmetric, n int) (*mat.Dense, error) {
 // It returns a slice of n indices into the vector p.
 // It fails with error if p is empty or nil.
 func RouletteDrawN(p []float64, n int) ([]int, error) {
+	return RouletteDrawNSource(p, n, nil)
+}
+
+// RouletteDrawNSource works like RouletteDrawN but it draws the numbers using source of randomness src.
+// If src is nil, the global source is used.
+func RouletteDrawNSource(p []float64, n int, src xrand.Source) ([]int, error) {
 	if len(p) == 0 {
 		return nil, fmt.Errorf("Invalid probability weights: %v", p)
 	}
@@ -66,13 +102,113 @@ func RouletteDrawN(p []float64, n int) ([]int, error) {
 	// 1. Generate a uniformly-random value x in the range [0,1)
 	// 2. Using a binary search, find the index of the smallest element in cdf larger than x
 	var val float64
+	uniform := unitUniform(src)
 	indices := make([]int, n)
 	for i := range indices {
 		// multiply the sample with the largest CDF value; easier than normalizing to [0,1)
-		val = distuv.UnitUniform.Rand() * cdf[len(cdf)-1]
+		val = uniform() * cdf[len(cdf)-1]
 		// Search returns the smallest index i such that cdf[i] > val
 		indices[i] = sort.Search(len(cdf), func(i int) bool { return cdf[i] > val })
 	}
 
 	return indices, nil
 }
+
+// SystematicDrawN draws n numbers from a probability mass function (PMF) defined by weights in p
+// using systematic resampling: a single uniformly-random offset u in [0,1/n) selects the indices
+// at positions u + i/n of the normalized discrete CDF.
+// It returns a slice of n indices into the vector p.
+// It fails with error if p is empty or nil.
+func SystematicDrawN(p []float64, n int) ([]int, error) {
+	return SystematicDrawNSource(p, n, nil)
+}
+
+// SystematicDrawNSource works like SystematicDrawN but it draws the offset using source of randomness src.
+// If src is nil, the global source is used.
+func SystematicDrawNSource(p []float64, n int, src xrand.Source) ([]int, error) {
+	u := unitUniform(src)()
+
+	return cdfDrawN(p, n, func() float64 { return u })
+}
+
+// StratifiedDrawN draws n numbers from a probability mass function (PMF) defined by weights in p
+// using stratified resampling: [0,1) is split into n strata of equal size and an index is drawn
+// from every stratum using its own uniformly-random offset.
+// It returns a slice of n indices into the vector p.
+// It fails with error if p is empty or nil.
+func StratifiedDrawN(p []float64, n int) ([]int, error) {
+	return StratifiedDrawNSource(p, n, nil)
+}
+
+// StratifiedDrawNSource works like StratifiedDrawN but it draws the offsets using source of randomness src.
+// If src is nil, the global source is used.
+func StratifiedDrawNSource(p []float64, n int, src xrand.Source) ([]int, error) {
+	return cdfDrawN(p, n, unitUniform(src))
+}
+
+// ResidualDrawN draws n numbers from a probability mass function (PMF) defined by weights in p
+// using residual resampling: every index i is first selected floor(n*p[i]) times deterministically
+// and the remaining indices are drawn from the residual weights using RouletteDrawN.
+// It returns a slice of n indices into the vector p.
+// It fails with error if p is empty or nil.
+func ResidualDrawN(p []float64, n int) ([]int, error) {
+	return ResidualDrawNSource(p, n, nil)
+}
+
+// ResidualDrawNSource works like ResidualDrawN but it draws the remaining indices using source of randomness src.
+// If src is nil, the global source is used.
+func ResidualDrawNSource(p []float64, n int, src xrand.Source) ([]int, error) {
+	if len(p) == 0 {
+		return nil, fmt.Errorf("Invalid probability weights: %v", p)
+	}
+
+	sum := floats.Sum(p)
+	residual := make([]float64, len(p))
+	indices := make([]int, 0, n)
+
+	for i := range p {
+		np := float64(n) * p[i] / sum
+		copies := int(math.Floor(np))
+		for c := 0; c < copies && len(indices) < n; c++ {
+			indices = append(indices, i)
+		}
+		residual[i] = np - float64(copies)
+	}
+
+	if len(indices) == n {
+		return indices, nil
+	}
+
+	rest, err := RouletteDrawNSource(residual, n-len(indices), src)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(indices, rest...), nil
+}
+
+// cdfDrawN draws n numbers from a probability mass function (PMF) defined by weights in p by selecting
+// the indices at positions (i + offset())/n of the normalized discrete CDF, where offset() is in [0,1).
+// It fails with error if p is empty or nil.
+func cdfDrawN(p []float64, n int, offset func() float64) ([]int, error) {
+	if len(p) == 0 {
+		return nil, fmt.Errorf("Invalid probability weights: %v", p)
+	}
+
+	// Initialization: create the discrete CDF
+	cdf := make([]float64, len(p))
+	floats.CumSum(cdf, p)
+
+	// positions are increasing so the CDF is walked through only once
+	indices := make([]int, n)
+	j := 0
+	for i := range indices {
+		pos := (float64(i) + offset()) / float64(n) * cdf[len(cdf)-1]
+		for j < len(cdf)-1 && cdf[j] <= pos {
+			j++
+		}
+		indices[i] = j
+	}
+
+	return indices, nil
+}
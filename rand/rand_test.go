@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	xrand "golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -51,3 +52,84 @@ func TestRouletteDrawN(t *testing.T) {
 	assert.NotNil(indices)
 	assert.Equal(n, len(indices))
 }
+
+func TestDrawN(t *testing.T) {
+	assert := assert.New(t)
+
+	draws := map[string]func([]float64, int) ([]int, error){
+		"systematic": SystematicDrawN,
+		"stratified": StratifiedDrawN,
+		"residual":   ResidualDrawN,
+	}
+
+	for name, draw := range draws {
+		// p can't be nil or empty
+		indices, err := draw(nil, 10)
+		assert.Error(err, name)
+		assert.Nil(indices, name)
+
+		p := []float64{0.1, 0.7, 0.3, 0.4}
+		n := 10
+		indices, err = draw(p, n)
+		assert.NoError(err, name)
+		assert.Equal(n, len(indices), name)
+		for _, i := range indices {
+			assert.True(i >= 0 && i < len(p), name)
+		}
+
+		// indices with zero weight are never drawn
+		p = []float64{0.0, 0.5, 0.0, 0.5}
+		indices, err = draw(p, 100)
+		assert.NoError(err, name)
+		counts := make([]int, len(p))
+		for _, i := range indices {
+			counts[i]++
+		}
+		assert.Equal(0, counts[0], name)
+		assert.Equal(0, counts[2], name)
+	}
+
+	// systematic and residual draws select every index at least floor(n*p) times
+	p := []float64{0.125, 0.5, 0.375}
+	for _, draw := range []func([]float64, int) ([]int, error){SystematicDrawN, ResidualDrawN} {
+		indices, err := draw(p, 8)
+		assert.NoError(err)
+		counts := make([]int, len(p))
+		for _, i := range indices {
+			counts[i]++
+		}
+		assert.Equal([]int{1, 4, 3}, counts)
+	}
+}
+
+func TestSource(t *testing.T) {
+	assert := assert.New(t)
+
+	// the same seed draws the same samples
+	cov := mat.NewSymDense(2, []float64{1.0, 0.5, 0.5, 2.0})
+	a, err := WithCovNSource(cov, 10, xrand.NewSource(1))
+	assert.NoError(err)
+	b, err := WithCovNSource(cov, 10, xrand.NewSource(1))
+	assert.NoError(err)
+	assert.True(mat.Equal(a, b))
+
+	c, err := WithCovNSource(cov, 10, xrand.NewSource(2))
+	assert.NoError(err)
+	assert.False(mat.Equal(a, c))
+
+	p := []float64{0.1, 0.7, 0.3, 0.4}
+	draws := map[string]func([]float64, int, xrand.Source) ([]int, error){
+		"roulette":   RouletteDrawNSource,
+		"systematic": SystematicDrawNSource,
+		"stratified": StratifiedDrawNSource,
+		"residual":   ResidualDrawNSource,
+	}
+
+	for name, draw := range draws {
+		a, err := draw(p, 50, xrand.NewSource(1))
+		assert.NoError(err, name)
+		b, err := draw(p, 50, xrand.NewSource(1))
+		assert.NoError(err, name)
+		assert.Equal(a, b, name)
+	}
+}
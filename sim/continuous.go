@@ -0,0 +1,152 @@
+package sim
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// ContinuousModel is a continuous-time linear time-invariant model of a dynamical system:
+//
+//	xdot = Ac*x + Bc*u + Ec*w
+//	y    = C*x + D*u
+//
+// It is discretized with zero-order hold at a sample time Dt and it implements filter.DiscreteModel
+// through the embedded discrete model so it can be used with discrete filters and smoothers.
+type ContinuousModel struct {
+	// BaseModel is the discretized model
+	*BaseModel
+	// Ac is continuous internal state matrix
+	Ac *mat.Dense
+	// Bc is continuous control matrix
+	Bc *mat.Dense
+	// Ec is continuous disturbance matrix
+	Ec *mat.Dense
+	// Dt is sample time
+	Dt float64
+}
+
+// NewContinuousModel creates a continuous-time model, discretizes it with sample time dt and returns it.
+// Bc and D can be nil if the system has no control input, Ec can be nil if the disturbance acts directly on the state.
+// It returns error if either the model matrices have invalid dimensions or dt is not positive.
+func NewContinuousModel(Ac, Bc, C, D, Ec *mat.Dense, dt float64) (*ContinuousModel, error) {
+	if Ac == nil || C == nil {
+		return nil, fmt.Errorf("invalid model matrices")
+	}
+
+	if dt <= 0 {
+		return nil, fmt.Errorf("invalid sample time: %f", dt)
+	}
+
+	nx, cols := Ac.Dims()
+	if nx != cols {
+		return nil, fmt.Errorf("invalid state matrix dimensions: [%d x %d]", nx, cols)
+	}
+
+	if Bc != nil {
+		if rows, cols := Bc.Dims(); rows != nx {
+			return nil, fmt.Errorf("invalid control matrix dimensions: [%d x %d]", rows, cols)
+		}
+	}
+
+	ny, cols := C.Dims()
+	if cols != nx {
+		return nil, fmt.Errorf("invalid output matrix dimensions: [%d x %d]", ny, cols)
+	}
+
+	if D != nil {
+		if rows, cols := D.Dims(); rows != ny {
+			return nil, fmt.Errorf("invalid output control matrix dimensions: [%d x %d]", rows, cols)
+		}
+	}
+
+	if Ec != nil {
+		if rows, cols := Ec.Dims(); rows != nx {
+			return nil, fmt.Errorf("invalid disturbance matrix dimensions: [%d x %d]", rows, cols)
+		}
+	}
+
+	A, B := discretize(Ac, Bc, dt)
+
+	return &ContinuousModel{
+		BaseModel: &BaseModel{A: A, B: B, C: C, D: D},
+		Ac:        Ac,
+		Bc:        Bc,
+		Ec:        Ec,
+		Dt:        dt,
+	}, nil
+}
+
+// discretize calculates zero-order hold discretization of continuous state matrix ac and control matrix bc
+// with sample time dt and returns the discrete matrices. Returned control matrix is nil if bc is nil.
+// It calculates the exponential of the block matrix [ac bc; 0 0]*dt whose upper blocks are the discrete matrices.
+func discretize(ac, bc *mat.Dense, dt float64) (*mat.Dense, *mat.Dense) {
+	nx, _ := ac.Dims()
+	nu := 0
+	if bc != nil {
+		_, nu = bc.Dims()
+	}
+
+	m := mat.NewDense(nx+nu, nx+nu, nil)
+	m.Slice(0, nx, 0, nx).(*mat.Dense).Scale(dt, ac)
+	if nu > 0 {
+		m.Slice(0, nx, nx, nx+nu).(*mat.Dense).Scale(dt, bc)
+	}
+
+	exp := &mat.Dense{}
+	exp.Exp(m)
+
+	a := mat.DenseCopyOf(exp.Slice(0, nx, 0, nx))
+	if nu == 0 {
+		return a, nil
+	}
+
+	return a, mat.DenseCopyOf(exp.Slice(0, nx, nx, nx+nu))
+}
+
+// ProcessNoiseCov calculates discrete process noise covariance from continuous disturbance
+// covariance (power spectral density) qc using Van Loan's method and returns it.
+// qc must have the same dimension as the number of Ec columns or as the state if Ec is nil.
+// The returned covariance acts directly on the discrete state so it can be used to create the filter state noise.
+// It returns error if qc has invalid dimension.
+func (c *ContinuousModel) ProcessNoiseCov(qc mat.Symmetric) (*mat.SymDense, error) {
+	nx, _ := c.Ac.Dims()
+
+	// Ec*Qc*Ec'
+	q := mat.NewDense(nx, nx, nil)
+	if c.Ec != nil {
+		if _, cols := c.Ec.Dims(); qc.SymmetricDim() != cols {
+			return nil, fmt.Errorf("invalid disturbance covariance dimension: %d", qc.SymmetricDim())
+		}
+		eq := &mat.Dense{}
+		eq.Mul(c.Ec, qc)
+		q.Mul(eq, c.Ec.T())
+	} else {
+		if qc.SymmetricDim() != nx {
+			return nil, fmt.Errorf("invalid disturbance covariance dimension: %d", qc.SymmetricDim())
+		}
+		q.Copy(qc)
+	}
+
+	// Van Loan block matrix: [-Ac Q; 0 Ac']*dt
+	m := mat.NewDense(2*nx, 2*nx, nil)
+	m.Slice(0, nx, 0, nx).(*mat.Dense).Scale(-c.Dt, c.Ac)
+	m.Slice(0, nx, nx, 2*nx).(*mat.Dense).Scale(c.Dt, q)
+	m.Slice(nx, 2*nx, nx, 2*nx).(*mat.Dense).Scale(c.Dt, c.Ac.T())
+
+	exp := &mat.Dense{}
+	exp.Exp(m)
+
+	// Qd = G22'*G12 where G22' is the discrete state matrix
+	qd := &mat.Dense{}
+	qd.Mul(exp.Slice(nx, 2*nx, nx, 2*nx).T(), exp.Slice(0, nx, nx, 2*nx))
+
+	cov := mat.NewSymDense(nx, nil)
+	for i := 0; i < nx; i++ {
+		for j := i; j < nx; j++ {
+			cov.SetSym(i, j, 0.5*(qd.At(i, j)+qd.At(j, i)))
+		}
+	}
+
+	return cov, nil
+}
@@ -0,0 +1,98 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestContinuous(t *testing.T) {
+	assert := assert.New(t)
+
+	dt := 0.1
+
+	// double integrator driven by acceleration
+	ac := mat.NewDense(2, 2, []float64{0.0, 1.0, 0.0, 0.0})
+	bc := mat.NewDense(2, 1, []float64{0.0, 1.0})
+	ec := mat.NewDense(2, 1, []float64{0.0, 1.0})
+
+	m, err := NewContinuousModel(ac, bc, C, D, ec, dt)
+	assert.NotNil(m)
+	assert.NoError(err)
+
+	var _ filter.DiscreteModel = m
+
+	expA := mat.NewDense(2, 2, []float64{1.0, dt, 0.0, 1.0})
+	assert.True(mat.EqualApprox(expA, m.SystemMatrix(), 1e-12))
+
+	expB := mat.NewDense(2, 1, []float64{dt * dt / 2, dt})
+	assert.True(mat.EqualApprox(expB, m.ControlMatrix(), 1e-12))
+
+	qc := mat.NewSymDense(1, []float64{2.0})
+	qd, err := m.ProcessNoiseCov(qc)
+	assert.NoError(err)
+	expQ := mat.NewSymDense(2, []float64{
+		2.0 * dt * dt * dt / 3, 2.0 * dt * dt / 2,
+		2.0 * dt * dt / 2, 2.0 * dt,
+	})
+	assert.True(mat.EqualApprox(expQ, qd, 1e-12))
+
+	// invalid disturbance covariance dimension
+	qd, err = m.ProcessNoiseCov(mat.NewSymDense(2, nil))
+	assert.Nil(qd)
+	assert.Error(err)
+
+	// first order system without disturbance matrix
+	a, b, q := 2.0, 3.0, 0.5
+	m, err = NewContinuousModel(mat.NewDense(1, 1, []float64{-a}), mat.NewDense(1, 1, []float64{b}),
+		mat.NewDense(1, 1, []float64{1.0}), nil, nil, dt)
+	assert.NotNil(m)
+	assert.NoError(err)
+
+	assert.InDelta(math.Exp(-a*dt), m.SystemMatrix().At(0, 0), 1e-12)
+	assert.InDelta(b*(1-math.Exp(-a*dt))/a, m.ControlMatrix().At(0, 0), 1e-12)
+
+	qd, err = m.ProcessNoiseCov(mat.NewSymDense(1, []float64{q}))
+	assert.NoError(err)
+	assert.InDelta(q*(1-math.Exp(-2*a*dt))/(2*a), qd.At(0, 0), 1e-12)
+
+	// no control input
+	m, err = NewContinuousModel(ac, nil, C, nil, nil, dt)
+	assert.NotNil(m)
+	assert.NoError(err)
+	_, nu, _, _ := m.SystemDims()
+	assert.Equal(0, nu)
+
+	// invalid sample time
+	m, err = NewContinuousModel(ac, bc, C, D, ec, 0)
+	assert.Nil(m)
+	assert.Error(err)
+
+	// invalid matrices
+	m, err = NewContinuousModel(nil, bc, C, D, ec, dt)
+	assert.Nil(m)
+	assert.Error(err)
+
+	m, err = NewContinuousModel(mat.NewDense(2, 3, nil), bc, C, D, ec, dt)
+	assert.Nil(m)
+	assert.Error(err)
+
+	m, err = NewContinuousModel(ac, mat.NewDense(3, 1, nil), C, D, ec, dt)
+	assert.Nil(m)
+	assert.Error(err)
+
+	m, err = NewContinuousModel(ac, bc, mat.NewDense(1, 3, nil), D, ec, dt)
+	assert.Nil(m)
+	assert.Error(err)
+
+	m, err = NewContinuousModel(ac, bc, C, mat.NewDense(2, 1, nil), ec, dt)
+	assert.Nil(m)
+	assert.Error(err)
+
+	m, err = NewContinuousModel(ac, bc, C, D, mat.NewDense(3, 1, nil), dt)
+	assert.Nil(m)
+	assert.Error(err)
+}
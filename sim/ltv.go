@@ -0,0 +1,94 @@
+package sim
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// LTVModel is a linear time-varying model of a dynamical system.
+// Its matrices are returned by functions of time step k; timestamps can be
+// mapped to time steps by the functions themselves e.g. t = t0 + k*dt.
+type LTVModel struct {
+	// A returns internal state matrix at step k
+	A func(k int) *mat.Dense
+	// B returns control matrix at step k
+	B func(k int) *mat.Dense
+	// C returns output state matrix at step k
+	C func(k int) *mat.Dense
+	// D returns output control matrix at step k
+	D func(k int) *mat.Dense
+	// E returns disturbance matrix at step k
+	E func(k int) *mat.Dense
+}
+
+// NewLTVModel creates a linear time-varying model and returns it.
+// B and D can be nil if the system has no control input, E can be nil if there is no disturbance matrix.
+// It returns error if either A or C is nil.
+func NewLTVModel(A, B, C, D, E func(k int) *mat.Dense) (*LTVModel, error) {
+	if A == nil || C == nil {
+		return nil, fmt.Errorf("invalid model matrices")
+	}
+
+	return &LTVModel{A: A, B: B, C: C, D: D, E: E}, nil
+}
+
+// at returns time-invariant model at step k
+func (l *LTVModel) at(k int) *BaseModel {
+	b := &BaseModel{A: l.A(k), C: l.C(k)}
+	if l.B != nil {
+		b.B = l.B(k)
+	}
+	if l.D != nil {
+		b.D = l.D(k)
+	}
+	if l.E != nil {
+		b.E = l.E(k)
+	}
+
+	return b
+}
+
+// PropagateAt propagates internal state x from step k to the next step
+// given an input vector u and a disturbance input wd.
+func (l *LTVModel) PropagateAt(k int, x, u, wd mat.Vector) (mat.Vector, error) {
+	return l.at(k).Propagate(x, u, wd)
+}
+
+// ObserveAt observes external state at step k given internal state x and input u.
+// wn is added to the output as a noise vector.
+func (l *LTVModel) ObserveAt(k int, x, u, wn mat.Vector) (mat.Vector, error) {
+	return l.at(k).Observe(x, u, wn)
+}
+
+// SystemDims returns internal state length (nx), input vector length (nu),
+// external/observable/output state length (ny) and disturbance vector length (nz).
+// Dimensions are read from the matrices at step 0.
+func (l *LTVModel) SystemDims() (nx, nu, ny, nz int) {
+	return l.at(0).SystemDims()
+}
+
+// SystemMatrixAt returns state propagation matrix at step k
+func (l *LTVModel) SystemMatrixAt(k int) mat.Matrix {
+	return l.at(k).SystemMatrix()
+}
+
+// ControlMatrixAt returns state propagation control matrix at step k
+func (l *LTVModel) ControlMatrixAt(k int) mat.Matrix {
+	return l.at(k).ControlMatrix()
+}
+
+// OutputMatrixAt returns observation matrix at step k
+func (l *LTVModel) OutputMatrixAt(k int) mat.Matrix {
+	return l.at(k).OutputMatrix()
+}
+
+// FeedForwardMatrixAt returns observation control matrix at step k
+func (l *LTVModel) FeedForwardMatrixAt(k int) mat.Matrix {
+	return l.at(k).FeedForwardMatrix()
+}
+
+// DisturbanceMatrixAt returns state disturbance matrix at step k
+func (l *LTVModel) DisturbanceMatrixAt(k int) mat.Matrix {
+	return l.at(k).DisturbanceMatrix()
+}
@@ -0,0 +1,63 @@
+package sim
+
+import (
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestLTV(t *testing.T) {
+	assert := assert.New(t)
+
+	// A changes with time step k
+	a := func(k int) *mat.Dense { return mat.NewDense(2, 2, []float64{1.0, float64(k), 0.0, 1.0}) }
+	b := func(k int) *mat.Dense { return B }
+	c := func(k int) *mat.Dense { return C }
+	d := func(k int) *mat.Dense { return D }
+
+	m, err := NewLTVModel(a, b, c, d, nil)
+	assert.NotNil(m)
+	assert.NoError(err)
+
+	nx, nu, ny, nz := m.SystemDims()
+	assert.Equal(2, nx)
+	assert.Equal(1, nu)
+	assert.Equal(1, ny)
+	assert.Equal(0, nz)
+
+	assert.True(mat.Equal(a(3), m.SystemMatrixAt(3)))
+	assert.True(mat.Equal(B, m.ControlMatrixAt(3)))
+	assert.True(mat.Equal(C, m.OutputMatrixAt(3)))
+	assert.True(mat.Equal(D, m.FeedForwardMatrixAt(3)))
+
+	// x1 = A(2)*x + B*u
+	exp := mat.NewVecDense(2, []float64{0.5 + 2*0.6 - 0.5, 0.6 - 1.0})
+	x1, err := m.PropagateAt(2, x, u, nil)
+	assert.NoError(err)
+	assert.True(mat.EqualApprox(exp, x1, 1e-12))
+
+	y, err := m.ObserveAt(2, x, u, nil)
+	assert.NoError(err)
+	assert.InDelta(0.5, y.AtVec(0), 1e-12)
+
+	// time-varying model frozen at step 2
+	s := filter.AtStep(m, 2)
+	assert.True(mat.Equal(a(2), s.SystemMatrix()))
+	x2, err := s.Propagate(x, u, nil)
+	assert.NoError(err)
+	assert.True(mat.Equal(x1, x2))
+
+	// no control input
+	m, err = NewLTVModel(a, nil, c, nil, nil)
+	assert.NotNil(m)
+	assert.NoError(err)
+	_, nu, _, _ = m.SystemDims()
+	assert.Equal(0, nu)
+
+	// missing system matrix
+	m, err = NewLTVModel(nil, b, c, d, nil)
+	assert.Nil(m)
+	assert.Error(err)
+}
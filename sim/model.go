@@ -62,9 +62,10 @@ func NewBaseModel(A, B, C, D, E *mat.Dense) (*BaseModel, error) {
 }
 
 // Propagate propagates internal state x of a falling ball to the next step
-// given an input vector u and a disturbance input z. (wd is process noise, z not implemented yet)
+// given an input vector u and a disturbance input wd. If E is set and the length of wd
+// matches its column count, wd is mapped onto the state as E*wd; otherwise wd of the state length is added to the state.
 func (b *BaseModel) Propagate(x, u, wd mat.Vector) (mat.Vector, error) {
-	nx, nu, _, _ := b.SystemDims()
+	nx, nu, _, nz := b.SystemDims()
 	if u != nil && u.Len() != nu {
 		return nil, fmt.Errorf("invalid input vector")
 	}
@@ -83,11 +84,15 @@ func (b *BaseModel) Propagate(x, u, wd mat.Vector) (mat.Vector, error) {
 		out.Add(out, outU)
 	}
 
-	if wd != nil && wd.Len() == nx { // TODO change _nx to _nz when switching to z and disturbance matrix implementation
-		// outZ := new(mat.Dense) // TODO add E disturbance matrix
-		// outZ.Mul(b.E, z)
-		// out.Add(out, outZ)
-		out.Add(out, wd)
+	if wd != nil {
+		switch {
+		case nz > 0 && wd.Len() == nz:
+			outZ := new(mat.Dense)
+			outZ.Mul(b.E, wd)
+			out.Add(out, outZ)
+		case wd.Len() == nx:
+			out.Add(out, wd)
+		}
 	}
 
 	return out.ColView(0), nil
@@ -170,3 +175,12 @@ func (b *BaseModel) FeedForwardMatrix() mat.Matrix {
 	}
 	return m
 }
+
+// DisturbanceMatrix returns state disturbance matrix
+func (b *BaseModel) DisturbanceMatrix() mat.Matrix {
+	m := &mat.Dense{}
+	if b.E != nil {
+		m.CloneFrom(b.E)
+	}
+	return m
+}
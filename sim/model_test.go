@@ -91,6 +91,13 @@ func TestBasePropagate(t *testing.T) {
 	v, err = f.Propagate(x, u, nil)
 	assert.NotNil(v)
 	assert.NoError(err)
+
+	// disturbance input is mapped onto the state through E
+	wd := mat.NewVecDense(1, []float64{2.0})
+	v, err = f.Propagate(x, u, wd)
+	assert.NoError(err)
+	exp := mat.NewVecDense(2, []float64{0.5 + 0.6 - 0.5 + 2.0, 0.6 - 1.0})
+	assert.True(mat.EqualApprox(exp, v, 1e-12))
 }
 
 func TestBaseObserve(t *testing.T) {
@@ -137,6 +144,9 @@ func TestBaseSystemMatrices(t *testing.T) {
 
 	m = f.FeedForwardMatrix()
 	assert.True(mat.EqualApprox(m, D, 0.001))
+
+	m = f.DisturbanceMatrix()
+	assert.True(mat.EqualApprox(m, E, 0.001))
 }
 
 func TestBaseDims(t *testing.T) {
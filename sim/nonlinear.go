@@ -0,0 +1,134 @@
+package sim
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// NonlinearModel is a nonlinear continuous-discrete model of a dynamical system:
+// its state evolves in continuous time according to xdot = F(t, x, u) and it's observed
+// at discrete time steps as y = H(x, u). Propagate integrates F over a single sample interval.
+type NonlinearModel struct {
+	// F returns time derivative of state x at time t given input u
+	F func(t float64, x, u mat.Vector) (mat.Vector, error)
+	// H returns system output for state x and input u
+	H func(x, u mat.Vector) (mat.Vector, error)
+	// Integrator integrates F over sample interval
+	Integrator Integrator
+	// Dt is sample time
+	Dt float64
+	// T is the start time of the propagated sample interval;
+	// it can be advanced between filter steps for time-dependent dynamics
+	T float64
+	// nx is state dimension
+	nx int
+	// nu is input dimension
+	nu int
+	// ny is output dimension
+	ny int
+}
+
+// NewNonlinearModel creates new nonlinear model with dynamics f, observation h, sample time dt
+// and model dimensions nx, nu, ny and returns it. If integ is nil, RK4 integrator is used.
+// It returns error if either f or h is nil, the dimensions are invalid or dt is not positive.
+func NewNonlinearModel(f func(t float64, x, u mat.Vector) (mat.Vector, error), h func(x, u mat.Vector) (mat.Vector, error),
+	nx, nu, ny int, dt float64, integ Integrator) (*NonlinearModel, error) {
+	if f == nil || h == nil {
+		return nil, fmt.Errorf("invalid model functions")
+	}
+
+	if nx <= 0 || nu < 0 || ny <= 0 {
+		return nil, fmt.Errorf("invalid model dimensions: [%d x %d x %d]", nx, nu, ny)
+	}
+
+	if dt <= 0 {
+		return nil, fmt.Errorf("invalid sample time: %f", dt)
+	}
+
+	if integ == nil {
+		integ = &RK4{}
+	}
+
+	return &NonlinearModel{
+		F:          f,
+		H:          h,
+		Integrator: integ,
+		Dt:         dt,
+		nx:         nx,
+		nu:         nu,
+		ny:         ny,
+	}, nil
+}
+
+// Propagate propagates internal state x to the next step given an input vector u and a disturbance input wd.
+// It integrates the dynamics from T to T+Dt with u held constant over the interval and adds wd to the result.
+// It returns error if either x or u have invalid dimensions or if the dynamics fail to be integrated.
+func (n *NonlinearModel) Propagate(x, u, wd mat.Vector) (mat.Vector, error) {
+	if u != nil && u.Len() != n.nu {
+		return nil, fmt.Errorf("invalid input vector")
+	}
+
+	if x.Len() != n.nx {
+		return nil, fmt.Errorf("invalid state vector")
+	}
+
+	f := func(t float64, x mat.Vector) (mat.Vector, error) {
+		dx, err := n.F(t, x, u)
+		if err != nil {
+			return nil, err
+		}
+
+		if dx.Len() != n.nx {
+			return nil, fmt.Errorf("invalid state derivative length: %d", dx.Len())
+		}
+
+		return dx, nil
+	}
+
+	xNext, err := n.Integrator.Integrate(f, n.T, n.T+n.Dt, x)
+	if err != nil {
+		return nil, fmt.Errorf("failed to integrate dynamics: %v", err)
+	}
+
+	if wd != nil && wd.Len() == n.nx {
+		xNext.AddVec(xNext, wd)
+	}
+
+	return xNext, nil
+}
+
+// Observe observes external state of the system given internal state x and input u.
+// wn is added to the output as a noise vector.
+// It returns error if either x or u have invalid dimensions or if the output fails to be calculated.
+func (n *NonlinearModel) Observe(x, u, wn mat.Vector) (mat.Vector, error) {
+	if u != nil && u.Len() != n.nu {
+		return nil, fmt.Errorf("invalid input vector")
+	}
+
+	if x.Len() != n.nx {
+		return nil, fmt.Errorf("invalid state vector")
+	}
+
+	y, err := n.H(x, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to observe system output: %v", err)
+	}
+
+	if y.Len() != n.ny {
+		return nil, fmt.Errorf("invalid output vector length: %d", y.Len())
+	}
+
+	out := mat.VecDenseCopyOf(y)
+	if wn != nil && wn.Len() == n.ny {
+		out.AddVec(out, wn)
+	}
+
+	return out, nil
+}
+
+// SystemDims returns internal state length (nx), input vector length (nu),
+// external/observable/output state length (ny) and disturbance vector length (nz).
+func (n *NonlinearModel) SystemDims() (nx, nu, ny, nz int) {
+	return n.nx, n.nu, n.ny, 0
+}
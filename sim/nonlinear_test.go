@@ -0,0 +1,100 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNonlinear(t *testing.T) {
+	assert := assert.New(t)
+
+	// pendulum driven by torque u
+	f := func(t float64, x, u mat.Vector) (mat.Vector, error) {
+		dx := mat.NewVecDense(2, []float64{x.AtVec(1), -math.Sin(x.AtVec(0))})
+		if u != nil {
+			dx.SetVec(1, dx.AtVec(1)+u.AtVec(0))
+		}
+		return dx, nil
+	}
+	h := func(x, u mat.Vector) (mat.Vector, error) {
+		return mat.NewVecDense(1, []float64{math.Sin(x.AtVec(0))}), nil
+	}
+
+	m, err := NewNonlinearModel(f, h, 2, 1, 1, 0.01, nil)
+	assert.NotNil(m)
+	assert.NoError(err)
+
+	var _ filter.Model = m
+
+	nx, nu, ny, nz := m.SystemDims()
+	assert.Equal(2, nx)
+	assert.Equal(1, nu)
+	assert.Equal(1, ny)
+	assert.Equal(0, nz)
+
+	x0 := mat.NewVecDense(2, []float64{0.1, 0.0})
+	u0 := mat.NewVecDense(1, []float64{0.0})
+
+	// all integrators agree for small sample time
+	xRK4, err := m.Propagate(x0, u0, nil)
+	assert.NoError(err)
+	for _, integ := range []Integrator{&Euler{Steps: 100}, &RK45{}} {
+		m.Integrator = integ
+		x, err := m.Propagate(x0, u0, nil)
+		assert.NoError(err)
+		assert.True(mat.EqualApprox(xRK4, x, 1e-6))
+	}
+
+	// disturbance is added to the state
+	wd := mat.NewVecDense(2, []float64{1.0, 1.0})
+	x, err := m.Propagate(x0, u0, wd)
+	assert.NoError(err)
+	assert.InDelta(xRK4.AtVec(0)+1.0, x.AtVec(0), 1e-6)
+
+	y, err := m.Observe(x0, u0, mat.NewVecDense(1, []float64{0.5}))
+	assert.NoError(err)
+	assert.InDelta(math.Sin(0.1)+0.5, y.AtVec(0), 1e-12)
+
+	// invalid vectors
+	x, err = m.Propagate(mat.NewVecDense(3, nil), u0, nil)
+	assert.Nil(x)
+	assert.Error(err)
+
+	x, err = m.Propagate(x0, mat.NewVecDense(3, nil), nil)
+	assert.Nil(x)
+	assert.Error(err)
+
+	y, err = m.Observe(mat.NewVecDense(3, nil), u0, nil)
+	assert.Nil(y)
+	assert.Error(err)
+
+	y, err = m.Observe(x0, mat.NewVecDense(3, nil), nil)
+	assert.Nil(y)
+	assert.Error(err)
+
+	// invalid model
+	_m, err := NewNonlinearModel(nil, h, 2, 1, 1, 0.01, nil)
+	assert.Nil(_m)
+	assert.Error(err)
+
+	_m, err = NewNonlinearModel(f, h, 0, 1, 1, 0.01, nil)
+	assert.Nil(_m)
+	assert.Error(err)
+
+	_m, err = NewNonlinearModel(f, h, 2, 1, 1, 0, nil)
+	assert.Nil(_m)
+	assert.Error(err)
+
+	// invalid dynamics output
+	_m, err = NewNonlinearModel(func(t float64, x, u mat.Vector) (mat.Vector, error) {
+		return mat.NewVecDense(3, nil), nil
+	}, h, 2, 1, 1, 0.01, nil)
+	assert.NoError(err)
+	x, err = _m.Propagate(x0, u0, nil)
+	assert.Nil(x)
+	assert.Error(err)
+}
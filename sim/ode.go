@@ -0,0 +1,237 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const (
+	// rk45AbsTol is default RK45 absolute tolerance
+	rk45AbsTol = 1e-6
+	// rk45RelTol is default RK45 relative tolerance
+	rk45RelTol = 1e-6
+	// rk45MaxSteps is default maximum number of RK45 steps
+	rk45MaxSteps = 100000
+)
+
+// ODEFunc returns time derivative of state x at time t
+type ODEFunc func(t float64, x mat.Vector) (mat.Vector, error)
+
+// Integrator integrates ordinary differential equations
+type Integrator interface {
+	// Integrate integrates f from time t0 to time t1 starting in state x and returns the state at t1.
+	Integrate(f ODEFunc, t0, t1 float64, x mat.Vector) (*mat.VecDense, error)
+}
+
+// Euler is explicit Euler integrator with fixed step size
+type Euler struct {
+	// Steps is number of integration steps; single step is used if Steps is not positive
+	Steps int
+}
+
+// Integrate integrates f from time t0 to time t1 starting in state x and returns the state at t1.
+// It returns error if f fails to be evaluated.
+func (e *Euler) Integrate(f ODEFunc, t0, t1 float64, x mat.Vector) (*mat.VecDense, error) {
+	return integrateFixed(f, t0, t1, x, e.Steps, eulerStep)
+}
+
+// eulerStep makes a single Euler step of size h from state x at time t
+func eulerStep(f ODEFunc, t, h float64, x *mat.VecDense) (*mat.VecDense, error) {
+	dx, err := f(t, x)
+	if err != nil {
+		return nil, err
+	}
+
+	xNext := mat.NewVecDense(x.Len(), nil)
+	xNext.AddScaledVec(x, h, dx)
+
+	return xNext, nil
+}
+
+// RK4 is classic fourth order Runge-Kutta integrator with fixed step size
+type RK4 struct {
+	// Steps is number of integration steps; single step is used if Steps is not positive
+	Steps int
+}
+
+// Integrate integrates f from time t0 to time t1 starting in state x and returns the state at t1.
+// It returns error if f fails to be evaluated.
+func (r *RK4) Integrate(f ODEFunc, t0, t1 float64, x mat.Vector) (*mat.VecDense, error) {
+	return integrateFixed(f, t0, t1, x, r.Steps, rk4Step)
+}
+
+// rk4Step makes a single RK4 step of size h from state x at time t
+func rk4Step(f ODEFunc, t, h float64, x *mat.VecDense) (*mat.VecDense, error) {
+	n := x.Len()
+
+	k1, err := f(t, x)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := mat.NewVecDense(n, nil)
+	tmp.AddScaledVec(x, h/2, k1)
+	k2, err := f(t+h/2, tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp = mat.NewVecDense(n, nil)
+	tmp.AddScaledVec(x, h/2, k2)
+	k3, err := f(t+h/2, tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp = mat.NewVecDense(n, nil)
+	tmp.AddScaledVec(x, h, k3)
+	k4, err := f(t+h, tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	xNext := mat.VecDenseCopyOf(x)
+	xNext.AddScaledVec(xNext, h/6, k1)
+	xNext.AddScaledVec(xNext, h/3, k2)
+	xNext.AddScaledVec(xNext, h/3, k3)
+	xNext.AddScaledVec(xNext, h/6, k4)
+
+	return xNext, nil
+}
+
+// integrateFixed integrates f from t0 to t1 using n fixed size steps of the given stepper.
+func integrateFixed(f ODEFunc, t0, t1 float64, x mat.Vector, n int,
+	step func(ODEFunc, float64, float64, *mat.VecDense) (*mat.VecDense, error)) (*mat.VecDense, error) {
+	if n <= 0 {
+		n = 1
+	}
+
+	h := (t1 - t0) / float64(n)
+	xNext := mat.VecDenseCopyOf(x)
+
+	var err error
+	for i := 0; i < n; i++ {
+		xNext, err = step(f, t0+float64(i)*h, h, xNext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate ODE: %v", err)
+		}
+	}
+
+	return xNext, nil
+}
+
+// RK45 is adaptive step size Runge-Kutta integrator using Dormand-Prince 5(4) pair
+type RK45 struct {
+	// AbsTol is absolute error tolerance; 1e-6 is used if AbsTol is not positive
+	AbsTol float64
+	// RelTol is relative error tolerance; 1e-6 is used if RelTol is not positive
+	RelTol float64
+	// MaxSteps is maximum number of attempted steps; 100000 is used if MaxSteps is not positive
+	MaxSteps int
+}
+
+// Dormand-Prince 5(4) Butcher tableau
+var (
+	dpC = []float64{0, 1.0 / 5, 3.0 / 10, 4.0 / 5, 8.0 / 9, 1, 1}
+	dpA = [][]float64{
+		{},
+		{1.0 / 5},
+		{3.0 / 40, 9.0 / 40},
+		{44.0 / 45, -56.0 / 15, 32.0 / 9},
+		{19372.0 / 6561, -25360.0 / 2187, 64448.0 / 6561, -212.0 / 729},
+		{9017.0 / 3168, -355.0 / 33, 46732.0 / 5247, 49.0 / 176, -5103.0 / 18656},
+		{35.0 / 384, 0, 500.0 / 1113, 125.0 / 192, -2187.0 / 6784, 11.0 / 84},
+	}
+	// dpE is the difference between fifth and fourth order solution weights
+	dpE = []float64{
+		35.0/384 - 5179.0/57600,
+		0,
+		500.0/1113 - 7571.0/16695,
+		125.0/192 - 393.0/640,
+		-2187.0/6784 + 92097.0/339200,
+		11.0/84 - 187.0/2100,
+		-1.0 / 40,
+	}
+)
+
+// Integrate integrates f from time t0 to time t1 starting in state x and returns the state at t1.
+// Step size is adapted so that the local error estimate stays within the configured tolerances.
+// It returns error if f fails to be evaluated or if the integration does not finish in maximum number of steps.
+func (r *RK45) Integrate(f ODEFunc, t0, t1 float64, x mat.Vector) (*mat.VecDense, error) {
+	absTol, relTol, maxSteps := r.AbsTol, r.RelTol, r.MaxSteps
+	if absTol <= 0 {
+		absTol = rk45AbsTol
+	}
+	if relTol <= 0 {
+		relTol = rk45RelTol
+	}
+	if maxSteps <= 0 {
+		maxSteps = rk45MaxSteps
+	}
+
+	n := x.Len()
+	xNow := mat.VecDenseCopyOf(x)
+
+	t, h := t0, t1-t0
+	if h == 0 {
+		return xNow, nil
+	}
+
+	k := make([]mat.Vector, len(dpC))
+	tmp := mat.NewVecDense(n, nil)
+
+	for i := 0; i < maxSteps; i++ {
+		// do not step over t1
+		last := false
+		if (h > 0 && t+h >= t1) || (h < 0 && t+h <= t1) {
+			h = t1 - t
+			last = true
+		}
+
+		for s := range dpC {
+			tmp.CopyVec(xNow)
+			for j, a := range dpA[s] {
+				tmp.AddScaledVec(tmp, h*a, k[j])
+			}
+
+			dx, err := f(t+dpC[s]*h, tmp)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate ODE: %v", err)
+			}
+			k[s] = mat.VecDenseCopyOf(dx)
+		}
+
+		// the last stage is evaluated at the fifth order solution
+		xNext := mat.VecDenseCopyOf(tmp)
+
+		// scaled local error estimate
+		errNorm := 0.0
+		for j := 0; j < n; j++ {
+			e := 0.0
+			for s := range dpE {
+				e += h * dpE[s] * k[s].AtVec(j)
+			}
+			scale := absTol + relTol*math.Max(math.Abs(xNow.AtVec(j)), math.Abs(xNext.AtVec(j)))
+			errNorm = math.Max(errNorm, math.Abs(e)/scale)
+		}
+
+		if errNorm <= 1 {
+			if last {
+				return xNext, nil
+			}
+			t += h
+			xNow = xNext
+		}
+
+		// adapt step size
+		factor := 5.0
+		if errNorm > 0 {
+			factor = math.Min(5.0, math.Max(0.2, 0.9*math.Pow(errNorm, -0.2)))
+		}
+		h *= factor
+	}
+
+	return nil, fmt.Errorf("integration did not finish in %d steps", maxSteps)
+}
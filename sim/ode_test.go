@@ -0,0 +1,73 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+// decay is exponential decay xdot = -x
+func decay(t float64, x mat.Vector) (mat.Vector, error) {
+	dx := mat.NewVecDense(x.Len(), nil)
+	dx.ScaleVec(-1.0, x)
+
+	return dx, nil
+}
+
+func TestIntegrators(t *testing.T) {
+	assert := assert.New(t)
+
+	x0 := mat.NewVecDense(1, []float64{1.0})
+	exp := math.Exp(-1.0)
+
+	testCases := []struct {
+		integ Integrator
+		tol   float64
+	}{
+		{&Euler{Steps: 1000}, 1e-3},
+		{&RK4{Steps: 10}, 1e-5},
+		{&RK45{}, 1e-5},
+		{&RK45{AbsTol: 1e-10, RelTol: 1e-10}, 1e-9},
+	}
+
+	for _, tc := range testCases {
+		x, err := tc.integ.Integrate(decay, 0, 1, x0)
+		assert.NoError(err)
+		assert.InDelta(exp, x.AtVec(0), tc.tol)
+		// initial state is not modified
+		assert.Equal(1.0, x0.AtVec(0))
+	}
+
+	// single step is used by default
+	x, err := (&Euler{}).Integrate(decay, 0, 1, x0)
+	assert.NoError(err)
+	assert.Equal(0.0, x.AtVec(0))
+
+	// zero length interval
+	x, err = (&RK45{}).Integrate(decay, 1, 1, x0)
+	assert.NoError(err)
+	assert.Equal(1.0, x.AtVec(0))
+
+	// backward integration
+	x, err = (&RK45{}).Integrate(decay, 1, 0, mat.NewVecDense(1, []float64{exp}))
+	assert.NoError(err)
+	assert.InDelta(1.0, x.AtVec(0), 1e-5)
+
+	// failing ODE
+	fail := func(t float64, x mat.Vector) (mat.Vector, error) {
+		return nil, fmt.Errorf("failed")
+	}
+	for _, integ := range []Integrator{&Euler{}, &RK4{}, &RK45{}} {
+		x, err = integ.Integrate(fail, 0, 1, x0)
+		assert.Nil(x)
+		assert.Error(err)
+	}
+
+	// maximum number of steps exceeded
+	x, err = (&RK45{AbsTol: 1e-14, RelTol: 1e-14, MaxSteps: 2}).Integrate(decay, 0, 10, x0)
+	assert.Nil(x)
+	assert.Error(err)
+}
@@ -6,19 +6,21 @@ import (
 	filter "github.com/milosgajdos/go-estimate"
 	"github.com/milosgajdos/go-estimate/estimate"
 	"github.com/milosgajdos/go-estimate/noise"
-	"gonum.org/v1/gonum/diff/fd"
 	"gonum.org/v1/gonum/mat"
 )
 
 // JacFunc defines jacobian function to calculate Jacobian matrix
-type JacFunc func(u mat.Vector) func(y, x []float64)
+type JacFunc = filter.JacFunc
 
 // ERTS is Extended Rauch-Tung-Striebel smoother
 type ERTS struct {
 	// q is state noise a.k.a. process noise
 	q filter.Noise
-	// FJacFn is propagation Jacobian function
+	// FJacFn is propagation Jacobian function;
+	// it is not used if the model implements filter.Linearizer
 	FJacFn JacFunc
+	// jac calculates Jacobian and records model errors of finite difference Jacobian function
+	jac *filter.FDJacobian
 	// f is EKF jacobian matrix
 	f *mat.Dense
 	// m is system model
@@ -36,34 +38,23 @@ func New(m filter.Model, init filter.InitCond, q filter.Noise) (*ERTS, error) {
 	}
 
 	if q != nil {
-		if q.Cov().SymmetricDim() != in {
-			return nil, fmt.Errorf("Invalid state noise dimension: %d", q.Cov().SymmetricDim())
+		if _, err := filter.StateNoiseCov(m, q.Cov()); err != nil {
+			return nil, fmt.Errorf("Invalid state noise: %v", err)
 		}
 	} else {
 		q, _ = noise.NewNone()
 	}
 
-	// propagation Jacobian
-	fJacFn := func(u mat.Vector) func([]float64, []float64) {
-		q, _ := noise.NewZero(in)
-
-		return func(xOut, xNow []float64) {
-			x := mat.NewVecDense(len(xNow), xNow)
-			xNext, err := m.Propagate(x, u, q.Sample())
-			if err != nil {
-				panic(err)
-			}
+	// finite difference Jacobian records errors returned by the model
+	jac := &filter.FDJacobian{}
 
-			for i := 0; i < len(xOut); i++ {
-				xOut[i] = xNext.At(i, 0)
-			}
-		}
-	}
+	// propagation Jacobian
 	f := mat.NewDense(in, in, nil)
 
 	return &ERTS{
 		q:      q,
-		FJacFn: fJacFn,
+		FJacFn: jac.PropagateFunc(m),
+		jac:    jac,
 		f:      f,
 		m:      m,
 		start:  init,
@@ -107,17 +98,20 @@ func (s *ERTS) Smooth(est []filter.Estimate, u []mat.Vector) ([]filter.Estimate,
 		}
 
 		// calculate propagation Jacobian matrix
-		fd.Jacobian(s.f, s.FJacFn(uEst), mat.Col(nil, 0, est[i].Val()), &fd.JacobianSettings{
-			Formula:    fd.Central,
-			Concurrent: true,
-		})
+		if err := s.jac.StateJacobian(s.f, s.m, s.FJacFn, est[i].Val(), uEst); err != nil {
+			return nil, fmt.Errorf("Failed to calculate propagation Jacobian: %v", err)
+		}
 
 		pk1 := &mat.Dense{}
 		pk1.Mul(s.f, est[i].Cov())
 		pk1.Mul(pk1, s.f.T())
 
 		if _, ok := s.q.(*noise.None); !ok {
-			pk1.Add(pk1, s.q.Cov())
+			qCov, err := filter.StateNoiseCov(s.m, s.q.Cov())
+			if err != nil {
+				return nil, fmt.Errorf("Failed to map state noise: %v", err)
+			}
+			pk1.Add(pk1, qCov)
 		}
 
 		// calculat smoothing matrix
@@ -1,6 +1,8 @@
 package erts
 
 import (
+	"fmt"
+	"math"
 	"os"
 	"testing"
 
@@ -116,3 +118,99 @@ func TestERTSSmooth(t *testing.T) {
 	assert.NotNil(sx)
 	assert.NoError(err)
 }
+
+// linModel provides exact Jacobians of a linear model
+type linModel struct {
+	*sim.BaseModel
+	err error
+}
+
+func (m *linModel) StateJacobian(x, u mat.Vector) (mat.Matrix, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.SystemMatrix(), nil
+}
+
+func (m *linModel) OutputJacobian(x, u mat.Vector) (mat.Matrix, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.OutputMatrix(), nil
+}
+
+// gridModel fails to propagate states whose first element is not an integer
+type gridModel struct {
+	*sim.BaseModel
+}
+
+func (m *gridModel) Propagate(x, u, wd mat.Vector) (mat.Vector, error) {
+	if x.AtVec(0) != math.Trunc(x.AtVec(0)) {
+		return nil, fmt.Errorf("state off grid")
+	}
+	return m.BaseModel.Propagate(x, u, wd)
+}
+
+func TestERTSLinearizer(t *testing.T) {
+	assert := assert.New(t)
+
+	fd, err := New(okModel, ic, q)
+	assert.NotNil(fd)
+	assert.NoError(err)
+
+	lm := &linModel{BaseModel: okModel}
+	s, err := New(lm, ic, q)
+	assert.NotNil(s)
+	assert.NoError(err)
+
+	sxFD, err := fd.Smooth(ex, ux)
+	assert.NoError(err)
+	sx, err := s.Smooth(ex, ux)
+	assert.NoError(err)
+	for i := range sx {
+		assert.True(mat.EqualApprox(sxFD[i].Cov(), sx[i].Cov(), 1e-6))
+	}
+
+	// Jacobian errors are returned
+	lm.err = fmt.Errorf("jacobian error")
+	sx, err = s.Smooth(ex, ux)
+	assert.Nil(sx)
+	assert.Error(err)
+
+	// model errors in finite difference Jacobian are returned rather than panicking
+	s, err = New(&gridModel{okModel}, ic, q)
+	assert.NotNil(s)
+	assert.NoError(err)
+
+	sx, err = s.Smooth(ex, ux)
+	assert.Nil(sx)
+	assert.Error(err)
+}
+
+func BenchmarkERTSSmoothFD(b *testing.B) {
+	s, err := New(okModel, ic, q)
+	if err != nil {
+		b.Fatalf("failed to create ERTS: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Smooth(ex, ux); err != nil {
+			b.Fatalf("failed to smooth: %v", err)
+		}
+	}
+}
+
+func BenchmarkERTSSmoothLinearizer(b *testing.B) {
+	s, err := New(&linModel{BaseModel: okModel}, ic, q)
+	if err != nil {
+		b.Fatalf("failed to create ERTS: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Smooth(ex, ux); err != nil {
+			b.Fatalf("failed to smooth: %v", err)
+		}
+	}
+}
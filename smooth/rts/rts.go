@@ -17,6 +17,8 @@ type RTS struct {
 	m filter.DiscreteModel
 	// start is initial condition
 	start filter.InitCond
+	// tv is time-varying system model; nil for time-invariant models
+	tv filter.TimeVaryingModel
 }
 
 // New creates new RTS and returns it.
@@ -28,8 +30,8 @@ func New(m filter.DiscreteModel, init filter.InitCond, q filter.Noise) (*RTS, er
 	}
 
 	if q != nil {
-		if q.Cov().SymmetricDim() != in {
-			return nil, fmt.Errorf("Invalid state noise dimension: %d", q.Cov().SymmetricDim())
+		if _, err := filter.StateNoiseCov(m, q.Cov()); err != nil {
+			return nil, fmt.Errorf("Invalid state noise: %v", err)
 		}
 	} else {
 		q, _ = noise.NewNone()
@@ -42,6 +44,31 @@ func New(m filter.DiscreteModel, init filter.InitCond, q filter.Noise) (*RTS, er
 	}, nil
 }
 
+// NewTimeVarying creates new RTS for time-varying model m and returns it.
+// Estimates passed to Smooth are expected to come from KF created by kf.NewTimeVarying:
+// estimate with index i is returned by the i-th Run of KF at time step i+1, so it is smoothed
+// using the model matrices at time step i+1 which KF used to predict the following estimate.
+// It returns error if it fails to create RTS smoother.
+func NewTimeVarying(m filter.TimeVaryingModel, init filter.InitCond, q filter.Noise) (*RTS, error) {
+	s, err := New(filter.AtStep(m, 0), init, q)
+	if err != nil {
+		return nil, err
+	}
+
+	s.tv = m
+
+	return s, nil
+}
+
+// model returns system model at time step k
+func (s *RTS) model(k int) filter.DiscreteModel {
+	if s.tv == nil {
+		return s.m
+	}
+
+	return filter.AtStep(s.tv, k)
+}
+
 // Smooth implements Rauch-Tung-Striebel smoothing algorithm.
 // It uses estimates est to compute smoothed estimates and returns them.
 // It returns error if either est is nil or smoothing could not be computed.
@@ -72,24 +99,30 @@ func (s *RTS) Smooth(est []filter.Estimate, u []mat.Vector) ([]filter.Estimate,
 		if u != nil {
 			uEst = u[i]
 		}
-		xk1, err := s.m.Propagate(est[i].Val(), uEst, s.q.Sample())
+		// estimate i is at step i+1
+		m := s.model(i + 1)
+		xk1, err := m.Propagate(est[i].Val(), uEst, s.q.Sample())
 		if err != nil {
 			return nil, fmt.Errorf("Model state propagation failed: %v", err)
 		}
 
 		// propagate covariance matrix to the next step
 		pk1 := &mat.Dense{}
-		pk1.Mul(s.m.SystemMatrix(), est[i].Cov())
-		pk1.Mul(pk1, s.m.SystemMatrix().T())
+		pk1.Mul(m.SystemMatrix(), est[i].Cov())
+		pk1.Mul(pk1, m.SystemMatrix().T())
 
 		if _, ok := s.q.(*noise.None); !ok {
-			pk1.Add(pk1, s.q.Cov())
+			qCov, err := filter.StateNoiseCov(m, s.q.Cov())
+			if err != nil {
+				return nil, fmt.Errorf("Failed to map state noise: %v", err)
+			}
+			pk1.Add(pk1, qCov)
 		}
 
 		// calculat smoothing matrix
 		c := &mat.Dense{}
 		// Pk*Ak'
-		c.Mul(est[i].Cov(), s.m.SystemMatrix().T())
+		c.Mul(est[i].Cov(), m.SystemMatrix().T())
 		// P_(k+1)^-1 inverse
 		pinv := &mat.Dense{}
 		// invert predicted P_k+1 covariance
@@ -6,6 +6,7 @@ import (
 
 	filter "github.com/milosgajdos/go-estimate"
 	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman/kf"
 	"github.com/milosgajdos/go-estimate/noise"
 	"github.com/milosgajdos/go-estimate/sim"
 	"github.com/stretchr/testify/assert"
@@ -115,4 +116,156 @@ func TestRTSSmooth(t *testing.T) {
 	sx, err = s.Smooth(ex, ux)
 	assert.NotNil(sx)
 	assert.NoError(err)
+
+	// disturbance noise mapped through E
+	m := &sim.BaseModel{A: okModel.A, B: okModel.B, C: okModel.C, D: okModel.D, E: mat.NewDense(2, 1, []float64{0.5, 1.0})}
+	_q, err := noise.NewGaussian([]float64{0}, mat.NewSymDense(1, []float64{0.5}))
+	assert.NoError(err)
+
+	s, err = New(m, ic, _q)
+	assert.NotNil(s)
+	assert.NoError(err)
+
+	sx, err = s.Smooth(ex, ux)
+	assert.NotNil(sx)
+	assert.NoError(err)
+}
+
+func TestRTSTimeVarying(t *testing.T) {
+	assert := assert.New(t)
+
+	a := func(k int) *mat.Dense { return okModel.A }
+	b := func(k int) *mat.Dense { return okModel.B }
+	c := func(k int) *mat.Dense { return okModel.C }
+	d := func(k int) *mat.Dense { return okModel.D }
+
+	m, err := sim.NewLTVModel(a, b, c, d, nil)
+	assert.NotNil(m)
+	assert.NoError(err)
+
+	// no state noise so both smoothers are deterministic
+	s, err := New(okModel, ic, nil)
+	assert.NotNil(s)
+	assert.NoError(err)
+
+	tv, err := NewTimeVarying(m, ic, nil)
+	assert.NotNil(tv)
+	assert.NoError(err)
+
+	sx, err := s.Smooth(ex, ux)
+	assert.NotNil(sx)
+	assert.NoError(err)
+
+	tx, err := tv.Smooth(ex, ux)
+	assert.NotNil(tx)
+	assert.NoError(err)
+
+	// constant time-varying model produces the same estimates as time-invariant model
+	for i := range sx {
+		assert.True(mat.EqualApprox(sx[i].Val(), tx[i].Val(), 1e-12))
+		assert.True(mat.EqualApprox(sx[i].Cov(), tx[i].Cov(), 1e-12))
+	}
+
+	// invalid model
+	a = func(k int) *mat.Dense { return mat.NewDense(3, 3, nil) }
+	m, err = sim.NewLTVModel(a, b, c, d, nil)
+	assert.NotNil(m)
+	assert.NoError(err)
+
+	tv, err = NewTimeVarying(m, ic, q)
+	assert.Nil(tv)
+	assert.Error(err)
+}
+
+func TestRTSTimeVaryingKF(t *testing.T) {
+	assert := assert.New(t)
+
+	// step dependent propagation matrix
+	a := func(k int) *mat.Dense { return mat.NewDense(2, 2, []float64{1.0, 0.1 * float64(k+1), 0.0, 1.0}) }
+	b := func(k int) *mat.Dense { return okModel.B }
+	c := func(k int) *mat.Dense { return okModel.C }
+	d := func(k int) *mat.Dense { return okModel.D }
+
+	m, err := sim.NewLTVModel(a, b, c, d, nil)
+	assert.NotNil(m)
+	assert.NoError(err)
+
+	// noise which samples its mean keeps both KF and smoother deterministic
+	_q := newMeanNoise(q.Cov())
+	_r := newMeanNoise(mat.NewSymDense(1, []float64{0.25}))
+	f, err := kf.NewTimeVarying(m, ic, _q, _r)
+	assert.NotNil(f)
+	assert.NoError(err)
+
+	// run KF and keep its predictions and estimates
+	zs := []float64{1.5, 2.0, 4.0, 5.5, 8.0}
+	var preds, ests []filter.Estimate
+	x := mat.VecDenseCopyOf(ic.State())
+	for i, zi := range zs {
+		pred, err := f.Predict(x, ux[i])
+		assert.NoError(err)
+		preds = append(preds, copyEstimate(pred))
+
+		est, err := f.Update(mat.VecDenseCopyOf(pred.Val()), ux[i], mat.NewVecDense(1, []float64{zi}))
+		assert.NoError(err)
+		ests = append(ests, copyEstimate(est))
+
+		x = mat.VecDenseCopyOf(est.Val())
+	}
+
+	tv, err := NewTimeVarying(m, ic, _q)
+	assert.NotNil(tv)
+	assert.NoError(err)
+
+	sx, err := tv.Smooth(ests, ux)
+	assert.NotNil(sx)
+	assert.NoError(err)
+
+	// smoothed estimates match the RTS recursion over KF predictions:
+	// estimate i is smoothed with the model KF used to predict estimate i+1
+	for i := len(ests) - 2; i >= 0; i-- {
+		pInv := &mat.Dense{}
+		assert.NoError(pInv.Inverse(preds[i+1].Cov()))
+		gain := &mat.Dense{}
+		gain.Mul(ests[i].Cov(), a(i+1).T())
+		gain.Mul(gain, pInv)
+
+		xs := &mat.VecDense{}
+		xs.SubVec(sx[i+1].Val(), preds[i+1].Val())
+		xs.MulVec(gain, xs)
+		xs.AddVec(ests[i].Val(), xs)
+
+		ps := &mat.Dense{}
+		ps.Sub(sx[i+1].Cov(), preds[i+1].Cov())
+		ps.Mul(gain, ps)
+		ps.Mul(ps, gain.T())
+		ps.Add(ests[i].Cov(), ps)
+
+		assert.True(mat.EqualApprox(xs, sx[i].Val(), 1e-9))
+		assert.True(mat.EqualApprox(ps, sx[i].Cov(), 1e-9))
+	}
+}
+
+// copyEstimate returns a copy of estimate e
+func copyEstimate(e filter.Estimate) filter.Estimate {
+	cov := mat.NewSymDense(e.Cov().SymmetricDim(), nil)
+	cov.CopySym(e.Cov())
+	c, _ := estimate.NewBaseWithCov(mat.VecDenseCopyOf(e.Val()), cov)
+
+	return c
+}
+
+// meanNoise is Gaussian noise which always samples its mean
+type meanNoise struct {
+	*noise.Gaussian
+}
+
+func newMeanNoise(cov mat.Symmetric) *meanNoise {
+	g, _ := noise.NewGaussian(make([]float64, cov.SymmetricDim()), cov)
+
+	return &meanNoise{Gaussian: g}
+}
+
+func (n *meanNoise) Sample() mat.Vector {
+	return mat.NewVecDense(n.Cov().SymmetricDim(), n.Mean())
 }
@@ -0,0 +1,169 @@
+package urts
+
+import (
+	"fmt"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman/sigma"
+	"github.com/milosgajdos/go-estimate/kalman/ukf"
+	"github.com/milosgajdos/go-estimate/noise"
+	"gonum.org/v1/gonum/mat"
+)
+
+// URTS is Unscented Rauch-Tung-Striebel smoother.
+// Instead of linearizing the model it propagates sigma points of every estimate
+// and calculates the smoothing gain from sigma point cross covariances.
+// State noise is assumed to be additive.
+type URTS struct {
+	// q is state noise a.k.a. process noise
+	q filter.Noise
+	// unit stores sigma points of standard normal distribution and their weights
+	unit *sigma.Points
+	// m is system model
+	m filter.Model
+	// start is initial condition
+	start filter.InitCond
+}
+
+// New creates new URTS and returns it.
+// Sigma points are generated using configuration c the same way UKF generates them.
+// It returns error if it fails to create URTS smoother.
+func New(m filter.Model, init filter.InitCond, q filter.Noise, c *ukf.Config) (*URTS, error) {
+	in, _, out, _ := m.SystemDims()
+	if in <= 0 || out <= 0 {
+		return nil, fmt.Errorf("Invalid model dimensions: [%d x %d]", in, out)
+	}
+
+	if q != nil {
+		if _, err := filter.StateNoiseCov(m, q.Cov()); err != nil {
+			return nil, fmt.Errorf("Invalid state noise: %v", err)
+		}
+	} else {
+		q, _ = noise.NewNone()
+	}
+
+	gen := c.Points
+	if gen == nil {
+		gen = &ukf.Symmetric{Alpha: c.Alpha, Beta: c.Beta, Kappa: c.Kappa}
+	}
+
+	// sigma points of standard normal distribution
+	unit, err := gen.Generate(in)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate sigma points: %v", err)
+	}
+
+	return &URTS{
+		q:     q,
+		unit:  unit,
+		m:     m,
+		start: init,
+	}, nil
+}
+
+// Smooth implements Unscented Rauch-Tung-Striebel smoothing algorithm.
+// It uses estimates est to compute smoothed estimates and returns them.
+// It returns error if either est is nil or smoothing could not be computed.
+func (s *URTS) Smooth(est []filter.Estimate, u []mat.Vector) ([]filter.Estimate, error) {
+	if est == nil {
+		return nil, fmt.Errorf("Invalid estimates size")
+	}
+
+	if u != nil && len(u) != len(est) {
+		return nil, fmt.Errorf("Invalid input vector size")
+	}
+
+	sx := make([]filter.Estimate, len(est))
+
+	// create initial estimate to work from recursively
+	e, err := estimate.NewBaseWithCov(s.start.State(), s.start.Cov())
+	if err != nil {
+		return nil, err
+	}
+
+	// smoothed state
+	x := &mat.Dense{}
+	pk := &mat.Dense{}
+
+	var uEst mat.Vector = nil
+	for i := len(est) - 1; i >= 0; i-- {
+		if u != nil {
+			uEst = u[i]
+		}
+
+		// generate sigma points around the estimate
+		sp, err := sigma.Transform(s.unit, est[i].Val(), est[i].Cov())
+		if err != nil {
+			return nil, fmt.Errorf("Failed to generate sigma points: %v", err)
+		}
+
+		// propagate sigma points to the next step
+		xPts, err := sigma.Propagate(s.m, sp.X, uEst)
+		if err != nil {
+			return nil, fmt.Errorf("Model state propagation failed: %v", err)
+		}
+
+		// predicted state and covariance
+		xk1 := sigma.Mean(xPts, s.unit.Wm)
+		pk1 := sigma.CrossCov(xPts, xk1, xPts, xk1, s.unit.Wc)
+
+		if _, ok := s.q.(*noise.None); !ok {
+			qCov, err := filter.StateNoiseCov(s.m, s.q.Cov())
+			if err != nil {
+				return nil, fmt.Errorf("Failed to map state noise: %v", err)
+			}
+			pk1.Add(pk1, qCov)
+		}
+
+		// cross covariance of the estimate and the predicted state
+		xMean := sigma.Mean(sp.X, s.unit.Wm)
+		d := sigma.CrossCov(sp.X, xMean, xPts, xk1, s.unit.Wc)
+
+		// calculat smoothing matrix
+		c := &mat.Dense{}
+		// P_(k+1)^-1 inverse
+		pinv := &mat.Dense{}
+		// invert predicted P_k+1 covariance
+		if err := pinv.Inverse(pk1); err != nil {
+			return nil, err
+		}
+		// Dk * P_(k+1)^-1
+		c.Mul(d, pinv)
+
+		// smooth the state
+		x.Sub(e.Val(), xk1)
+		// c*x
+		x.Mul(c, x)
+		// xk + Ck*x_sub
+		x.Add(est[i].Val(), x)
+
+		// smoothed covariance
+		cov := &mat.Dense{}
+		// smooth covariance
+		cov.Sub(e.Cov(), pk1)
+		// Ck*P_sub
+		pk.Mul(c, cov)
+		// Ck*P_sub*Ck'
+		pk.Mul(pk, c.T())
+		// Pk + Ck*P_sub*Ck'
+		pk.Add(est[i].Cov(), pk)
+
+		r, _ := cov.Dims()
+		pSmooth := mat.NewSymDense(r, nil)
+		// update covariance matrix
+		for i := 0; i < r; i++ {
+			for j := i; j < r; j++ {
+				pSmooth.SetSym(i, j, 0.5*(pk.At(i, j)+pk.At(j, i)))
+			}
+		}
+
+		e, err = estimate.NewBaseWithCov(x.ColView(0), pSmooth)
+		if err != nil {
+			return nil, err
+		}
+		sx[i] = e
+	}
+
+	return sx, nil
+}
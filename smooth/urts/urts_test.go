@@ -0,0 +1,165 @@
+package urts
+
+import (
+	"os"
+	"testing"
+
+	filter "github.com/milosgajdos/go-estimate"
+	"github.com/milosgajdos/go-estimate/estimate"
+	"github.com/milosgajdos/go-estimate/kalman/ukf"
+	"github.com/milosgajdos/go-estimate/noise"
+	"github.com/milosgajdos/go-estimate/sim"
+	"github.com/milosgajdos/go-estimate/smooth/erts"
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/mat"
+)
+
+type invalidModel struct {
+	filter.DiscreteModel
+	r int
+	c int
+}
+
+func (m *invalidModel) SystemDims() (int, int, int, int) {
+	return m.r, m.c, m.c, 0
+}
+
+var (
+	okModel  *sim.BaseModel
+	badModel *invalidModel
+	ic       *sim.InitCond
+	q        filter.Noise
+	c        *ukf.Config
+	ex       []filter.Estimate
+	ux       []mat.Vector
+	n        int
+)
+
+func setup() {
+	// initial condition
+	initState := mat.NewVecDense(2, []float64{1.0, 3.0})
+	initCov := mat.NewSymDense(2, []float64{0.25, 0, 0, 0.25})
+	ic = sim.NewInitCond(initState, initCov)
+
+	// state and output noise
+	q, _ = noise.NewGaussian([]float64{0, 0}, initCov)
+
+	A := mat.NewDense(2, 2, []float64{1.0, 1.0, 0.0, 1.0})
+	B := mat.NewDense(2, 1, []float64{0.5, 1.0})
+	C := mat.NewDense(1, 2, []float64{1.0, 0.0})
+	D := mat.NewDense(1, 1, []float64{0.0})
+
+	n = 2
+	// generate some estimates
+	for i := 0; i < 5; i++ {
+		e, _ := estimate.NewBaseWithCov(
+			mat.NewVecDense(n, []float64{1.0 + float64(i), 3.0}),
+			mat.NewSymDense(n, []float64{0.25, 0.1, 0.1, 0.25}))
+		u := mat.NewVecDense(1, []float64{-1.0})
+		ex = append(ex, e)
+		ux = append(ux, u)
+	}
+
+	okModel = &sim.BaseModel{A: A, B: B, C: C, D: D}
+	badModel = &invalidModel{DiscreteModel: okModel, r: 10, c: 10}
+
+	c = &ukf.Config{
+		Alpha: 0.75,
+		Beta:  2.0,
+		Kappa: 3.0,
+	}
+}
+
+func TestMain(m *testing.M) {
+	// set up tests
+	setup()
+	// run the tests
+	retCode := m.Run()
+	// call with result of m.Run()
+	os.Exit(retCode)
+}
+
+func TestNewURTS(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := New(okModel, ic, q, c)
+	assert.NotNil(s)
+	assert.NoError(err)
+
+	// nil noise
+	s, err = New(okModel, ic, nil, c)
+	assert.NotNil(s)
+	assert.NoError(err)
+
+	// invalid sigma point parameters
+	s, err = New(okModel, ic, q, &ukf.Config{Points: &ukf.Simplex{W0: 2.0}})
+	assert.Nil(s)
+	assert.Error(err)
+
+	// invalid model: negative dimensions
+	badModel.r, badModel.c = -10, 20
+	s, err = New(badModel, ic, q, c)
+	assert.Nil(s)
+	assert.Error(err)
+
+	// invalid state noise dimension
+	_q, _ := noise.NewZero(20)
+	s, err = New(okModel, ic, _q, c)
+	assert.Nil(s)
+	assert.Error(err)
+}
+
+func TestURTSSmooth(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := New(okModel, ic, q, c)
+	assert.NotNil(s)
+	assert.NoError(err)
+
+	sx, err := s.Smooth(nil, ux)
+	assert.Nil(sx)
+	assert.Error(err)
+
+	sx, err = s.Smooth(ex, ux[0:1])
+	assert.Nil(sx)
+	assert.Error(err)
+
+	sx, err = s.Smooth(ex, ux)
+	assert.NotNil(sx)
+	assert.NoError(err)
+	assert.Len(sx, len(ex))
+
+	// invalid input vector
+	_ux := []mat.Vector{nil, nil, nil, nil, mat.NewVecDense(3, nil)}
+	sx, err = s.Smooth(ex, _ux)
+	assert.Nil(sx)
+	assert.Error(err)
+}
+
+func TestURTSLinear(t *testing.T) {
+	assert := assert.New(t)
+
+	// URTS matches ERTS for linear models
+	for _, _q := range []filter.Noise{nil, q} {
+		es, err := erts.New(okModel, ic, _q)
+		assert.NotNil(es)
+		assert.NoError(err)
+
+		s, err := New(okModel, ic, _q, c)
+		assert.NotNil(s)
+		assert.NoError(err)
+
+		exp, err := es.Smooth(ex, ux)
+		assert.NoError(err)
+		sx, err := s.Smooth(ex, ux)
+		assert.NoError(err)
+
+		for i := range sx {
+			assert.True(mat.EqualApprox(exp[i].Cov(), sx[i].Cov(), 1e-6))
+			// ERTS propagates the estimates with sampled state noise
+			if _q == nil {
+				assert.True(mat.EqualApprox(exp[i].Val(), sx[i].Val(), 1e-6))
+			}
+		}
+	}
+}